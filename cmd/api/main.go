@@ -1,19 +1,35 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"runar-himmel/config"
+	"runar-himmel/internal/api/audit"
 	"runar-himmel/internal/api/auth"
+	"runar-himmel/internal/api/job"
+	"runar-himmel/internal/api/memo"
+	"runar-himmel/internal/api/notification"
+	"runar-himmel/internal/api/organization"
+	"runar-himmel/internal/api/permission"
 	"runar-himmel/internal/api/root"
+	"runar-himmel/internal/api/user"
+	"runar-himmel/internal/api/webhook"
+	"runar-himmel/internal/cache"
 	"runar-himmel/internal/db"
+	"runar-himmel/internal/notify"
 	"runar-himmel/internal/rbac"
 	"runar-himmel/internal/repo"
+	"time"
 
 	"runar-himmel/pkg/server"
+	"runar-himmel/pkg/server/middleware/actor"
 	"runar-himmel/pkg/server/middleware/jwt"
 	"runar-himmel/pkg/server/middleware/secure"
+	"runar-himmel/pkg/server/middleware/tenant"
 	"runar-himmel/pkg/util/crypter"
+	httputil "runar-himmel/pkg/util/http"
+	snsutil "runar-himmel/pkg/util/sns"
 
 	"github.com/labstack/echo/v4"
 )
@@ -48,6 +64,8 @@ func main() {
 		WriteTimeout:      cfg.Server.WriteTimeout,
 		AllowOrigins:      cfg.Server.AllowOrigins,
 		Debug:             cfg.General.Debug,
+		// the errors of the database & the repos, e.g. 404 for the missing records
+		ErrorTranslators: []server.ErrorTranslator{httputil.TranslateError},
 	})
 
 	// custom api context
@@ -60,19 +78,48 @@ func main() {
 
 	// Initialize core services
 	crypterSvc := crypter.New()
-	repoSvc := repo.New(db)
-	rbacSvc := rbac.New(cfg.General.Debug)
+	cacheStore, err := cache.New(cfg.Cache)
+	checkErr(err)
+	repoSvc := repo.NewWithCache(db, cacheStore, time.Duration(cfg.Cache.TTL)*time.Second)
+	rbacSvc, err := rbac.New(db, cfg.RBAC, cfg.General.Debug)
+	checkErr(err)
+	if cfg.RBAC.ModelFile != "" && cfg.RBAC.ReloadInterval > 0 {
+		go rbacSvc.WatchFiles(context.Background(), time.Duration(cfg.RBAC.ReloadInterval)*time.Second, func(err error) {
+			e.Logger.Errorf("cannot reload RBAC files: %v", err)
+		})
+	}
+	notifySvc, err := notify.New(db, snsutil.New(), cfg.Notify)
+	checkErr(err)
 	jwtSvc := jwt.New(cfg.JWT.Algorithm, cfg.JWT.Secret, cfg.JWT.DurationAccessToken, cfg.JWT.DurationRefreshToken)
 
 	fmt.Println(crypterSvc, rbacSvc, jwtSvc, repoSvc)
 
 	// Initialize services
 	authSvc := auth.New(repoSvc, jwtSvc, crypterSvc)
+	permissionSvc := permission.New(repoSvc, rbacSvc)
+	organizationSvc := organization.New(repoSvc, rbacSvc)
+	memoSvc := memo.New(repoSvc, rbacSvc)
+	userSvc := user.New(repoSvc, crypterSvc)
+	auditSvc := audit.New(repoSvc)
+	webhookSvc := webhook.New(repoSvc)
+	jobSvc := job.New(repoSvc)
+	notificationSvc := notification.New(notifySvc)
 
 	// Initialize root API
 	root.NewHTTP(e)
 
-	auth.NewHTTP(authSvc, e.Group("/auth"))
+	auth.NewHTTP(authSvc, e.Group("/auth", actor.Middleware("id")))
+	organization.NewHTTP(organizationSvc, e.Group("/organizations", jwtSvc.MWFunc(), actor.Middleware("id")))
+	memo.NewHTTP(memoSvc, e.Group("/memos", jwtSvc.MWFunc(), tenant.Middleware("org"), actor.Middleware("id")))
+	notification.NewHTTP(notificationSvc, e.Group("/notifications", jwtSvc.MWFunc(), actor.Middleware("id")))
+
+	// Initialize admin APIs, restricted to superadmins
+	adminRouter := e.Group("/admin", jwtSvc.MWFunc(), rbac.RequireRoles(rbac.RoleSuperAdmin), actor.Middleware("id"))
+	permission.NewHTTP(permissionSvc, adminRouter)
+	user.NewHTTP(userSvc, adminRouter)
+	audit.NewHTTP(auditSvc, adminRouter)
+	webhook.NewHTTP(webhookSvc, adminRouter)
+	job.NewHTTP(jobSvc, adminRouter)
 
 	// ctx := context.Context(context.Background())
 	// newUser := &types.User{
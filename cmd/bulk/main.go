@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/api/user"
+	"runar-himmel/internal/db"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+	"runar-himmel/pkg/server"
+	"runar-himmel/pkg/util/crypter"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+const usage = `Usage: bulk [flags] <command> <resource> [file]
+
+Commands:
+  import   Imports the records from the file, or stdin if omitted
+  export   Exports the records to the file, or stdout if omitted
+
+Resources:
+  users
+
+Flags:
+`
+
+func main() {
+	format := flag.String("format", "", "csv or ndjson, detected from the file extension if omitted (default csv)")
+	dryRun := flag.Bool("dry-run", false, "validates the import without changing anything")
+	query := flag.String("query", "", `the export filters & sorting, same as the listing query string, e.g. "filter[role]=admin&sort=-created_at"`)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 || flag.Arg(1) != "users" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(2)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if *format == "" {
+			*format = repoutil.FormatCSV
+		}
+	}
+
+	svc, closeDB, err := newService()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := 2
+	switch cmd := flag.Arg(0); cmd {
+	case "import":
+		code = importUsers(svc, path, *format, *dryRun)
+	case "export":
+		code = exportUsers(svc, path, *format, *query)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+	}
+	closeDB()
+	os.Exit(code)
+}
+
+func newService() (*user.User, func(), error) {
+	cfg, err := config.LoadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	gdb, sqldb, err := db.New(cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user.New(repo.New(gdb), crypter.New()), func() { sqldb.Close() }, nil
+}
+
+// importUsers prints the import result as JSON, returns 1 if any row fails
+func importUsers(svc *user.User, path, format string, dryRun bool) int {
+	var r io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result, err := svc.Import(context.Background(), r, repoutil.ImportOptions{
+		Format:    format,
+		DryRun:    dryRun,
+		Validator: server.NewValidator(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// exportUsers writes the users to the file, prints the number of exported users to stderr
+func exportUsers(svc *user.User, path, format, query string) int {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid query: %v\n", err)
+		return 2
+	}
+	lqc, err := httputil.ParseListQuery[types.User](params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := svc.Export(context.Background(), w, format, lqc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d users exported\n", n)
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/db"
+	"runar-himmel/pkg/rbac"
+)
+
+const usage = `Usage: rbac [flags] <command> [args]
+
+Commands:
+  enforce <values...>   Evaluates a request, e.g. "enforce alice org1 memo view"
+  test <file>           Runs the assertions in the given file, one "<values...>, allow|deny" per line
+
+Flags:
+`
+
+func main() {
+	modelFile := flag.String("model", "config/rbac/model.conf", "the model file")
+	policyFile := flag.String("policy", "config/rbac/policy.csv", "the policy file")
+	withDB := flag.Bool("db", false, "loads the policies of the database configured by the environment as well, like the API")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, closeDB := rbac.Config{}, func() {}
+	if *withDB {
+		dbCfg := config.DB{}
+		if err := config.Load(&dbCfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		gdb, sqldb, err := db.New(dbCfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		cfg.GormDB, closeDB = gdb, func() { sqldb.Close() }
+	}
+
+	r, err := rbac.NewFromFiles(cfg, *modelFile, *policyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		closeDB()
+		os.Exit(1)
+	}
+
+	code := 2
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "enforce":
+		code = enforce(r, args)
+	case "test":
+		code = test(r, args[0])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+	}
+	closeDB()
+	os.Exit(code)
+}
+
+// enforce prints the decision of the given request, returns 1 if denied
+func enforce(r *rbac.RBAC, args []string) int {
+	rvals := make([]interface{}, 0, len(args))
+	for _, v := range args {
+		rvals = append(rvals, v)
+	}
+
+	allowed, err := r.EnforceSafe(rvals...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !allowed {
+		fmt.Println("deny")
+		return 1
+	}
+	fmt.Println("allow")
+	return 0
+}
+
+// test runs the assertions in the given file, returns 1 if any of them fails
+func test(r *rbac.RBAC, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	list, err := rbac.ParseAssertions(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	failed := 0
+	for _, res := range r.RunAssertions(list) {
+		fmt.Println(res)
+		if !res.Passed() {
+			failed++
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(list)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/db"
+	"runar-himmel/internal/job"
+	"runar-himmel/internal/notify"
+	jobutil "runar-himmel/pkg/util/job"
+	snsutil "runar-himmel/pkg/util/sns"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	if config.IsLambda() {
+		// start lambda request handler, expected to be invoked on schedule
+		lambda.Start(handler)
+		return
+	}
+
+	// run the worker until interrupted, the running jobs are finished before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Run(ctx, false); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+func handler(ctx context.Context) (string, error) {
+	if err := Run(ctx, true); err != nil {
+		return "Jobs failed!", err
+	}
+	return "Jobs completed!", nil
+}
+
+// Run runs the background jobs of the configured queues.
+// If once is true, it returns when all due jobs are run, otherwise it keeps polling until ctx is done.
+func Run(ctx context.Context, once bool) error {
+	cfg, err := config.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	db, sqldb, err := db.New(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer sqldb.Close()
+
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{
+		Queues:       cfg.Worker.Queues,
+		Concurrency:  cfg.Worker.Concurrency,
+		PollInterval: time.Duration(cfg.Worker.PollInterval) * time.Millisecond,
+		Lease:        time.Duration(cfg.Worker.Lease) * time.Second,
+		MinBackoff:   time.Duration(cfg.Worker.MinBackoff) * time.Second,
+		MaxBackoff:   time.Duration(cfg.Worker.MaxBackoff) * time.Second,
+	})
+	snsSvc := snsutil.New()
+	notifySvc, err := notify.New(db, snsSvc, cfg.Notify)
+	if err != nil {
+		return err
+	}
+	job.Register(w, snsSvc, notifySvc)
+
+	if once {
+		return w.Drain(ctx)
+	}
+	w.Run(ctx, func(err error) { log.Println(err) })
+	return nil
+}
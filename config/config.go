@@ -1,5 +1,10 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 type (
 	// Configuration holds data necessery for configuring application
 	Configuration struct {
@@ -7,6 +12,13 @@ type (
 		Server
 		DB
 		JWT
+		RBAC
+		Cache
+		Outbox
+		Webhook
+		Worker
+		Cron
+		Notify
 	}
 
 	// General holds general configurations
@@ -30,14 +42,30 @@ type (
 
 	// DB holds DB configurations
 	DB struct {
-		Driver   string `env:"DB_DRIVER,notEmpty"`
-		Host     string `env:"DB_HOST,notEmpty"`
-		Port     int    `env:"DB_PORT,notEmpty"`
-		Username string `env:"DB_USERNAME,notEmpty"`
-		Password string `env:"DB_PASSWORD,notEmpty"`
-		Database string `env:"DB_DATABASE,notEmpty"`
+		Driver string `env:"DB_DRIVER,notEmpty"` // mysql, postgres or sqlite3
+		// Connection settings, not used by sqlite3
+		Host     string `env:"DB_HOST"`
+		Port     int    `env:"DB_PORT"`
+		Username string `env:"DB_USERNAME"`
+		Password string `env:"DB_PASSWORD"`
+		// The database name, or the file path for sqlite3. Empty or `:memory:` for sqlite3 in-memory mode
+		Database string `env:"DB_DATABASE"`
 		Logging  int    `env:"DB_LOGGING" envDefault:"1"` // 0=discard, 1=silent, 2=error, 3=warn, 4=info
 		Params   string `env:"DB_PARAMS"`
+		// Queries taking longer are logged with the request ID unless DB_LOGGING is 0, in millisecond. 0 to disable.
+		SlowThreshold int `env:"DB_SLOW_THRESHOLD" envDefault:"200"`
+		// Default deadline of each query in second, 0 to disable. See repoutil.WithTimeout for overriding it per call.
+		QueryTimeout int `env:"DB_QUERY_TIMEOUT" envDefault:"10"`
+		// Record the changes of the audited models into audit_logs, see auditutil.Use
+		Audit bool `env:"DB_AUDIT" envDefault:"true"`
+		// Read replicas as `host[:port]` separated by comma, sharing the other settings with the primary
+		Replicas []string `env:"DB_REPLICAS"`
+		// Connection pool settings, applied to the primary and each replica.
+		// These are not one-size-fits-all settings. Tune them based on your db settings!
+		MaxIdleConns    int `env:"DB_MAX_IDLE_CONNS" envDefault:"10"`
+		MaxOpenConns    int `env:"DB_MAX_OPEN_CONNS" envDefault:"10"`
+		ConnMaxLifetime int `env:"DB_CONN_MAX_LIFETIME" envDefault:"1800"` // in second
+		ConnMaxIdleTime int `env:"DB_CONN_MAX_IDLE_TIME" envDefault:"600"` // in second
 	}
 
 	// JWT holds JWT configurations
@@ -48,6 +76,102 @@ type (
 		DurationRefreshToken int    `env:"JWT_DURATION_REFRESH_TOKEN" envDefault:"86400"` // 1 day in second
 	}
 
+	// RBAC holds RBAC configurations
+	RBAC struct {
+		// The model & policy files, e.g. config/rbac/model.conf & config/rbac/policy.csv.
+		// Leave empty to load the model from code and the policies from the database only.
+		ModelFile  string `env:"RBAC_MODEL_FILE"`
+		PolicyFile string `env:"RBAC_POLICY_FILE"`
+		// How often (in seconds) the files are checked for changes, 0 to disable reloading
+		ReloadInterval int `env:"RBAC_RELOAD_INTERVAL" envDefault:"10"`
+	}
+
+	// Cache holds repository cache configurations
+	Cache struct {
+		// The cache backend: `lru` for in-process, `redis` for Redis-protocol servers. Empty to disable caching.
+		Driver string `env:"CACHE_DRIVER"`
+		// Time to live of the entries in second
+		TTL int `env:"CACHE_TTL" envDefault:"300"`
+		// Maximum number of entries of the `lru` backend
+		Size int `env:"CACHE_SIZE" envDefault:"10000"`
+		// Settings of the `redis` backend, all keys are prefixed by `Prefix`
+		RedisAddr     string `env:"CACHE_REDIS_ADDR"`
+		RedisPassword string `env:"CACHE_REDIS_PASSWORD"`
+		RedisDB       int    `env:"CACHE_REDIS_DB"`
+		Prefix        string `env:"CACHE_PREFIX" envDefault:"runar-himmel:"`
+	}
+
+	// Outbox holds the configurations of the dispatcher delivering the domain events
+	Outbox struct {
+		// The sinks receiving the events separated by comma: log, webhook, sns & webhooks (the subscriptions, see Webhook)
+		Sinks []string `env:"OUTBOX_SINKS" envDefault:"log"`
+		// Maximum number of events delivered per batch
+		BatchSize int `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+		// How often the outbox is polled when idle in millisecond, not used by Lambda
+		PollInterval int `env:"OUTBOX_POLL_INTERVAL" envDefault:"1000"`
+		// Failed deliveries are retried with exponential backoff between the min & max in second, up to the max attempts
+		MaxAttempts int `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+		MinBackoff  int `env:"OUTBOX_MIN_BACKOFF" envDefault:"1"`
+		MaxBackoff  int `env:"OUTBOX_MAX_BACKOFF" envDefault:"3600"`
+		// Settings of the `webhook` sink, the authorization is sent as the Authorization header if given
+		WebhookURL           string `env:"OUTBOX_WEBHOOK_URL"`
+		WebhookAuthorization string `env:"OUTBOX_WEBHOOK_AUTHORIZATION"`
+		// Settings of the `sns` sink
+		SNSTopicARN string `env:"OUTBOX_SNS_TOPIC_ARN"`
+	}
+
+	// Webhook holds the configurations of the dispatcher sending the events to the webhook subscriptions
+	Webhook struct {
+		// The timeout of each request in second
+		Timeout int `env:"WEBHOOK_TIMEOUT" envDefault:"10"`
+		// Maximum number of requests sent concurrently
+		Concurrency int `env:"WEBHOOK_CONCURRENCY" envDefault:"10"`
+		// Failed deliveries are retried with exponential backoff between the min & max in second, up to the max attempts
+		MaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+		MinBackoff  int `env:"WEBHOOK_MIN_BACKOFF" envDefault:"30"`
+		MaxBackoff  int `env:"WEBHOOK_MAX_BACKOFF" envDefault:"21600"`
+		// Number of consecutive failed attempts disabling the subscription
+		DisableAfter int `env:"WEBHOOK_DISABLE_AFTER" envDefault:"25"`
+	}
+
+	// Worker holds the configurations of the worker running the background jobs
+	Worker struct {
+		// The queues to work on separated by comma
+		Queues []string `env:"WORKER_QUEUES" envDefault:"default"`
+		// Maximum number of jobs run concurrently
+		Concurrency int `env:"WORKER_CONCURRENCY" envDefault:"4"`
+		// How often the queues are polled when idle in millisecond, not used by Lambda
+		PollInterval int `env:"WORKER_POLL_INTERVAL" envDefault:"1000"`
+		// How long a job is reserved for its worker in second, it is also the timeout of the job
+		Lease int `env:"WORKER_LEASE" envDefault:"300"`
+		// Failed jobs are retried with exponential backoff between the min & max in second, up to their max attempts
+		MinBackoff int `env:"WORKER_MIN_BACKOFF" envDefault:"10"`
+		MaxBackoff int `env:"WORKER_MAX_BACKOFF" envDefault:"3600"`
+	}
+
+	// Cron holds the configurations of the scheduler running the periodic tasks
+	Cron struct {
+		// How often the due tasks are checked in second, not used by Lambda
+		PollInterval int `env:"CRON_POLL_INTERVAL" envDefault:"15"`
+		// How long a running task is locked for its instance in second, it is also the timeout of the tasks
+		Lease int `env:"CRON_LEASE" envDefault:"600"`
+		// The time zone of the schedules, e.g. Asia/Ho_Chi_Minh
+		Timezone string `env:"CRON_TIMEZONE" envDefault:"UTC"`
+		// The OTPs sent earlier than the TTL in second are cleared
+		OTPTTL int `env:"CRON_OTP_TTL" envDefault:"600"`
+		// The delivered events, succeeded jobs & the run history older than the retention in day are deleted
+		Retention int `env:"CRON_RETENTION" envDefault:"30"`
+	}
+
+	// Notify holds the configurations of the push notifications sent to the devices of the users
+	Notify struct {
+		// The SNS platform applications of the devices, the platform is disabled if empty
+		IOSAppARN     string `env:"NOTIFY_IOS_APP_ARN"`
+		AndroidAppARN string `env:"NOTIFY_ANDROID_APP_ARN"`
+		// The topics the users may subscribe to separated by comma, each as name=arn, e.g. news=arn:aws:sns:us-east-1:123456789012:news
+		Topics []string `env:"NOTIFY_TOPICS"`
+	}
+
 	// App holds app specific configurations
 	App struct {
 		// more app specific configurations
@@ -59,3 +183,16 @@ func LoadAll() (cfg Configuration, err error) {
 	err = Load(&cfg)
 	return
 }
+
+// TopicARNs returns the topic ARNs by name
+func (c Notify) TopicARNs() (map[string]string, error) {
+	topics := make(map[string]string, len(c.Topics))
+	for _, topic := range c.Topics {
+		name, arn, ok := strings.Cut(strings.TrimSpace(topic), "=")
+		if !ok || name == "" || arn == "" {
+			return nil, fmt.Errorf("invalid notify topic %q, expected name=arn", topic)
+		}
+		topics[name] = arn
+	}
+	return topics, nil
+}
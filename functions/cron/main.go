@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// the time zones of CRON_TIMEZONE, which may be missing on Lambda
+	_ "time/tzdata"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/cron"
+	"runar-himmel/internal/db"
+	"runar-himmel/internal/repo"
+	cronutil "runar-himmel/pkg/util/cron"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	if config.IsLambda() {
+		// start lambda request handler, expected to be invoked by EventBridge rules
+		lambda.Start(handler)
+		return
+	}
+
+	// run the given task once, or the scheduler until interrupted
+	task := flag.String("task", "", "runs the task once, e.g. sessions.purge")
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Run(ctx, func(s *cronutil.Scheduler) error {
+		if *task != "" {
+			return s.RunTask(ctx, *task, cronutil.TriggerManual)
+		}
+		s.Run(ctx, func(err error) { log.Println(err) })
+		return nil
+	}); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+func handler(ctx context.Context, event cronutil.Event) (string, error) {
+	if err := Run(ctx, func(s *cronutil.Scheduler) error {
+		return s.HandleEvent(ctx, event)
+	}); err != nil {
+		return "Cron failed!", err
+	}
+	return "Cron completed!", nil
+}
+
+// Run creates the scheduler with all periodic tasks, then runs fn with it
+func Run(ctx context.Context, fn func(s *cronutil.Scheduler) error) error {
+	cfg, err := config.LoadAll()
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(cfg.Cron.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid CRON_TIMEZONE: %w", err)
+	}
+
+	db, sqldb, err := db.New(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer sqldb.Close()
+
+	s := cronutil.NewScheduler(db, cronutil.SchedulerConfig{
+		PollInterval: time.Duration(cfg.Cron.PollInterval) * time.Second,
+		Lease:        time.Duration(cfg.Cron.Lease) * time.Second,
+		Location:     loc,
+	})
+	if err := cron.Register(s, repo.New(db), cfg.Cron); err != nil {
+		return err
+	}
+	return fn(s)
+}
@@ -6,8 +6,18 @@ import (
 	"runar-himmel/config"
 	"runar-himmel/internal/db"
 	"runar-himmel/internal/types"
+	"runar-himmel/pkg/rbac/casbinadapter"
+	auditutil "runar-himmel/pkg/util/audit"
+	cronutil "runar-himmel/pkg/util/cron"
 	"runar-himmel/pkg/util/crypter"
+	dbutil "runar-himmel/pkg/util/db"
+	jobutil "runar-himmel/pkg/util/job"
 	"runar-himmel/pkg/util/migration"
+	notifyutil "runar-himmel/pkg/util/notify"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	repoutil "runar-himmel/pkg/util/repo"
+	searchutil "runar-himmel/pkg/util/search"
+	webhookutil "runar-himmel/pkg/util/webhook"
 	"time"
 
 	"runar-himmel/internal/rbac"
@@ -17,10 +27,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// EnablePostgreSQL: remove this and all tx.Set() functions bellow
-// var defaultTableOpts = "ENGINE=InnoDB ROW_FORMAT=DYNAMIC"
-var defaultTableOpts = ""
-
 func main() {
 	if config.IsLambda() {
 		// start lambda request handler
@@ -48,6 +54,8 @@ func Run() (respErr error) {
 		return err
 	}
 
+	// the seeds may run before audit_logs is created
+	cfg.DB.Audit = false
 	db, sqldb, err := db.New(cfg.DB)
 	if err != nil {
 		return err
@@ -69,11 +77,13 @@ func Run() (respErr error) {
 		}
 	}()
 
-	// EnablePostgreSQL: remove these
-	// workaround for "Index column size too large" error on migrations table
-	initSQL := "CREATE TABLE IF NOT EXISTS migrations (id VARCHAR(255) PRIMARY KEY) " + defaultTableOpts
-	if err := db.Exec(initSQL).Error; err != nil {
-		return err
+	defaultTableOpts := dbutil.TableOptions(cfg.DB.Driver)
+	if dbutil.Dialect(cfg.DB.Driver) == dbutil.DialectMySQL {
+		// workaround for "Index column size too large" error on migrations table
+		initSQL := "CREATE TABLE IF NOT EXISTS migrations (id VARCHAR(255) PRIMARY KEY) " + defaultTableOpts
+		if err := db.Exec(initSQL).Error; err != nil {
+			return err
+		}
 	}
 
 	migration.Run(db, []*gormigrate.Migration{
@@ -140,6 +150,247 @@ func Run() (respErr error) {
 				return tx.Migrator().DropTable("users")
 			},
 		},
+		// create rbac policy table with default policies
+		{
+			ID: "202401051030",
+			Migrate: func(tx *gorm.DB) error {
+				type CasbinRule = casbinadapter.CasbinRule
+
+				if err := tx.Migrator().DropTable(&CasbinRule{}); err != nil {
+					return err
+				}
+
+				if err := tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&CasbinRule{}); err != nil {
+					return err
+				}
+
+				rules := make([]*CasbinRule, 0, len(rbac.DefaultPolicies))
+				for _, p := range rbac.DefaultPolicies {
+					rules = append(rules, &CasbinRule{PType: "p", V0: p[0], V1: p[1], V2: p[2]})
+				}
+				return tx.Create(rules).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("casbin_rules")
+			},
+		},
+		// multi-tenancy: organizations, memberships, memos and domain-scoped rbac policies
+		{
+			ID: "202401081200",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&types.Organization{}, &types.Membership{}, &types.Memo{}); err != nil {
+					return err
+				}
+
+				if err := tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&types.Organization{}, &types.Membership{}, &types.Memo{}); err != nil {
+					return err
+				}
+
+				// move existing policies to the `*` domain, so they apply to all organizations
+				if err := tx.Exec("UPDATE casbin_rules SET v3 = v2, v2 = v1, v1 = ? WHERE p_type = ? AND v3 = ''", rbac.DomainAny, "p").Error; err != nil {
+					return err
+				}
+				// role assignments without domain are no longer valid
+				return tx.Where("p_type = ? AND v2 = ''", "g").Delete(&casbinadapter.CasbinRule{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("organizations", "memberships", "memos")
+			},
+		},
+		// default memo policies, the `*_all` actions allow managing memos of other members
+		{
+			ID: "202401101500",
+			Migrate: func(tx *gorm.DB) error {
+				rules := []*casbinadapter.CasbinRule{}
+				for _, p := range [][]string{
+					{rbac.RoleAdmin, rbac.ObjectMemo, rbac.ActionViewAll},
+					{rbac.RoleAdmin, rbac.ObjectMemo, rbac.ActionCreate},
+					{rbac.RoleAdmin, rbac.ObjectMemo, rbac.ActionUpdateAll},
+					{rbac.RoleAdmin, rbac.ObjectMemo, rbac.ActionDeleteAll},
+					{rbac.RoleCustomer, rbac.ObjectMemo, rbac.ActionView},
+					{rbac.RoleCustomer, rbac.ObjectMemo, rbac.ActionCreate},
+					{rbac.RoleCustomer, rbac.ObjectMemo, rbac.ActionUpdate},
+					{rbac.RoleCustomer, rbac.ObjectMemo, rbac.ActionDelete},
+				} {
+					rules = append(rules, &casbinadapter.CasbinRule{PType: "p", V0: p[0], V1: rbac.DomainAny, V2: p[1], V3: p[2]})
+				}
+				return tx.Create(rules).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Where("p_type = ? AND v2 = ?", "p", rbac.ObjectMemo).Delete(&casbinadapter.CasbinRule{}).Error
+			},
+		},
+		// soft delete users, the unique indexes include deleted_at so emails & phones of deleted users can be reused
+		{
+			ID: "202401121000",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					Email     string             `gorm:"uniqueIndex:uix_users_email,priority:1"`
+					Phone     string             `gorm:"uniqueIndex:uix_users_phone,priority:1"`
+					DeletedAt repoutil.DeletedAt `gorm:"not null;default:0;uniqueIndex:uix_users_email,priority:2;uniqueIndex:uix_users_phone,priority:2"`
+				}
+
+				// fresh databases already have the column, see the initial migration
+				if tx.Migrator().HasColumn(&User{}, "deleted_at") {
+					return nil
+				}
+				if err := tx.Migrator().AddColumn(&User{}, "DeletedAt"); err != nil {
+					return err
+				}
+				for _, idx := range []string{"uix_users_email", "uix_users_phone"} {
+					if err := tx.Migrator().DropIndex(&User{}, idx); err != nil {
+						return err
+					}
+					if err := tx.Migrator().CreateIndex(&User{}, idx); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				type User struct {
+					Email     string `gorm:"uniqueIndex:uix_users_email"`
+					Phone     string `gorm:"uniqueIndex:uix_users_phone"`
+					DeletedAt int64
+				}
+
+				// soft deleted users must be purged, otherwise the unique indexes cannot be created
+				if err := tx.Where("deleted_at <> 0").Delete(&User{}).Error; err != nil {
+					return err
+				}
+				for _, idx := range []string{"uix_users_email", "uix_users_phone"} {
+					if err := tx.Migrator().DropIndex(&User{}, idx); err != nil {
+						return err
+					}
+					if err := tx.Migrator().CreateIndex(&User{}, idx); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropColumn(&User{}, "deleted_at")
+			},
+		},
+		// optimistic locking for memos
+		{
+			ID: "202401151000",
+			Migrate: func(tx *gorm.DB) error {
+				type Memo struct {
+					Version repoutil.Version `gorm:"not null;default:1"`
+				}
+
+				// fresh databases already have the column
+				if tx.Migrator().HasColumn(&Memo{}, "version") {
+					return nil
+				}
+				return tx.Migrator().AddColumn(&Memo{}, "Version")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn("memos", "version")
+			},
+		},
+		// full-text search of users & memos, see searchutil for the index of each dialect
+		{
+			ID: "202401181000",
+			Migrate: func(tx *gorm.DB) error {
+				for _, idx := range []searchutil.Index{
+					{Name: "ftx_users", Table: "users", Columns: []string{"first_name", "last_name", "email", "phone"}},
+					{Name: "ftx_memos", Table: "memos", Columns: []string{"content"}},
+				} {
+					if err := searchutil.New(tx, idx).CreateIndex(tx); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, idx := range []searchutil.Index{
+					{Name: "ftx_users", Table: "users"},
+					{Name: "ftx_memos", Table: "memos"},
+				} {
+					if err := searchutil.New(tx, idx).DropIndex(tx); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		// append-only audit logs of the data changes, see auditutil.Use
+		{
+			ID: "202401201000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&auditutil.Log{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("audit_logs")
+			},
+		},
+		// the outbox of the domain events, delivered by the dispatcher, see functions/outbox
+		{
+			ID: "202401221000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&outboxutil.Event{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("outbox")
+			},
+		},
+		// webhook subscriptions & their delivery log, see webhookutil.NewSink
+		{
+			ID: "202401241000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&webhookutil.Subscription{}, &webhookutil.Delivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("webhook_deliveries", "webhooks")
+			},
+		},
+		// the background jobs, run by the worker, see cmd/worker
+		{
+			ID: "202401261000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&jobutil.Job{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("jobs")
+			},
+		},
+		// the schedule state & run history of the periodic tasks, see functions/cron
+		{
+			ID: "202401281000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&cronutil.Task{}, &cronutil.Run{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("cron_runs", "cron_tasks")
+			},
+		},
+		// the devices of the users receiving the push notifications & their topics, see notifyutil
+		{
+			ID: "202401301000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&notifyutil.Device{}, &notifyutil.UserTopic{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("user_topics", "devices")
+			},
+		},
+		// optimistic locking for users
+		{
+			ID: "202402011000",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					Version repoutil.Version `gorm:"not null;default:1"`
+				}
+
+				// fresh databases already have the column
+				if tx.Migrator().HasColumn(&User{}, "version") {
+					return nil
+				}
+				return tx.Migrator().AddColumn(&User{}, "Version")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn("users", "version")
+			},
+		},
 	})
 
 	return nil
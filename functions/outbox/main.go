@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/db"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	snsutil "runar-himmel/pkg/util/sns"
+	webhookutil "runar-himmel/pkg/util/webhook"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+func main() {
+	if config.IsLambda() {
+		// start lambda request handler, expected to be invoked on schedule
+		lambda.Start(handler)
+		return
+	}
+
+	// run the dispatcher until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Run(ctx, false); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+func handler(ctx context.Context) (string, error) {
+	if err := Run(ctx, true); err != nil {
+		return "Outbox dispatching failed!", err
+	}
+	return "Outbox dispatching completed!", nil
+}
+
+// Run delivers the domain events of the outbox to the configured sinks, along with the webhook deliveries
+// if the webhooks sink is enabled. If once is true, it returns when all due events & deliveries are dispatched,
+// otherwise it keeps polling until ctx is done.
+func Run(ctx context.Context, once bool) error {
+	cfg, err := config.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	db, sqldb, err := db.New(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer sqldb.Close()
+
+	sinks, err := newSinks(db, cfg.Outbox)
+	if err != nil {
+		return err
+	}
+	dispatchers := []dispatcher{outboxutil.NewDispatcher(db, outboxutil.DispatcherConfig{
+		BatchSize:    cfg.Outbox.BatchSize,
+		PollInterval: time.Duration(cfg.Outbox.PollInterval) * time.Millisecond,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		MinBackoff:   time.Duration(cfg.Outbox.MinBackoff) * time.Second,
+		MaxBackoff:   time.Duration(cfg.Outbox.MaxBackoff) * time.Second,
+	}, sinks...)}
+	if lo.Contains(cfg.Outbox.Sinks, "webhooks") {
+		dispatchers = append(dispatchers, webhookutil.NewDispatcher(db, webhookutil.DispatcherConfig{
+			BatchSize:    cfg.Outbox.BatchSize,
+			Concurrency:  cfg.Webhook.Concurrency,
+			PollInterval: time.Duration(cfg.Outbox.PollInterval) * time.Millisecond,
+			Timeout:      time.Duration(cfg.Webhook.Timeout) * time.Second,
+			MaxAttempts:  cfg.Webhook.MaxAttempts,
+			MinBackoff:   time.Duration(cfg.Webhook.MinBackoff) * time.Second,
+			MaxBackoff:   time.Duration(cfg.Webhook.MaxBackoff) * time.Second,
+			DisableAfter: cfg.Webhook.DisableAfter,
+		}))
+	}
+
+	if !once {
+		wg := sync.WaitGroup{}
+		for _, d := range dispatchers {
+			wg.Add(1)
+			go func(d dispatcher) {
+				defer wg.Done()
+				d.Run(ctx, func(err error) { log.Println(err) })
+			}(d)
+		}
+		wg.Wait()
+		return nil
+	}
+	// in order, so the deliveries queued by the outbox are sent in the same run
+	for _, d := range dispatchers {
+		for {
+			n, err := d.DispatchOnce(ctx)
+			if err != nil {
+				return err
+			}
+			if n < cfg.Outbox.BatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+type dispatcher interface {
+	Run(ctx context.Context, onError func(error))
+	DispatchOnce(ctx context.Context) (int, error)
+}
+
+func newSinks(db *gorm.DB, cfg config.Outbox) ([]outboxutil.Sink, error) {
+	sinks := make([]outboxutil.Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, outboxutil.NewLogSink(log.New(os.Stdout, "", log.LstdFlags)))
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				return nil, errors.New("OUTBOX_WEBHOOK_URL is required by the webhook sink")
+			}
+			header := http.Header{}
+			if cfg.WebhookAuthorization != "" {
+				header.Set("Authorization", cfg.WebhookAuthorization)
+			}
+			sinks = append(sinks, outboxutil.NewWebhookSink(outboxutil.WebhookConfig{URL: cfg.WebhookURL, Header: header}))
+		case "sns":
+			if cfg.SNSTopicARN == "" {
+				return nil, errors.New("OUTBOX_SNS_TOPIC_ARN is required by the sns sink")
+			}
+			sinks = append(sinks, outboxutil.NewSNSSink(snsutil.New(), cfg.SNSTopicARN))
+		case "webhooks":
+			sinks = append(sinks, webhookutil.NewSink(db))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("no outbox sinks, see OUTBOX_SINKS")
+	}
+	return sinks, nil
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"runar-himmel/pkg/server"
+	auditutil "runar-himmel/pkg/util/audit"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// List returns the audit logs matching the filters of lqc.
+// Uses offset pagination if the page is given, cursor pagination otherwise.
+func (s *Audit) List(ctx context.Context, lqc *repoutil.ListQueryCondition) (*ListResp, error) {
+	var result *repoutil.ListResult[auditutil.Log]
+	var err error
+	if lqc.Page > 0 {
+		result, err = s.repo.AuditLog.ReadAllByCondition(ctx, lqc)
+	} else {
+		result, err = s.repo.AuditLog.ReadAllByCursor(ctx, lqc)
+	}
+	if errors.Is(err, repoutil.ErrInvalidCursor) {
+		return nil, server.NewHTTPValidationError("Invalid cursor").SetInternal(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ListResp{Data: result.Items, TotalCount: result.Total, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
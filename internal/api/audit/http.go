@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	auditutil "runar-himmel/pkg/util/audit"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// HTTP represents audit http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents audit service interface
+type Service interface {
+	List(context.Context, *repoutil.ListQueryCondition) (*ListResp, error)
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be restricted to superadmins.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /admin/audit-logs admin-audit adminAuditLogsList
+	// ---
+	// summary: Lists the audit logs of the data changes, the newest first
+	// description: |
+	//   Filterable by `entity`, `entity_id`, `actor_id`, `action` (exact, in), `request_id`, `ip` (exact)
+	//   and `created_at` (gte, lte, between), e.g. `filter[entity]=users&filter[entity_id]=id1`
+	//   or `filter[created_at__between]=2024-01-01,2024-02-01`. Sortable by `id` and `created_at`.
+	// parameters:
+	// - name: page
+	//   in: query
+	//   description: Page number for offset pagination, cursor pagination is used if omitted
+	//   type: integer
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   type: string
+	//   default: -created_at
+	// - name: cursor
+	//   in: query
+	//   description: The next_cursor or prev_cursor of the previous page, for cursor pagination
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of audit logs
+	//     schema:
+	//       "$ref": "#/definitions/AuditLogListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/audit-logs", h.list)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	lqc, err := httputil.ReqListQuery[auditutil.Log](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.List(c.Request().Context(), lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
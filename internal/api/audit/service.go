@@ -0,0 +1,17 @@
+package audit
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new audit service
+func New(repo *repo.Service) *Audit {
+	return &Audit{
+		repo: repo,
+	}
+}
+
+// Audit represents audit log application service
+type Audit struct {
+	repo *repo.Service
+}
@@ -0,0 +1,12 @@
+package audit
+
+import auditutil "runar-himmel/pkg/util/audit"
+
+// ListResp represents a page of audit logs
+// swagger:model AuditLogListResp
+type ListResp struct {
+	Data       []auditutil.Log `json:"data"`
+	TotalCount int64           `json:"total_count"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+}
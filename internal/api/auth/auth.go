@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"errors"
 	"runar-himmel/internal/rbac"
 	"runar-himmel/internal/types"
 
+	gjwt "github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -35,10 +37,67 @@ func (s *Auth) Login(c echo.Context, data Credentials) (*types.AuthToken, error)
 		return nil, ErrUserBlocked
 	}
 
-	return s.authenticate(c.Request().Context(), existedUser)
+	orgID, err := s.defaultOrganization(c.Request().Context(), existedUser.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authenticate(c.Request().Context(), existedUser, orgID)
 }
 
-// RefreshToken refreshes the access token
-func (s *Auth) RefreshToken(echo.Context, RefreshTokenData) (*types.AuthToken, error) {
-	return nil, nil
+// RefreshToken refreshes the access token, optionally switching to another organization of the user
+func (s *Auth) RefreshToken(c echo.Context, data RefreshTokenData) (*types.AuthToken, error) {
+	ctx := c.Request().Context()
+
+	token, err := s.jwt.ParseToken(data.RefreshToken)
+	if err != nil {
+		if errors.Is(err, gjwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidRefreshToken.SetInternal(err)
+	}
+	claims, ok := token.Claims.(gjwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidPayloadType
+	}
+	userID, _ := claims["id"].(string)
+
+	existedUser := &types.User{}
+	if err := s.repo.User.ReadByID(ctx, existedUser, userID); err != nil {
+		return nil, ErrInvalidRefreshToken.SetInternal(err)
+	}
+	// only the latest refresh token is valid
+	if existedUser.RefreshToken == nil || *existedUser.RefreshToken != data.RefreshToken {
+		return nil, ErrInvalidRefreshToken
+	}
+	if existedUser.Status == types.UserStatusBlocked.String() {
+		return nil, ErrUserBlocked
+	}
+
+	orgID := data.OrganizationID
+	if orgID != "" {
+		isMember, err := s.isMember(ctx, existedUser.ID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember {
+			return nil, ErrNotMember
+		}
+	} else {
+		// keep the current organization if the user is still a member
+		orgID, _ = claims["org"].(string)
+		isMember := false
+		if orgID != "" {
+			if isMember, err = s.isMember(ctx, existedUser.ID, orgID); err != nil {
+				return nil, err
+			}
+		}
+		if !isMember {
+			if orgID, err = s.defaultOrganization(ctx, existedUser.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.authenticate(ctx, existedUser, orgID)
 }
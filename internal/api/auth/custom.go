@@ -14,4 +14,5 @@ var (
 	ErrInvalidPayloadType  = server.NewHTTPError(http.StatusUnauthorized, "INVALID_PAYLOAD_TYPE", "Invalid payload type")
 	ErrRefreshToken        = server.NewHTTPError(http.StatusInternalServerError, "REFRESH_TOKEN_ERROR", "An error occur while refreshing token")
 	ErrInvalidGrantType    = server.NewHTTPError(http.StatusBadRequest, "INVALID_GRANT_TYPE", "Invalid grant type")
+	ErrNotMember           = server.NewHTTPError(http.StatusForbidden, "NOT_MEMBER", "You are not a member of the organization")
 )
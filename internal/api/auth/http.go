@@ -50,7 +50,7 @@ func NewHTTP(svc Service, eg *echo.Group) {
 
 	// swagger:operation POST /auth/refresh-token auth authRefreshToken
 	// ---
-	// summary: Refresh access token
+	// summary: Refresh access token, optionally switching to another organization
 	// security: []
 	// parameters:
 	// - name: token
@@ -19,4 +19,7 @@ type Credentials struct {
 // swagger:model
 type RefreshTokenData struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
+	// Switches to another organization of the user. Keeps the current one if empty.
+	// example: 01HKQ8ZJ4N0S7V2DXP5W1Y3T6M
+	OrganizationID string `json:"organization_id"`
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"runar-himmel/internal/types"
 	"runar-himmel/pkg/server/middleware/jwt"
+	repoutil "runar-himmel/pkg/util/repo"
 )
 
-func (s *Auth) authenticate(ctx context.Context, u *types.User) (*types.AuthToken, error) {
+// authenticate generates new tokens for the user, scoped to the given organization (empty for none)
+func (s *Auth) authenticate(ctx context.Context, u *types.User, orgID string) (*types.AuthToken, error) {
 	accessTokenOutput := jwt.TokenOutput{}
 	refreshTokenOutput := jwt.TokenOutput{}
 	if err := s.jwt.GenerateToken(&jwt.TokenInput{
@@ -17,6 +19,7 @@ func (s *Auth) authenticate(ctx context.Context, u *types.User) (*types.AuthToke
 			"email": u.Email,
 			"name":  fmt.Sprintf("%s %s", u.FirstName, u.LastName),
 			"role":  u.Role,
+			"org":   orgID,
 		},
 	}, &accessTokenOutput); err != nil {
 		return nil, err
@@ -25,7 +28,8 @@ func (s *Auth) authenticate(ctx context.Context, u *types.User) (*types.AuthToke
 	if err := s.jwt.GenerateToken(&jwt.TokenInput{
 		Type: jwt.TypeTokenRefresh,
 		Claims: map[string]interface{}{
-			"id": u.ID,
+			"id":  u.ID,
+			"org": orgID,
 		},
 	}, &refreshTokenOutput); err != nil {
 		return nil, err
@@ -45,3 +49,20 @@ func (s *Auth) authenticate(ctx context.Context, u *types.User) (*types.AuthToke
 		RefreshToken: refreshTokenOutput.Token,
 	}, nil
 }
+
+// defaultOrganization returns the organization that the user joined first, or empty if none
+func (s *Auth) defaultOrganization(ctx context.Context, userID string) (string, error) {
+	memberships, err := s.repo.Membership.FindByUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if len(memberships) == 0 {
+		return "", nil
+	}
+	return memberships[0].OrganizationID, nil
+}
+
+// isMember checks whether the user is a member of the given organization
+func (s *Auth) isMember(ctx context.Context, userID, orgID string) (bool, error) {
+	return s.repo.Membership.Exist(repoutil.WithTenant(ctx, orgID), `user_id = ?`, userID)
+}
@@ -0,0 +1,12 @@
+package job
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrJobNotFound = server.NewHTTPError(http.StatusNotFound, "JOB_NOT_FOUND", "Job not found")
+	ErrJobNotDead  = server.NewHTTPError(http.StatusConflict, "JOB_NOT_DEAD", "Only dead jobs can be retried")
+)
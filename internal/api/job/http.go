@@ -0,0 +1,140 @@
+package job
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	httputil "runar-himmel/pkg/util/http"
+	jobutil "runar-himmel/pkg/util/job"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// HTTP represents job http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents job service interface
+type Service interface {
+	List(context.Context, *repoutil.ListQueryCondition) (*ListResp, error)
+	View(context.Context, string) (*jobutil.Job, error)
+	Retry(context.Context, string) (*jobutil.Job, error)
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be restricted to superadmins.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /admin/jobs admin-jobs adminJobsList
+	// ---
+	// summary: Lists the background jobs, the newest first
+	// description: |
+	//   Filterable by `id`, `queue`, `type`, `status` (exact, in), `request_id` (exact), `run_at` and `created_at` (gte, lte),
+	//   e.g. `filter[status]=dead`. Sortable by `id`, `run_at` and `created_at`.
+	// parameters:
+	// - name: page
+	//   in: query
+	//   description: Page number for offset pagination, cursor pagination is used if omitted
+	//   type: integer
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   type: string
+	//   default: -created_at
+	// - name: cursor
+	//   in: query
+	//   description: The next_cursor or prev_cursor of the previous page, for cursor pagination
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of jobs
+	//     schema:
+	//       "$ref": "#/definitions/JobListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/jobs", h.list)
+
+	// swagger:operation GET /admin/jobs/{id} admin-jobs adminJobsView
+	// ---
+	// summary: Gets a background job
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Job ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The job
+	//     schema:
+	//       "$ref": "#/definitions/Job"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/jobs/:id", h.view)
+
+	// swagger:operation POST /admin/jobs/{id}/retry admin-jobs adminJobsRetry
+	// ---
+	// summary: Enqueues a dead job again to run now, with its attempts reset
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Job ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The job
+	//     schema:
+	//       "$ref": "#/definitions/Job"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/jobs/:id/retry", h.retry)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	lqc, err := httputil.ReqListQuery[jobutil.Job](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.List(c.Request().Context(), lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) view(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.View(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) retry(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.Retry(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
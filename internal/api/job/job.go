@@ -0,0 +1,57 @@
+package job
+
+import (
+	"context"
+	"errors"
+
+	"runar-himmel/pkg/server"
+	jobutil "runar-himmel/pkg/util/job"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// List returns the jobs matching the filters of lqc.
+// Uses offset pagination if the page is given, cursor pagination otherwise.
+func (s *Job) List(ctx context.Context, lqc *repoutil.ListQueryCondition) (*ListResp, error) {
+	var result *repoutil.ListResult[jobutil.Job]
+	var err error
+	if lqc.Page > 0 {
+		result, err = s.repo.Job.ReadAllByCondition(ctx, lqc)
+	} else {
+		result, err = s.repo.Job.ReadAllByCursor(ctx, lqc)
+	}
+	if errors.Is(err, repoutil.ErrInvalidCursor) {
+		return nil, server.NewHTTPValidationError("Invalid cursor").SetInternal(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ListResp{Data: result.Items, TotalCount: result.Total, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// View returns a job by ID
+func (s *Job) View(ctx context.Context, id string) (*jobutil.Job, error) {
+	rec := &jobutil.Job{}
+	if err := s.repo.Job.ReadByID(ctx, rec, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound.SetInternal(err)
+		}
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Retry enqueues a dead job again to run now, with its attempts reset
+func (s *Job) Retry(ctx context.Context, id string) (*jobutil.Job, error) {
+	if _, err := s.View(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Job.Retry(ctx, id); err != nil {
+		if errors.Is(err, jobutil.ErrNotDead) {
+			return nil, ErrJobNotDead.SetInternal(err)
+		}
+		return nil, err
+	}
+	return s.View(ctx, id)
+}
@@ -0,0 +1,17 @@
+package job
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new job service
+func New(repo *repo.Service) *Job {
+	return &Job{
+		repo: repo,
+	}
+}
+
+// Job represents background job application service
+type Job struct {
+	repo *repo.Service
+}
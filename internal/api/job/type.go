@@ -0,0 +1,12 @@
+package job
+
+import jobutil "runar-himmel/pkg/util/job"
+
+// ListResp represents a page of jobs
+// swagger:model JobListResp
+type ListResp struct {
+	Data       []jobutil.Job `json:"data"`
+	TotalCount int64         `json:"total_count"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+}
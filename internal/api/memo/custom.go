@@ -0,0 +1,11 @@
+package memo
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrMemoNotFound = server.NewHTTPError(http.StatusNotFound, "MEMO_NOT_FOUND", "Memo not found")
+)
@@ -0,0 +1,230 @@
+package memo
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"runar-himmel/internal/types"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// HTTP represents memo http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents memo service interface
+type Service interface {
+	List(echo.Context, *repoutil.ListQueryCondition) (*ListResp, error)
+	Create(echo.Context, CreationData) (*types.Memo, error)
+	View(echo.Context, string) (*types.Memo, error)
+	Update(echo.Context, string, int64, UpdateData) (*types.Memo, error)
+	Delete(echo.Context, string) error
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be authenticated and scoped to the current organization.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /memos memos memosList
+	// ---
+	// summary: Lists the memos of the current user, or all memos of the organization if allowed
+	// description: |
+	//   Filterable by `id`, `user_id` (exact, in), `memo` (icontains), `created_at`, `updated_at` (gte, lte, date),
+	//   e.g. `filter[user_id__in]=id1,id2`. Sortable by `id`, `created_at` and `updated_at`.
+	// parameters:
+	// - name: q
+	//   in: query
+	//   description: |
+	//     Full-text search of the memo content, all words must match as prefixes.
+	//     The results are ranked by relevance unless `sort` is given, offset pagination is always used.
+	//   type: string
+	// - name: page
+	//   in: query
+	//   description: Page number for offset pagination, cursor pagination is used if omitted
+	//   type: integer
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   type: string
+	//   description: Defaults to -created_at if not searching
+	// - name: cursor
+	//   in: query
+	//   description: The next_cursor or prev_cursor of the previous page, for cursor pagination
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of memos
+	//     schema:
+	//       "$ref": "#/definitions/MemoListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("", h.list)
+
+	// swagger:operation POST /memos memos memosCreate
+	// ---
+	// summary: Creates a new memo
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/MemoCreationData"
+	// responses:
+	//   "200":
+	//     description: The new memo
+	//     schema:
+	//       "$ref": "#/definitions/Memo"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("", h.create)
+
+	// swagger:operation GET /memos/{id} memos memosView
+	// ---
+	// summary: Gets a memo
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Memo ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The memo, with its version in the ETag header
+	//     schema:
+	//       "$ref": "#/definitions/Memo"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/:id", h.view)
+
+	// swagger:operation PATCH /memos/{id} memos memosUpdate
+	// ---
+	// summary: Updates a memo
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Memo ID
+	//   type: string
+	//   required: true
+	// - name: If-Match
+	//   in: header
+	//   description: The ETag of the memo when it was loaded, the update fails with 409 if the memo has changed since then
+	//   type: string
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/MemoUpdateData"
+	// responses:
+	//   "200":
+	//     description: The updated memo, with its new version in the ETag header
+	//     schema:
+	//       "$ref": "#/definitions/Memo"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 412, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.PATCH("/:id", h.update)
+
+	// swagger:operation DELETE /memos/{id} memos memosDelete
+	// ---
+	// summary: Deletes a memo
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Memo ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/:id", h.delete)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	lqc, err := httputil.ReqListQuery[types.Memo](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.List(c, lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) create(c echo.Context) error {
+	r := CreationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Create(c, r)
+	if err != nil {
+		return err
+	}
+	httputil.SetETag(c, int64(resp.Version))
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) view(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.View(c, id)
+	if err != nil {
+		return err
+	}
+	httputil.SetETag(c, int64(resp.Version))
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) update(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	version, err := httputil.ReqIfMatch(c)
+	if err != nil {
+		return err
+	}
+	r := UpdateData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Update(c, id, version, r)
+	if err != nil {
+		return err
+	}
+	httputil.SetETag(c, int64(resp.Version))
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) delete(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
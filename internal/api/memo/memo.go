@@ -0,0 +1,115 @@
+package memo
+
+import (
+	"errors"
+
+	"runar-himmel/internal/rbac"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+	"runar-himmel/pkg/server"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// List returns the memos that the current user is allowed to view.
+// Uses offset pagination if the page or the search query is given, cursor pagination otherwise.
+func (s *Memo) List(c echo.Context, lqc *repoutil.ListQueryCondition) (*ListResp, error) {
+	conds, err := s.ownership.Filter(rbac.CurrentSubject(c), rbac.ActionView)
+	if err != nil {
+		return nil, err
+	}
+	lqc.AddFilter(conds...)
+
+	var result *repoutil.ListResult[types.Memo]
+	// search results are ranked by relevance, which has no stable keys for cursors
+	if lqc.Page > 0 || lqc.Search != "" {
+		result, err = s.repo.Memo.ReadAllByCondition(c.Request().Context(), lqc)
+	} else {
+		result, err = s.repo.Memo.ReadAllByCursor(c.Request().Context(), lqc)
+	}
+	if errors.Is(err, repoutil.ErrInvalidCursor) {
+		return nil, server.NewHTTPValidationError("Invalid cursor").SetInternal(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ListResp{Data: result.Items, TotalCount: result.Total, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// Create creates a new memo owned by the current user
+func (s *Memo) Create(c echo.Context, data CreationData) (*types.Memo, error) {
+	sub := rbac.CurrentSubject(c)
+	if !s.enforcer.EnforceSubject(sub, rbac.ObjectMemo, rbac.ActionCreate) {
+		return nil, rbac.ErrForbiddenAction
+	}
+
+	ctx := c.Request().Context()
+	rec := &types.Memo{UserID: sub.ID, Content: data.Content}
+	if err := s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.Memo.Create(ctx, rec); err != nil {
+			return err
+		}
+		return tx.Outbox.Emit(ctx, types.EventMemoCreated, rec.ID, types.NewMemoEvent(rec))
+	}); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// View returns a memo by ID
+func (s *Memo) View(c echo.Context, id string) (*types.Memo, error) {
+	return s.authorize(c, rbac.ActionView, id)
+}
+
+// Update updates the content of a memo.
+// The update fails with a version conflict if the memo has changed since the given version,
+// or since it was loaded if the version is zero.
+func (s *Memo) Update(c echo.Context, id string, version int64, data UpdateData) (*types.Memo, error) {
+	rec, err := s.authorize(c, rbac.ActionUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		version = int64(rec.Version)
+	}
+
+	ctx := c.Request().Context()
+	if err := s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.Memo.UpdateWithVersion(ctx, version, map[string]any{"content": data.Content}, id); err != nil {
+			return err
+		}
+		rec.Content = data.Content
+		rec.Version = repoutil.Version(version + 1)
+		return tx.Outbox.Emit(ctx, types.EventMemoUpdated, rec.ID, types.NewMemoEvent(rec))
+	}); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Delete deletes a memo
+func (s *Memo) Delete(c echo.Context, id string) error {
+	rec, err := s.authorize(c, rbac.ActionDelete, id)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	return s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.Memo.Delete(ctx, id); err != nil {
+			return err
+		}
+		rec.Content = ""
+		return tx.Outbox.Emit(ctx, types.EventMemoDeleted, rec.ID, types.NewMemoEvent(rec))
+	})
+}
+
+// authorize loads the memo and checks whether the current user is allowed to do the action on it
+func (s *Memo) authorize(c echo.Context, act, id string) (*types.Memo, error) {
+	rec, err := s.ownership.Authorize(c.Request().Context(), rbac.CurrentSubject(c), act, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMemoNotFound.SetInternal(err)
+	}
+	return rec, err
+}
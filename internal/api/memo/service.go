@@ -0,0 +1,25 @@
+package memo
+
+import (
+	"runar-himmel/internal/rbac"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+
+	rbacutil "runar-himmel/pkg/rbac"
+)
+
+// New creates new memo service
+func New(repo *repo.Service, enforcer rbacutil.SubjectEnforcer) *Memo {
+	return &Memo{
+		repo:      repo,
+		enforcer:  enforcer,
+		ownership: rbacutil.NewOwnership[types.Memo](repo.Memo.Repo, enforcer, rbac.ObjectMemo),
+	}
+}
+
+// Memo represents memo application service
+type Memo struct {
+	repo      *repo.Service
+	enforcer  rbacutil.SubjectEnforcer
+	ownership *rbacutil.Ownership[types.Memo]
+}
@@ -0,0 +1,26 @@
+package memo
+
+import "runar-himmel/internal/types"
+
+// CreationData represents memo creation data
+// swagger:model MemoCreationData
+type CreationData struct {
+	// example: Feed Huginn and Muninn
+	Content string `json:"memo" validate:"required"`
+}
+
+// UpdateData represents memo update data
+// swagger:model MemoUpdateData
+type UpdateData struct {
+	// example: Feed Huginn and Muninn, twice
+	Content string `json:"memo" validate:"required"`
+}
+
+// ListResp represents a page of memos
+// swagger:model MemoListResp
+type ListResp struct {
+	Data       []types.Memo `json:"data"`
+	TotalCount int64        `json:"total_count"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+}
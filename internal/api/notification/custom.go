@@ -0,0 +1,13 @@
+package notification
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrDeviceNotFound   = server.NewHTTPError(http.StatusNotFound, "DEVICE_NOT_FOUND", "Device not found")
+	ErrTopicNotFound    = server.NewHTTPError(http.StatusNotFound, "TOPIC_NOT_FOUND", "Topic not found")
+	ErrPlatformDisabled = server.NewHTTPError(http.StatusBadRequest, "PLATFORM_DISABLED", "Push notifications are not available on the platform")
+)
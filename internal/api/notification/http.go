@@ -0,0 +1,193 @@
+package notification
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	httputil "runar-himmel/pkg/util/http"
+	notifyutil "runar-himmel/pkg/util/notify"
+)
+
+// HTTP represents notification http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents notification service interface
+type Service interface {
+	ListDevices(echo.Context) ([]notifyutil.Device, error)
+	RegisterDevice(echo.Context, DeviceData) (*notifyutil.Device, error)
+	DeleteDevice(echo.Context, string) error
+	ListTopics(echo.Context) ([]TopicResp, error)
+	Subscribe(echo.Context, string) error
+	Unsubscribe(echo.Context, string) error
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be authenticated, the current user is identified by the `id` context key.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /notifications/devices notifications notificationsDevicesList
+	// ---
+	// summary: Lists the devices of the current user receiving the push notifications
+	// responses:
+	//   "200":
+	//     description: List of devices
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/Device"
+	//   default:
+	//     description: 'Possible errors: 401, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/devices", h.listDevices)
+
+	// swagger:operation POST /notifications/devices notifications notificationsDevicesRegister
+	// ---
+	// summary: Registers a device of the current user, or returns it if already registered
+	// description: |
+	//   The device is subscribed to the topics of the user. A device registered by another user is moved to the current user.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/NotificationDeviceData"
+	// responses:
+	//   "200":
+	//     description: The device
+	//     schema:
+	//       "$ref": "#/definitions/Device"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/devices", h.registerDevice)
+
+	// swagger:operation DELETE /notifications/devices/{id} notifications notificationsDevicesDelete
+	// ---
+	// summary: Deregisters a device of the current user, e.g. on logging out
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Device ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/devices/:id", h.deleteDevice)
+
+	// swagger:operation GET /notifications/topics notifications notificationsTopicsList
+	// ---
+	// summary: Lists the available topics, along with whether the current user subscribed to them
+	// responses:
+	//   "200":
+	//     description: List of topics
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/NotificationTopicResp"
+	//   default:
+	//     description: 'Possible errors: 401, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/topics", h.listTopics)
+
+	// swagger:operation PUT /notifications/topics/{name} notifications notificationsTopicsSubscribe
+	// ---
+	// summary: Subscribes the current user to a topic, including all devices of the user
+	// parameters:
+	// - name: name
+	//   in: path
+	//   description: Topic name
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 401, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.PUT("/topics/:name", h.subscribe)
+
+	// swagger:operation DELETE /notifications/topics/{name} notifications notificationsTopicsUnsubscribe
+	// ---
+	// summary: Unsubscribes the current user from a topic
+	// parameters:
+	// - name: name
+	//   in: path
+	//   description: Topic name
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 401, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/topics/:name", h.unsubscribe)
+}
+
+func (h *HTTP) listDevices(c echo.Context) error {
+	resp, err := h.svc.ListDevices(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) registerDevice(c echo.Context) error {
+	r := DeviceData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.RegisterDevice(c, r)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) deleteDevice(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.DeleteDevice(c, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) listTopics(c echo.Context) error {
+	resp, err := h.svc.ListTopics(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) subscribe(c echo.Context) error {
+	if err := h.svc.Subscribe(c, c.Param("name")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) unsubscribe(c echo.Context) error {
+	if err := h.svc.Unsubscribe(c, c.Param("name")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
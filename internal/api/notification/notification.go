@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"errors"
+
+	notifyutil "runar-himmel/pkg/util/notify"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+// ListDevices returns the devices of the current user
+func (s *Notification) ListDevices(c echo.Context) ([]notifyutil.Device, error) {
+	return s.notify.Devices(c.Request().Context(), currentUserID(c))
+}
+
+// RegisterDevice registers a device of the current user, it is subscribed to the topics of the user
+func (s *Notification) RegisterDevice(c echo.Context, data DeviceData) (*notifyutil.Device, error) {
+	d, err := s.notify.Register(c.Request().Context(), currentUserID(c), data.Platform, data.Token)
+	if errors.Is(err, notifyutil.ErrPlatformDisabled) || errors.Is(err, notifyutil.ErrInvalidPlatform) {
+		return nil, ErrPlatformDisabled
+	}
+	return d, err
+}
+
+// DeleteDevice deregisters a device of the current user
+func (s *Notification) DeleteDevice(c echo.Context, id string) error {
+	err := s.notify.Unregister(c.Request().Context(), currentUserID(c), id)
+	if errors.Is(err, notifyutil.ErrDeviceNotFound) {
+		return ErrDeviceNotFound
+	}
+	return err
+}
+
+// ListTopics returns the available topics, along with whether the current user subscribed to them
+func (s *Notification) ListTopics(c echo.Context) ([]TopicResp, error) {
+	subscribed, err := s.notify.Topics(c.Request().Context(), currentUserID(c))
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(s.notify.AvailableTopics(), func(name string, _ int) TopicResp {
+		return TopicResp{Name: name, Subscribed: lo.Contains(subscribed, name)}
+	}), nil
+}
+
+// Subscribe subscribes the current user to a topic, including all devices of the user
+func (s *Notification) Subscribe(c echo.Context, name string) error {
+	err := s.notify.Subscribe(c.Request().Context(), currentUserID(c), name)
+	if errors.Is(err, notifyutil.ErrUnknownTopic) {
+		return ErrTopicNotFound
+	}
+	return err
+}
+
+// Unsubscribe unsubscribes the current user from a topic
+func (s *Notification) Unsubscribe(c echo.Context, name string) error {
+	err := s.notify.Unsubscribe(c.Request().Context(), currentUserID(c), name)
+	if errors.Is(err, notifyutil.ErrUnknownTopic) {
+		return ErrTopicNotFound
+	}
+	return err
+}
+
+func currentUserID(c echo.Context) string {
+	id, _ := c.Get("id").(string)
+	return id
+}
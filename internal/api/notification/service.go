@@ -0,0 +1,17 @@
+package notification
+
+import (
+	notifyutil "runar-himmel/pkg/util/notify"
+)
+
+// New creates new notification service
+func New(notify *notifyutil.Service) *Notification {
+	return &Notification{
+		notify: notify,
+	}
+}
+
+// Notification represents the push notification application service, managing the devices & topics of the current user
+type Notification struct {
+	notify *notifyutil.Service
+}
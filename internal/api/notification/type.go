@@ -0,0 +1,19 @@
+package notification
+
+// DeviceData represents device registration data
+// swagger:model NotificationDeviceData
+type DeviceData struct {
+	// example: ios
+	Platform string `json:"platform" validate:"required,oneof=ios android"`
+	// The APNS or FCM token of the device
+	// example: 740f4707bebcf74f9b7c25d48e3358945f6aa01da5ddb387462c7eaf61bb78ad
+	Token string `json:"token" validate:"required,max=255"`
+}
+
+// TopicResp represents a topic, whether the current user subscribed to it
+// swagger:model NotificationTopicResp
+type TopicResp struct {
+	// example: news
+	Name       string `json:"name"`
+	Subscribed bool   `json:"subscribed"`
+}
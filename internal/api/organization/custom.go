@@ -0,0 +1,14 @@
+package organization
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrOrganizationNotFound = server.NewHTTPError(http.StatusNotFound, "ORGANIZATION_NOT_FOUND", "Organization not found")
+	ErrUserNotFound         = server.NewHTTPError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	ErrMemberExisted        = server.NewHTTPError(http.StatusConflict, "MEMBER_EXISTED", "The user is already a member of the organization")
+	ErrMemberNotFound       = server.NewHTTPError(http.StatusNotFound, "MEMBER_NOT_FOUND", "The user is not a member of the organization")
+)
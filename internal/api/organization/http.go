@@ -0,0 +1,200 @@
+package organization
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"runar-himmel/internal/types"
+	"runar-himmel/pkg/server/middleware/tenant"
+	httputil "runar-himmel/pkg/util/http"
+)
+
+// HTTP represents organization http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents organization service interface
+type Service interface {
+	List(echo.Context) ([]*types.Organization, error)
+	Create(echo.Context, CreationData) (*types.Organization, error)
+	Current(echo.Context) (*types.Organization, error)
+	ListMembers(echo.Context) ([]types.Membership, error)
+	AddMember(echo.Context, MemberData) (*types.Membership, error)
+	RemoveMember(echo.Context, string) error
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be authenticated by the JWT middleware.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /organizations organizations organizationsList
+	// ---
+	// summary: Lists all organizations of the current user
+	// responses:
+	//   "200":
+	//     description: List of organizations
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/Organization"
+	//   default:
+	//     description: 'Possible errors: 401, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("", h.list)
+
+	// swagger:operation POST /organizations organizations organizationsCreate
+	// ---
+	// summary: Creates a new organization, the current user becomes its admin
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/OrganizationCreationData"
+	// responses:
+	//   "200":
+	//     description: The new organization
+	//     schema:
+	//       "$ref": "#/definitions/Organization"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("", h.create)
+
+	// the current organization, selected by the access token
+	cg := eg.Group("/current", tenant.Middleware("org"))
+
+	// swagger:operation GET /organizations/current organizations organizationsCurrent
+	// ---
+	// summary: Gets the current organization
+	// responses:
+	//   "200":
+	//     description: The current organization
+	//     schema:
+	//       "$ref": "#/definitions/Organization"
+	//   default:
+	//     description: 'Possible errors: 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	cg.GET("", h.current)
+
+	// swagger:operation GET /organizations/current/members organizations organizationsMembersList
+	// ---
+	// summary: Lists all members of the current organization
+	// responses:
+	//   "200":
+	//     description: List of members
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/Membership"
+	//   default:
+	//     description: 'Possible errors: 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	cg.GET("/members", h.listMembers)
+
+	// swagger:operation POST /organizations/current/members organizations organizationsMembersCreate
+	// ---
+	// summary: Adds a user into the current organization
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/MemberData"
+	// responses:
+	//   "200":
+	//     description: The new membership
+	//     schema:
+	//       "$ref": "#/definitions/Membership"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	cg.POST("/members", h.addMember)
+
+	// swagger:operation DELETE /organizations/current/members/{id} organizations organizationsMembersDelete
+	// ---
+	// summary: Removes a user from the current organization
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	cg.DELETE("/members/:id", h.removeMember)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	resp, err := h.svc.List(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) create(c echo.Context) error {
+	r := CreationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Create(c, r)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) current(c echo.Context) error {
+	resp, err := h.svc.Current(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) listMembers(c echo.Context) error {
+	resp, err := h.svc.ListMembers(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) addMember(c echo.Context) error {
+	r := MemberData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.AddMember(c, r)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) removeMember(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.RemoveMember(c, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package organization
+
+import (
+	"runar-himmel/internal/rbac"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"github.com/labstack/echo/v4"
+)
+
+// List returns all organizations that the current user is a member of
+func (s *Organization) List(c echo.Context) ([]*types.Organization, error) {
+	ctx := c.Request().Context()
+	memberships, err := s.repo.Membership.FindByUser(ctx, currentUserID(c))
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := []types.Organization{}
+	if len(memberships) > 0 {
+		ids := make([]string, 0, len(memberships))
+		for _, m := range memberships {
+			ids = append(ids, m.OrganizationID)
+		}
+		if err := s.repo.Organization.ReadAll(ctx, &orgs, `id IN ?`, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := make([]*types.Organization, 0, len(orgs))
+	for i := range orgs {
+		resp = append(resp, &orgs[i])
+	}
+	return resp, nil
+}
+
+// Create creates a new organization, the current user becomes its admin
+func (s *Organization) Create(c echo.Context, data CreationData) (*types.Organization, error) {
+	uid := currentUserID(c)
+	org := &types.Organization{Name: data.Name}
+	if err := s.repo.Transaction(c.Request().Context(), func(tx *repo.Service) error {
+		if err := tx.Organization.Create(c.Request().Context(), org); err != nil {
+			return err
+		}
+		ctx := repoutil.WithTenant(c.Request().Context(), org.ID)
+		if err := tx.Membership.Create(ctx, &types.Membership{UserID: uid, Role: rbac.RoleAdmin}); err != nil {
+			return err
+		}
+		repoutil.AfterCommit(tx.Membership.DB(ctx), func() {
+			s.rbac.AddRoleForUserIDInDomain(uid, rbac.RoleAdmin, org.ID)
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// Current returns the organization of the current access token
+func (s *Organization) Current(c echo.Context) (*types.Organization, error) {
+	org := &types.Organization{}
+	if err := s.repo.Organization.ReadByID(c.Request().Context(), org, currentOrgID(c)); err != nil {
+		return nil, ErrOrganizationNotFound.SetInternal(err)
+	}
+	return org, nil
+}
+
+// ListMembers returns all members of the current organization
+func (s *Organization) ListMembers(c echo.Context) ([]types.Membership, error) {
+	members := []types.Membership{}
+	if err := s.repo.Membership.ReadAll(c.Request().Context(), &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMember adds a user into the current organization. Only organization admins are allowed.
+func (s *Organization) AddMember(c echo.Context, data MemberData) (*types.Membership, error) {
+	ctx := c.Request().Context()
+	orgID := currentOrgID(c)
+	if !s.rbac.HasRoleForUserIDInDomain(currentUserID(c), rbac.RoleAdmin, orgID) {
+		return nil, rbac.ErrForbiddenAction
+	}
+
+	if existed, err := s.repo.User.Exist(ctx, data.UserID); err != nil {
+		return nil, err
+	} else if !existed {
+		return nil, ErrUserNotFound
+	}
+	if existed, err := s.repo.Membership.Exist(ctx, `user_id = ?`, data.UserID); err != nil {
+		return nil, err
+	} else if existed {
+		return nil, ErrMemberExisted
+	}
+
+	member := &types.Membership{UserID: data.UserID, Role: data.Role}
+	if err := s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.Membership.Create(ctx, member); err != nil {
+			return err
+		}
+		// the RBAC rules follow the membership once committed
+		repoutil.AfterCommit(tx.Membership.DB(ctx), func() {
+			s.rbac.AddRoleForUserIDInDomain(data.UserID, data.Role, orgID)
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// RemoveMember removes a user from the current organization. Only organization admins are allowed.
+func (s *Organization) RemoveMember(c echo.Context, userID string) error {
+	ctx := c.Request().Context()
+	orgID := currentOrgID(c)
+	if !s.rbac.HasRoleForUserIDInDomain(currentUserID(c), rbac.RoleAdmin, orgID) {
+		return rbac.ErrForbiddenAction
+	}
+
+	if existed, err := s.repo.Membership.Exist(ctx, `user_id = ?`, userID); err != nil {
+		return err
+	} else if !existed {
+		return ErrMemberNotFound
+	}
+
+	return s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.Membership.Delete(ctx, `user_id = ?`, userID); err != nil {
+			return err
+		}
+		repoutil.AfterCommit(tx.Membership.DB(ctx), func() {
+			s.rbac.DeleteRolesForUserIDInDomain(userID, orgID)
+		})
+		return nil
+	})
+}
+
+func currentUserID(c echo.Context) string {
+	id, _ := c.Get("id").(string)
+	return id
+}
+
+func currentOrgID(c echo.Context) string {
+	id, _ := c.Get("org").(string)
+	return id
+}
@@ -0,0 +1,26 @@
+package organization
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new organization service
+func New(repo *repo.Service, rbac RBAC) *Organization {
+	return &Organization{
+		repo: repo,
+		rbac: rbac,
+	}
+}
+
+// Organization represents organization application service
+type Organization struct {
+	repo *repo.Service
+	rbac RBAC
+}
+
+// RBAC represents the domain-aware role management interface of the RBAC enforcer
+type RBAC interface {
+	AddRoleForUserIDInDomain(uid string, role, domain string) bool
+	DeleteRolesForUserIDInDomain(uid string, domain string) bool
+	HasRoleForUserIDInDomain(uid string, role, domain string) bool
+}
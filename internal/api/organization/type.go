@@ -0,0 +1,17 @@
+package organization
+
+// CreationData represents organization creation data
+// swagger:model OrganizationCreationData
+type CreationData struct {
+	// example: Asgard Inc.
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// MemberData represents membership request data
+// swagger:model
+type MemberData struct {
+	// example: 01HKQ8ZJ4N0S7V2DXP5W1Y3T6M
+	UserID string `json:"user_id" validate:"required"`
+	// example: customer
+	Role string `json:"role" validate:"required,oneof=admin customer"`
+}
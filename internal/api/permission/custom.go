@@ -0,0 +1,18 @@
+package permission
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrPolicyExisted      = server.NewHTTPError(http.StatusConflict, "POLICY_EXISTED", "The policy already exists")
+	ErrPolicyNotFound     = server.NewHTTPError(http.StatusNotFound, "POLICY_NOT_FOUND", "The policy does not exist")
+	ErrInheritanceExisted = server.NewHTTPError(http.StatusConflict, "INHERITANCE_EXISTED", "The role already inherits the given role")
+	ErrInheritanceInvalid = server.NewHTTPError(http.StatusBadRequest, "INHERITANCE_INVALID", "A role cannot inherit itself")
+	ErrInheritanceMissing = server.NewHTTPError(http.StatusNotFound, "INHERITANCE_NOT_FOUND", "The role does not inherit the given role")
+	ErrMemberNotFound     = server.NewHTTPError(http.StatusNotFound, "MEMBER_NOT_FOUND", "The user is not a member of the organization")
+	ErrUserRoleExisted    = server.NewHTTPError(http.StatusConflict, "USER_ROLE_EXISTED", "The user already has the role")
+	ErrUserRoleNotFound   = server.NewHTTPError(http.StatusNotFound, "USER_ROLE_NOT_FOUND", "The user does not have the role")
+)
@@ -0,0 +1,409 @@
+package permission
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+
+	"runar-himmel/internal/rbac"
+	"runar-himmel/pkg/server"
+	httputil "runar-himmel/pkg/util/http"
+)
+
+// HTTP represents permission http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents permission service interface
+type Service interface {
+	ListRoles(echo.Context, string) ([]*Role, error)
+	AddRoleInheritance(echo.Context, RoleInheritanceData) error
+	RemoveRoleInheritance(echo.Context, RoleInheritanceData) error
+	ListPolicies(echo.Context, string, string) ([]*Policy, error)
+	AddPolicy(echo.Context, Policy) error
+	RemovePolicy(echo.Context, Policy) error
+	ListUserRoles(echo.Context, string, string) (*UserRoles, error)
+	AddUserRole(echo.Context, string, UserRoleData) error
+	RemoveUserRole(echo.Context, string, string, string) error
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be restricted to superadmins.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /admin/rbac/roles admin-rbac adminRbacRolesList
+	// ---
+	// summary: Lists all roles and their inheritance inside an organization
+	// parameters:
+	// - name: domain
+	//   in: query
+	//   description: The organization ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: List of roles
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/Role"
+	//   default:
+	//     description: 'Possible errors: 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/rbac/roles", h.listRoles)
+
+	// swagger:operation POST /admin/rbac/roles admin-rbac adminRbacRolesInherit
+	// ---
+	// summary: Makes a role inherit all permissions of another role
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/RoleInheritanceData"
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 409, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/rbac/roles", h.addRoleInheritance)
+
+	// swagger:operation DELETE /admin/rbac/roles admin-rbac adminRbacRolesUninherit
+	// ---
+	// summary: Removes the inheritance between two roles
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/RoleInheritanceData"
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/rbac/roles", h.removeRoleInheritance)
+
+	// swagger:operation GET /admin/rbac/policies admin-rbac adminRbacPoliciesList
+	// ---
+	// summary: Lists all policies
+	// parameters:
+	// - name: role
+	//   in: query
+	//   description: Only returns the policies of this role
+	//   type: string
+	// - name: domain
+	//   in: query
+	//   description: Only returns the policies of this domain, `*` for the ones applied to all organizations
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of policies
+	//     schema:
+	//       type: array
+	//       items:
+	//         "$ref": "#/definitions/Policy"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/rbac/policies", h.listPolicies)
+
+	// swagger:operation POST /admin/rbac/policies admin-rbac adminRbacPoliciesCreate
+	// ---
+	// summary: Adds a new policy
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Policy"
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 409, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/rbac/policies", h.addPolicy)
+
+	// swagger:operation DELETE /admin/rbac/policies admin-rbac adminRbacPoliciesDelete
+	// ---
+	// summary: Removes an existing policy
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Policy"
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/rbac/policies", h.removePolicy)
+
+	// swagger:operation GET /admin/users/{id}/roles admin-rbac adminUserRolesList
+	// ---
+	// summary: Lists the roles assigned to a user inside an organization
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// - name: domain
+	//   in: query
+	//   description: The organization ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The user roles
+	//     schema:
+	//       "$ref": "#/definitions/UserRoles"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/users/:id/roles", h.listUserRoles)
+
+	// swagger:operation POST /admin/users/{id}/roles admin-rbac adminUserRolesCreate
+	// ---
+	// summary: Assigns a role to a user inside an organization
+	// description: The role replaces the one of the membership, see the members of the organization.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/UserRoleData"
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/users/:id/roles", h.addUserRole)
+
+	// swagger:operation DELETE /admin/users/{id}/roles/{role} admin-rbac adminUserRolesDelete
+	// ---
+	// summary: Revokes a role from a user inside an organization
+	// description: The user stays a member of the organization without role.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// - name: role
+	//   in: path
+	//   description: Role name
+	//   type: string
+	//   required: true
+	// - name: domain
+	//   in: query
+	//   description: The organization ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/users/:id/roles/:role", h.removeUserRole)
+}
+
+func (h *HTTP) listRoles(c echo.Context) error {
+	domain, err := reqDomain(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.ListRoles(c, domain)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) addRoleInheritance(c echo.Context) error {
+	r := RoleInheritanceData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := validateRoles(r.Role, r.Inherits); err != nil {
+		return err
+	}
+	if err := h.svc.AddRoleInheritance(c, r); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) removeRoleInheritance(c echo.Context) error {
+	r := RoleInheritanceData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := validateRoles(r.Role, r.Inherits); err != nil {
+		return err
+	}
+	if err := h.svc.RemoveRoleInheritance(c, r); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) listPolicies(c echo.Context) error {
+	role := c.QueryParam("role")
+	if role != "" {
+		if err := validateRoles(role); err != nil {
+			return err
+		}
+	}
+	resp, err := h.svc.ListPolicies(c, role, c.QueryParam("domain"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) addPolicy(c echo.Context) error {
+	r := Policy{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := validatePolicy(&r); err != nil {
+		return err
+	}
+	if err := h.svc.AddPolicy(c, r); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) removePolicy(c echo.Context) error {
+	r := Policy{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := validatePolicy(&r); err != nil {
+		return err
+	}
+	if err := h.svc.RemovePolicy(c, r); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) listUserRoles(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	domain, err := reqDomain(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.ListUserRoles(c, id, domain)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) addUserRole(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	r := UserRoleData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := validateRoles(r.Role); err != nil {
+		return err
+	}
+	if err := h.svc.AddUserRole(c, id, r); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) removeUserRole(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	domain, err := reqDomain(c)
+	if err != nil {
+		return err
+	}
+	role := c.Param("role")
+	if err := validateRoles(role); err != nil {
+		return err
+	}
+	if err := h.svc.RemoveUserRole(c, id, role, domain); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// reqDomain returns the required domain query param
+func reqDomain(c echo.Context) (string, error) {
+	domain := c.QueryParam("domain")
+	if domain == "" {
+		return "", server.NewHTTPValidationError("domain is required, but was not received")
+	}
+	return domain, nil
+}
+
+// validateRoles checks the given roles against the RBAC catalog
+func validateRoles(roles ...string) error {
+	for _, r := range roles {
+		if !lo.Contains(rbac.ValidRoles, r) {
+			return server.NewHTTPValidationError("Invalid role: " + r)
+		}
+	}
+	return nil
+}
+
+// validatePolicy checks the given policy against the RBAC catalog
+func validatePolicy(p *Policy) error {
+	if p.Domain == "" {
+		p.Domain = rbac.DomainAny
+	}
+	if err := validateRoles(p.Role); err != nil {
+		return err
+	}
+	if !lo.Contains(rbac.ValidObjects, p.Object) {
+		return server.NewHTTPValidationError("Invalid object: " + p.Object)
+	}
+	if !lo.Contains(rbac.ValidActions, p.Action) {
+		return server.NewHTTPValidationError("Invalid action: " + p.Action)
+	}
+	return nil
+}
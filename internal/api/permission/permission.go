@@ -0,0 +1,164 @@
+package permission
+
+import (
+	"context"
+	"errors"
+
+	"runar-himmel/internal/rbac"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ListRoles returns all valid roles along with the roles they inherit from inside the given domain
+func (s *Permission) ListRoles(_ echo.Context, domain string) ([]*Role, error) {
+	roles := make([]*Role, 0, len(rbac.ValidRoles))
+	for _, name := range rbac.ValidRoles {
+		inherits := s.rbac.GetRolesForUserInDomain(name, domain)
+		if inherits == nil {
+			inherits = []string{}
+		}
+		roles = append(roles, &Role{Name: name, Domain: domain, Inherits: inherits})
+	}
+	return roles, nil
+}
+
+// AddRoleInheritance makes a role inherit all permissions of another role inside a domain
+func (s *Permission) AddRoleInheritance(_ echo.Context, data RoleInheritanceData) error {
+	if data.Role == data.Inherits {
+		return ErrInheritanceInvalid
+	}
+	if !s.rbac.AddGroupingPolicy(data.Role, data.Inherits, data.Domain) {
+		return ErrInheritanceExisted
+	}
+	return nil
+}
+
+// RemoveRoleInheritance removes the inheritance between two roles inside a domain
+func (s *Permission) RemoveRoleInheritance(_ echo.Context, data RoleInheritanceData) error {
+	if !s.rbac.RemoveGroupingPolicy(data.Role, data.Inherits, data.Domain) {
+		return ErrInheritanceMissing
+	}
+	return nil
+}
+
+// ListPolicies returns all policies, optionally filtered by role and domain
+func (s *Permission) ListPolicies(_ echo.Context, role, domain string) ([]*Policy, error) {
+	var rules [][]string
+	if role != "" || domain != "" {
+		rules = s.rbac.GetFilteredPolicy(0, role, domain)
+	} else {
+		rules = s.rbac.GetPolicy()
+	}
+
+	policies := make([]*Policy, 0, len(rules))
+	for _, r := range rules {
+		if len(r) < 4 {
+			continue
+		}
+		policies = append(policies, &Policy{Role: r[0], Domain: r[1], Object: r[2], Action: r[3]})
+	}
+	return policies, nil
+}
+
+// AddPolicy adds a new policy
+func (s *Permission) AddPolicy(_ echo.Context, data Policy) error {
+	if !s.rbac.AddPolicy(data.Role, data.Domain, data.Object, data.Action) {
+		return ErrPolicyExisted
+	}
+	return nil
+}
+
+// RemovePolicy removes an existing policy
+func (s *Permission) RemovePolicy(_ echo.Context, data Policy) error {
+	if !s.rbac.RemovePolicy(data.Role, data.Domain, data.Object, data.Action) {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+// ListUserRoles returns the roles assigned to the given user inside a domain
+func (s *Permission) ListUserRoles(c echo.Context, userID, domain string) (*UserRoles, error) {
+	if err := s.ensureMemberExists(c, userID, domain); err != nil {
+		return nil, err
+	}
+
+	roles := s.rbac.GetRolesForUserIDInDomain(userID, domain)
+	if roles == nil {
+		roles = []string{}
+	}
+	return &UserRoles{UserID: userID, Domain: domain, Roles: roles}, nil
+}
+
+// AddUserRole assigns a role to the given user inside a domain, replacing the role of the membership.
+// The RBAC rules of the user follow the membership once committed.
+func (s *Permission) AddUserRole(c echo.Context, userID string, data UserRoleData) error {
+	ctx := repoutil.WithTenant(c.Request().Context(), data.Domain)
+	return s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		member, err := readMember(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if member.Role == data.Role {
+			return ErrUserRoleExisted
+		}
+		if err := tx.Membership.Update(ctx, map[string]any{"role": data.Role}, `user_id = ?`, userID); err != nil {
+			return err
+		}
+		repoutil.AfterCommit(tx.Membership.DB(ctx), func() {
+			s.rbac.DeleteRolesForUserIDInDomain(userID, data.Domain)
+			s.rbac.AddRoleForUserIDInDomain(userID, data.Role, data.Domain)
+		})
+		return nil
+	})
+}
+
+// RemoveUserRole revokes the role of the membership from the given user inside a domain, the user stays a member without role
+func (s *Permission) RemoveUserRole(c echo.Context, userID, role, domain string) error {
+	ctx := repoutil.WithTenant(c.Request().Context(), domain)
+	return s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		member, err := readMember(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if member.Role != role {
+			return ErrUserRoleNotFound
+		}
+		if err := tx.Membership.Update(ctx, map[string]any{"role": ""}, `user_id = ?`, userID); err != nil {
+			return err
+		}
+		repoutil.AfterCommit(tx.Membership.DB(ctx), func() {
+			s.rbac.DeleteRoleForUserIDInDomain(userID, role, domain)
+		})
+		return nil
+	})
+}
+
+// readMember reads the membership of the user in the organization (tenant) of ctx
+func readMember(ctx context.Context, tx *repo.Service, userID string) (*types.Membership, error) {
+	member := &types.Membership{}
+	if err := tx.Membership.Read(ctx, member, `user_id = ?`, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// ensureMemberExists checks whether the user is a member of the organization (domain)
+func (s *Permission) ensureMemberExists(c echo.Context, userID, domain string) error {
+	ctx := repoutil.WithTenant(c.Request().Context(), domain)
+	existed, err := s.repo.Membership.Exist(ctx, `user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return ErrMemberNotFound
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package permission
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new permission service
+func New(repo *repo.Service, rbac RBAC) *Permission {
+	return &Permission{
+		repo: repo,
+		rbac: rbac,
+	}
+}
+
+// Permission represents permission application service
+type Permission struct {
+	repo *repo.Service
+	rbac RBAC
+}
+
+// RBAC represents the policy management interface of the RBAC enforcer
+type RBAC interface {
+	GetPolicy() [][]string
+	GetFilteredPolicy(fieldIndex int, fieldValues ...string) [][]string
+	AddPolicy(params ...interface{}) bool
+	RemovePolicy(params ...interface{}) bool
+	AddGroupingPolicy(params ...interface{}) bool
+	RemoveGroupingPolicy(params ...interface{}) bool
+	GetRolesForUserInDomain(name string, domain string) []string
+	GetRolesForUserIDInDomain(uid string, domain string) []string
+	AddRoleForUserIDInDomain(uid string, role, domain string) bool
+	DeleteRoleForUserIDInDomain(uid string, role, domain string) bool
+	DeleteRolesForUserIDInDomain(uid string, domain string) bool
+}
@@ -0,0 +1,56 @@
+package permission
+
+// Role represents a role and the roles it inherits permissions from inside a domain
+// swagger:model
+type Role struct {
+	// example: admin
+	Name string `json:"name"`
+	// example: 01HKQ8ZJ4N0S7V2DXP5W1Y3T6M
+	Domain string `json:"domain"`
+	// example: ["customer"]
+	Inherits []string `json:"inherits"`
+}
+
+// Policy represents a permission rule that allows a role to perform an action on an object
+// swagger:model
+type Policy struct {
+	// example: admin
+	Role string `json:"role" validate:"required"`
+	// The organization ID, or `*` for all organizations
+	// example: *
+	Domain string `json:"domain"`
+	// example: user
+	Object string `json:"object" validate:"required"`
+	// example: view_all
+	Action string `json:"action" validate:"required"`
+}
+
+// RoleInheritanceData represents role inheritance request data
+// swagger:model
+type RoleInheritanceData struct {
+	// example: admin
+	Role string `json:"role" validate:"required"`
+	// example: customer
+	Inherits string `json:"inherits" validate:"required"`
+	// The organization ID
+	// example: 01HKQ8ZJ4N0S7V2DXP5W1Y3T6M
+	Domain string `json:"domain" validate:"required"`
+}
+
+// UserRoleData represents user role request data
+// swagger:model
+type UserRoleData struct {
+	// example: admin
+	Role string `json:"role" validate:"required"`
+	// The organization ID
+	// example: 01HKQ8ZJ4N0S7V2DXP5W1Y3T6M
+	Domain string `json:"domain" validate:"required"`
+}
+
+// UserRoles represents the roles assigned to a user inside a domain
+// swagger:model
+type UserRoles struct {
+	UserID string   `json:"user_id"`
+	Domain string   `json:"domain"`
+	Roles  []string `json:"roles"`
+}
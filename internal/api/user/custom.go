@@ -0,0 +1,13 @@
+package user
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrUnsupportedFormat = server.NewHTTPError(http.StatusBadRequest, "UNSUPPORTED_FORMAT", "The format must be either csv or ndjson")
+	ErrInvalidFile       = server.NewHTTPError(http.StatusBadRequest, "INVALID_FILE", "The file cannot be imported")
+	ErrUserNotFound      = server.NewHTTPError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+)
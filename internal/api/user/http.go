@@ -0,0 +1,301 @@
+package user
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"runar-himmel/internal/types"
+	"runar-himmel/pkg/server"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// HTTP represents user http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents user service interface
+type Service interface {
+	List(context.Context, *repoutil.ListQueryCondition) (*ListResp, error)
+	Import(context.Context, io.Reader, repoutil.ImportOptions) (*ImportResp, error)
+	Export(context.Context, io.Writer, string, *repoutil.ListQueryCondition) (int, error)
+	Block(context.Context, string, int64) (*types.User, error)
+	Unblock(context.Context, string, int64) (*types.User, error)
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be restricted to superadmins.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /admin/users admin-users adminUsersList
+	// ---
+	// summary: Lists the users matching the filters or the search query
+	// description: |
+	//   Filterable by `first_name`, `last_name`, `email` (exact, icontains), `phone` (exact), `role`, `status` (exact, in),
+	//   e.g. `filter[role__in]=admin,customer`. Sortable by `first_name`, `last_name`, `email` and `created_at`.
+	// parameters:
+	// - name: q
+	//   in: query
+	//   description: |
+	//     Full-text search of the names, email & phone, all words must match as prefixes.
+	//     The results are ranked by relevance unless `sort` is given.
+	//   type: string
+	// - name: page
+	//   in: query
+	//   type: integer
+	//   default: 1
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   description: Defaults to -created_at if not searching
+	//   type: string
+	// - name: filter[field__operator]
+	//   in: query
+	//   description: Filters, e.g. `filter[status]=active`
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of users
+	//     schema:
+	//       "$ref": "#/definitions/UserListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/users", h.list)
+
+	// swagger:operation POST /admin/users/import admin-users adminUsersImport
+	// ---
+	// summary: Imports users from a CSV or NDJSON file
+	// description: |
+	//   The file is sent as the request body. CSV files must have a header row of the field names, see UserImportData.
+	//   Invalid rows are reported in the response, the other ones are still imported.
+	// consumes:
+	// - text/csv
+	// - application/x-ndjson
+	// parameters:
+	// - name: format
+	//   in: query
+	//   description: csv or ndjson, detected from the Content-Type header if omitted
+	//   type: string
+	// - name: dry_run
+	//   in: query
+	//   description: Validates the file without importing anything
+	//   type: boolean
+	// responses:
+	//   "200":
+	//     description: The import result
+	//     schema:
+	//       "$ref": "#/definitions/UserImportResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/users/import", h.importUsers)
+
+	// swagger:operation GET /admin/users/export admin-users adminUsersExport
+	// ---
+	// summary: Exports the users matching the filters as a CSV or NDJSON file
+	// produces:
+	// - text/csv
+	// - application/x-ndjson
+	// parameters:
+	// - name: format
+	//   in: query
+	//   description: csv (default) or ndjson
+	//   type: string
+	// - name: q
+	//   in: query
+	//   description: Full-text search of the names, email & phone
+	//   type: string
+	// - name: sort
+	//   in: query
+	//   description: Sorting fields, e.g. `-created_at,email`
+	//   type: string
+	// - name: filter[field__operator]
+	//   in: query
+	//   description: Filters, e.g. `filter[role__in]=admin,customer`
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: The exported file
+	//     schema:
+	//       type: file
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/users/export", h.exportUsers)
+
+	// swagger:operation POST /admin/users/{id}/block admin-users adminUsersBlock
+	// ---
+	// summary: Blocks the user from logging in
+	// description: The refresh token of the user is revoked, the `user.blocked` event is published.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// - name: If-Match
+	//   in: header
+	//   description: The ETag of the user when it was loaded, the request fails with 409 if the user has changed since then
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: The blocked user, with its new version in the ETag header
+	//     schema:
+	//       "$ref": "#/definitions/User"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 412, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/users/:id/block", h.block)
+
+	// swagger:operation POST /admin/users/{id}/unblock admin-users adminUsersUnblock
+	// ---
+	// summary: Allows the blocked user to log in again
+	// description: The `user.unblocked` event is published.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: User ID
+	//   type: string
+	//   required: true
+	// - name: If-Match
+	//   in: header
+	//   description: The ETag of the user when it was loaded, the request fails with 409 if the user has changed since then
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: The unblocked user, with its new version in the ETag header
+	//     schema:
+	//       "$ref": "#/definitions/User"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 409, 412, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/users/:id/unblock", h.unblock)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	lqc, err := httputil.ReqListQuery[types.User](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	if lqc.Page < 1 {
+		lqc.Page = 1
+	}
+	resp, err := h.svc.List(c.Request().Context(), lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) importUsers(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = formatFromContentType(c.Request().Header.Get(echo.HeaderContentType))
+	}
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return server.NewHTTPValidationError("Invalid dry_run, expecting true or false")
+		}
+	}
+
+	resp, err := h.svc.Import(c.Request().Context(), c.Request().Body, repoutil.ImportOptions{
+		Format:    format,
+		DryRun:    dryRun,
+		Validator: c.Echo().Validator,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) exportUsers(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = repoutil.FormatCSV
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return ErrUnsupportedFormat
+	}
+	lqc, err := httputil.ReqListQuery[types.User](c, httputil.ListQueryConfig{DefaultSort: "created_at"})
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="users.`+format+`"`)
+	res.WriteHeader(http.StatusOK)
+	// the response is streamed, errors cannot be reported once started
+	_, err = h.svc.Export(c.Request().Context(), res, format, lqc)
+	return err
+}
+
+func (h *HTTP) block(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	version, err := httputil.ReqIfMatch(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.Block(c.Request().Context(), id, version)
+	if err != nil {
+		return err
+	}
+	httputil.SetETag(c, int64(resp.Version))
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) unblock(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	version, err := httputil.ReqIfMatch(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.Unblock(c.Request().Context(), id, version)
+	if err != nil {
+		return err
+	}
+	httputil.SetETag(c, int64(resp.Version))
+	return c.JSON(http.StatusOK, resp)
+}
+
+var contentTypes = map[string]string{
+	repoutil.FormatCSV:    "text/csv; charset=utf-8",
+	repoutil.FormatNDJSON: "application/x-ndjson",
+}
+
+func formatFromContentType(ct string) string {
+	mediaType, _, _ := strings.Cut(ct, ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/csv":
+		return repoutil.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return repoutil.FormatNDJSON
+	}
+	return ""
+}
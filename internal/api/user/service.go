@@ -0,0 +1,24 @@
+package user
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new user service
+func New(repo *repo.Service, cr Crypter) *User {
+	return &User{
+		repo: repo,
+		cr:   cr,
+	}
+}
+
+// User represents user application service
+type User struct {
+	repo *repo.Service
+	cr   Crypter
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	HashPassword(string) string
+}
@@ -0,0 +1,34 @@
+package user
+
+import (
+	"runar-himmel/internal/types"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// ListResp represents a page of users
+// swagger:model UserListResp
+type ListResp struct {
+	Data       []types.User `json:"data"`
+	TotalCount int64        `json:"total_count"`
+}
+
+// ImportData represents a row of the user import file, the CSV columns are the JSON names
+// swagger:model UserImportData
+type ImportData struct {
+	// example: frigg@runar-himmel.sky
+	Email string `json:"email" validate:"required,email"`
+	// example: +6281234567893
+	Phone string `json:"phone" validate:"omitempty,phone"`
+	// example: Frigg
+	FirstName string `json:"first_name" validate:"required"`
+	// example: Queen of Asgard
+	LastName string `json:"last_name"`
+	// example: customer
+	Role string `json:"role" validate:"required"`
+	// example: frigg123!@#
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// ImportResp represents the result of importing users
+// swagger:model UserImportResp
+type ImportResp = repoutil.ImportResult
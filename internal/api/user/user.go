@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"runar-himmel/internal/rbac"
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// List returns a page of the users matching the filters & the search query of lqc
+func (s *User) List(ctx context.Context, lqc *repoutil.ListQueryCondition) (*ListResp, error) {
+	result, err := s.repo.User.ReadAllByCondition(ctx, lqc)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResp{Data: result.Items, TotalCount: result.Total}, nil
+}
+
+// Import creates users from the CSV or NDJSON rows of r, the rows are validated by opts.Validator if given.
+// Invalid rows are reported in the result, the other ones are still imported unless opts.DryRun.
+func (s *User) Import(ctx context.Context, r io.Reader, opts repoutil.ImportOptions) (*ImportResp, error) {
+	opts.AfterCreate = func(ctx context.Context, rec any) error {
+		u := rec.(*types.User)
+		return s.repo.Outbox.Emit(ctx, types.EventUserRegistered, u.ID, types.NewUserEvent(u))
+	}
+	result, err := repoutil.Import(ctx, s.repo.User.Repo, r, opts, s.fromImportData)
+	switch {
+	case errors.Is(err, repoutil.ErrUnsupportedFormat):
+		return nil, ErrUnsupportedFormat
+	case errors.Is(err, repoutil.ErrInvalidHeader):
+		return nil, ErrInvalidFile.SetInternal(err)
+	}
+	return result, err
+}
+
+// Export writes the users matching the filter of lqc to w in CSV or NDJSON format, returns the number of users
+func (s *User) Export(ctx context.Context, w io.Writer, format string, lqc *repoutil.ListQueryCondition) (int, error) {
+	n, err := s.repo.User.Export(ctx, w, format, lqc)
+	if errors.Is(err, repoutil.ErrUnsupportedFormat) {
+		return n, ErrUnsupportedFormat
+	}
+	return n, err
+}
+
+// Block blocks the user from logging in, the refresh token is revoked.
+// It fails with a version conflict if the user has changed since the given version, unless zero.
+func (s *User) Block(ctx context.Context, id string, version int64) (*types.User, error) {
+	return s.setStatus(ctx, id, version, types.UserStatusBlocked, types.EventUserBlocked)
+}
+
+// Unblock allows the blocked user to log in again.
+// It fails with a version conflict if the user has changed since the given version, unless zero.
+func (s *User) Unblock(ctx context.Context, id string, version int64) (*types.User, error) {
+	return s.setStatus(ctx, id, version, types.UserStatusActive, types.EventUserUnblocked)
+}
+
+// setStatus changes the status of the user and emits the event in the same transaction, nothing if unchanged
+func (s *User) setStatus(ctx context.Context, id string, version int64, status types.Status, event string) (*types.User, error) {
+	rec := &types.User{}
+	err := s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.User.ReadByID(ctx, rec, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if rec.Status == status.String() {
+			return nil
+		}
+		if version == 0 {
+			version = int64(rec.Version)
+		}
+		updates := map[string]any{"status": status.String()}
+		if status == types.UserStatusBlocked {
+			updates["refresh_token"] = nil
+		}
+		if err := tx.User.UpdateWithVersion(ctx, version, updates, `id = ?`, id); err != nil {
+			return err
+		}
+		rec.Status = status.String()
+		rec.Version = repoutil.Version(version + 1)
+		return tx.Outbox.Emit(ctx, event, rec.ID, types.NewUserEvent(rec))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *User) fromImportData(_ context.Context, data *ImportData) (*types.User, error) {
+	if !lo.Contains(rbac.ValidRoles, data.Role) {
+		return nil, fmt.Errorf("invalid role %q", data.Role)
+	}
+	return &types.User{
+		Email:     data.Email,
+		Phone:     data.Phone,
+		FirstName: data.FirstName,
+		LastName:  data.LastName,
+		Role:      data.Role,
+		Password:  s.cr.HashPassword(data.Password),
+		Status:    types.UserStatusActive.String(),
+	}, nil
+}
@@ -0,0 +1,12 @@
+package webhook
+
+import (
+	"net/http"
+	"runar-himmel/pkg/server"
+)
+
+// Custom errors
+var (
+	ErrWebhookNotFound = server.NewHTTPError(http.StatusNotFound, "WEBHOOK_NOT_FOUND", "Webhook not found")
+	ErrInvalidEvents   = server.NewHTTPError(http.StatusBadRequest, "INVALID_EVENTS", "Unknown events, expecting the event types, `<prefix>.*` or `*`")
+)
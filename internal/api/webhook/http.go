@@ -0,0 +1,306 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+	webhookutil "runar-himmel/pkg/util/webhook"
+)
+
+// HTTP represents webhook http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents webhook service interface
+type Service interface {
+	List(context.Context, *repoutil.ListQueryCondition) (*ListResp, error)
+	Create(context.Context, CreationData) (*SecretResp, error)
+	View(context.Context, string) (*webhookutil.Subscription, error)
+	Update(context.Context, string, UpdateData) (*webhookutil.Subscription, error)
+	Delete(context.Context, string) error
+	RotateSecret(context.Context, string) (*SecretResp, error)
+	ListDeliveries(context.Context, string, *repoutil.ListQueryCondition) (*DeliveryListResp, error)
+}
+
+// NewHTTP attaches handlers to Echo routers under given group.
+// The group is expected to be restricted to superadmins.
+func NewHTTP(svc Service, eg *echo.Group) {
+	h := HTTP{svc: svc}
+
+	// swagger:operation GET /admin/webhooks admin-webhooks adminWebhooksList
+	// ---
+	// summary: Lists the webhook subscriptions
+	// description: |
+	//   Filterable by `id` (exact, in), `url` (exact, icontains), `enabled` (exact) and `created_at` (gte, lte),
+	//   e.g. `filter[enabled]=false`. Sortable by `id` and `created_at`.
+	// parameters:
+	// - name: page
+	//   in: query
+	//   type: integer
+	//   default: 1
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   type: string
+	//   default: -created_at
+	// responses:
+	//   "200":
+	//     description: List of webhook subscriptions
+	//     schema:
+	//       "$ref": "#/definitions/WebhookListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/webhooks", h.list)
+
+	// swagger:operation POST /admin/webhooks admin-webhooks adminWebhooksCreate
+	// ---
+	// summary: Subscribes an endpoint to the events
+	// description: |
+	//   The events are POSTed as JSON to the URL, signed in the `X-Webhook-Signature` header as `t=<unix timestamp>,v1=<signature>`,
+	//   where the signature is the hex HMAC-SHA256 of `<unix timestamp>.<body>` using the returned secret.
+	//   Failed deliveries are retried with exponential backoff, the subscription is disabled after repeated failures.
+	// parameters:
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/WebhookCreationData"
+	// responses:
+	//   "200":
+	//     description: The new subscription along with its secret, which is not returned again
+	//     schema:
+	//       "$ref": "#/definitions/WebhookSecretResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/webhooks", h.create)
+
+	// swagger:operation GET /admin/webhooks/{id} admin-webhooks adminWebhooksView
+	// ---
+	// summary: Gets a webhook subscription
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Subscription ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The subscription
+	//     schema:
+	//       "$ref": "#/definitions/WebhookSubscription"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/webhooks/:id", h.view)
+
+	// swagger:operation PATCH /admin/webhooks/{id} admin-webhooks adminWebhooksUpdate
+	// ---
+	// summary: Updates a webhook subscription, or enables/disables it
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Subscription ID
+	//   type: string
+	//   required: true
+	// - name: request
+	//   in: body
+	//   description: Request body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/WebhookUpdateData"
+	// responses:
+	//   "200":
+	//     description: The updated subscription
+	//     schema:
+	//       "$ref": "#/definitions/WebhookSubscription"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.PATCH("/webhooks/:id", h.update)
+
+	// swagger:operation DELETE /admin/webhooks/{id} admin-webhooks adminWebhooksDelete
+	// ---
+	// summary: Deletes a webhook subscription along with its deliveries
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Subscription ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/ok"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.DELETE("/webhooks/:id", h.delete)
+
+	// swagger:operation POST /admin/webhooks/{id}/rotate-secret admin-webhooks adminWebhooksRotateSecret
+	// ---
+	// summary: Replaces the secret of a webhook subscription, effective immediately
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Subscription ID
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: The subscription along with its new secret
+	//     schema:
+	//       "$ref": "#/definitions/WebhookSecretResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.POST("/webhooks/:id/rotate-secret", h.rotateSecret)
+
+	// swagger:operation GET /admin/webhooks/{id}/deliveries admin-webhooks adminWebhooksDeliveriesList
+	// ---
+	// summary: Lists the deliveries of a webhook subscription, the newest first
+	// description: |
+	//   Filterable by `event_id` (exact), `event_type`, `status` (exact, in) and `created_at` (gte, lte),
+	//   e.g. `filter[status]=failed`. Sortable by `id` and `created_at`.
+	// parameters:
+	// - name: id
+	//   in: path
+	//   description: Subscription ID
+	//   type: string
+	//   required: true
+	// - name: page
+	//   in: query
+	//   description: Page number for offset pagination, cursor pagination is used if omitted
+	//   type: integer
+	// - name: per_page
+	//   in: query
+	//   type: integer
+	//   default: 25
+	// - name: sort
+	//   in: query
+	//   type: string
+	//   default: -created_at
+	// - name: cursor
+	//   in: query
+	//   description: The next_cursor or prev_cursor of the previous page, for cursor pagination
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: List of deliveries
+	//     schema:
+	//       "$ref": "#/definitions/WebhookDeliveryListResp"
+	//   default:
+	//     description: 'Possible errors: 400, 401, 403, 404, 500'
+	//     schema:
+	//       "$ref": "#/definitions/ErrorResponse"
+	eg.GET("/webhooks/:id/deliveries", h.listDeliveries)
+}
+
+func (h *HTTP) list(c echo.Context) error {
+	lqc, err := httputil.ReqListQuery[webhookutil.Subscription](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	if lqc.Page < 1 {
+		lqc.Page = 1
+	}
+	resp, err := h.svc.List(c.Request().Context(), lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) create(c echo.Context) error {
+	r := CreationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Create(c.Request().Context(), r)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) view(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.View(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) update(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	r := UpdateData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Update(c.Request().Context(), id, r)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) delete(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c.Request().Context(), id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *HTTP) rotateSecret(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.RotateSecret(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *HTTP) listDeliveries(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	lqc, err := httputil.ReqListQuery[webhookutil.Delivery](c, httputil.ListQueryConfig{DefaultSort: "-created_at"})
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.ListDeliveries(c.Request().Context(), id, lqc)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
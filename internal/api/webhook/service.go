@@ -0,0 +1,17 @@
+package webhook
+
+import (
+	"runar-himmel/internal/repo"
+)
+
+// New creates new webhook service
+func New(repo *repo.Service) *Webhook {
+	return &Webhook{
+		repo: repo,
+	}
+}
+
+// Webhook represents webhook subscription application service
+type Webhook struct {
+	repo *repo.Service
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import webhookutil "runar-himmel/pkg/util/webhook"
+
+// CreationData represents webhook subscription creation data
+// swagger:model WebhookCreationData
+type CreationData struct {
+	// example: https://partner.example/hooks/runar-himmel
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+	// The event types, `user.*` for all user events or `*` for all events
+	// example: ["user.registered", "memo.*"]
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+	// example: Partner CRM sync
+	Description string `json:"description" validate:"max=255"`
+}
+
+// UpdateData represents webhook subscription update data, omitted fields are unchanged
+// swagger:model WebhookUpdateData
+type UpdateData struct {
+	URL         *string  `json:"url" validate:"omitempty,http_url,max=2048"`
+	Events      []string `json:"events" validate:"omitempty,min=1,dive,required"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	// Enabling again resets the failures & resumes the pending deliveries
+	Enabled *bool `json:"enabled"`
+}
+
+// SecretResp represents the subscription along with its secret, only returned on creation & rotation
+// swagger:model WebhookSecretResp
+type SecretResp struct {
+	webhookutil.Subscription
+	// The key verifying the X-Webhook-Signature header of the requests, see webhookutil.Verify
+	Secret string `json:"secret"`
+}
+
+// ListResp represents a page of webhook subscriptions
+// swagger:model WebhookListResp
+type ListResp struct {
+	Data       []webhookutil.Subscription `json:"data"`
+	TotalCount int64                      `json:"total_count"`
+}
+
+// DeliveryListResp represents a page of webhook deliveries
+// swagger:model WebhookDeliveryListResp
+type DeliveryListResp struct {
+	Data       []webhookutil.Delivery `json:"data"`
+	TotalCount int64                  `json:"total_count"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	PrevCursor string                 `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+	"runar-himmel/pkg/server"
+	repoutil "runar-himmel/pkg/util/repo"
+	webhookutil "runar-himmel/pkg/util/webhook"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// List returns a page of the webhook subscriptions matching the filters of lqc
+func (s *Webhook) List(ctx context.Context, lqc *repoutil.ListQueryCondition) (*ListResp, error) {
+	result, err := s.repo.Webhook.ReadAllByCondition(ctx, lqc)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResp{Data: result.Items, TotalCount: result.Total}, nil
+}
+
+// Create creates a new enabled subscription with a random secret
+func (s *Webhook) Create(ctx context.Context, data CreationData) (*SecretResp, error) {
+	if err := validateEvents(data.Events); err != nil {
+		return nil, err
+	}
+	rec := &webhookutil.Subscription{
+		URL:         data.URL,
+		Events:      lo.Uniq(data.Events),
+		Description: data.Description,
+		Secret:      webhookutil.NewSecret(),
+		Enabled:     true,
+	}
+	if err := s.repo.Webhook.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	return &SecretResp{Subscription: *rec, Secret: rec.Secret}, nil
+}
+
+// View returns a subscription by ID
+func (s *Webhook) View(ctx context.Context, id string) (*webhookutil.Subscription, error) {
+	rec := &webhookutil.Subscription{}
+	if err := s.repo.Webhook.ReadByID(ctx, rec, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound.SetInternal(err)
+		}
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Update updates the given fields of a subscription.
+// Enabling a disabled subscription resets its failures, the pending deliveries are resumed.
+func (s *Webhook) Update(ctx context.Context, id string, data UpdateData) (*webhookutil.Subscription, error) {
+	rec, err := s.View(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{}
+	if data.URL != nil {
+		updates["url"] = *data.URL
+	}
+	if data.Events != nil {
+		if err := validateEvents(data.Events); err != nil {
+			return nil, err
+		}
+		updates["events"] = webhookutil.Events(lo.Uniq(data.Events))
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.Enabled != nil && *data.Enabled != rec.Enabled {
+		updates["enabled"] = *data.Enabled
+		if *data.Enabled {
+			updates["failures"] = 0
+			updates["disabled_at"] = nil
+		} else {
+			updates["disabled_at"] = time.Now()
+		}
+	}
+	if len(updates) == 0 {
+		return rec, nil
+	}
+
+	if err := s.repo.Webhook.Update(ctx, updates, `id = ?`, id); err != nil {
+		return nil, err
+	}
+	return s.View(ctx, id)
+}
+
+// Delete deletes a subscription along with its deliveries
+func (s *Webhook) Delete(ctx context.Context, id string) error {
+	if _, err := s.View(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Transaction(ctx, func(tx *repo.Service) error {
+		if err := tx.WebhookDelivery.Delete(ctx, `subscription_id = ?`, id); err != nil {
+			return err
+		}
+		return tx.Webhook.Delete(ctx, `id = ?`, id)
+	})
+}
+
+// RotateSecret replaces the secret of a subscription with a new random one, effective immediately
+func (s *Webhook) RotateSecret(ctx context.Context, id string) (*SecretResp, error) {
+	rec, err := s.View(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rec.Secret = webhookutil.NewSecret()
+	if err := s.repo.Webhook.Update(ctx, map[string]any{"secret": rec.Secret}, `id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &SecretResp{Subscription: *rec, Secret: rec.Secret}, nil
+}
+
+// ListDeliveries returns the deliveries of a subscription matching the filters of lqc.
+// Uses offset pagination if the page is given, cursor pagination otherwise.
+func (s *Webhook) ListDeliveries(ctx context.Context, id string, lqc *repoutil.ListQueryCondition) (*DeliveryListResp, error) {
+	if _, err := s.View(ctx, id); err != nil {
+		return nil, err
+	}
+	lqc.AddFilter(map[string]any{"subscription_id": id})
+
+	var result *repoutil.ListResult[webhookutil.Delivery]
+	var err error
+	if lqc.Page > 0 {
+		result, err = s.repo.WebhookDelivery.ReadAllByCondition(ctx, lqc)
+	} else {
+		result, err = s.repo.WebhookDelivery.ReadAllByCursor(ctx, lqc)
+	}
+	if errors.Is(err, repoutil.ErrInvalidCursor) {
+		return nil, server.NewHTTPValidationError("Invalid cursor").SetInternal(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryListResp{Data: result.Items, TotalCount: result.Total, NextCursor: result.NextCursor, PrevCursor: result.PrevCursor}, nil
+}
+
+// validateEvents checks the events are known event types, or their patterns
+func validateEvents(events []string) error {
+	for _, e := range events {
+		if e == "*" || lo.Contains(types.Events, e) {
+			continue
+		}
+		prefix, ok := strings.CutSuffix(e, "*")
+		if !ok || !strings.HasSuffix(prefix, ".") || !lo.ContainsBy(types.Events, func(typ string) bool {
+			return strings.HasPrefix(typ, prefix)
+		}) {
+			return ErrInvalidEvents
+		}
+	}
+	return nil
+}
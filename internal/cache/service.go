@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"runar-himmel/config"
+	cacheutil "runar-himmel/pkg/util/cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// New creates the cache store of the given config, nil if caching is disabled
+func New(cfg config.Cache) (cacheutil.Store, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "lru":
+		return cacheutil.NewLRU(cfg.Size), nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, fmt.Errorf("cannot connect to cache server: %w", err)
+		}
+		return cacheutil.NewRedis(client, cfg.Prefix), nil
+	}
+
+	return nil, fmt.Errorf("unsupported cache driver '%s'", cfg.Driver)
+}
@@ -0,0 +1,26 @@
+package cron
+
+import (
+	"errors"
+	"time"
+
+	"runar-himmel/config"
+	"runar-himmel/internal/repo"
+	cronutil "runar-himmel/pkg/util/cron"
+)
+
+// Periodic tasks run by the scheduler, see functions/cron
+const (
+	TaskSessionsPurge = "sessions.purge"
+	TaskOTPsPurge     = "otps.purge"
+	TaskRecordsPurge  = "records.purge"
+)
+
+// Register registers all periodic tasks
+func Register(s *cronutil.Scheduler, repo *repo.Service, cfg config.Cron) error {
+	return errors.Join(
+		s.Add(TaskSessionsPurge, "0 * * * *", purgeSessions(repo)),
+		s.Add(TaskOTPsPurge, "@every 15m", purgeOTPs(repo, time.Duration(cfg.OTPTTL)*time.Second)),
+		s.Add(TaskRecordsPurge, "30 3 * * *", purgeRecords(repo, time.Duration(cfg.Retention)*24*time.Hour)),
+	)
+}
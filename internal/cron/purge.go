@@ -0,0 +1,69 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"runar-himmel/internal/repo"
+	"runar-himmel/internal/types"
+	cronutil "runar-himmel/pkg/util/cron"
+	jobutil "runar-himmel/pkg/util/job"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	webhookutil "runar-himmel/pkg/util/webhook"
+
+	gjwt "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// purgeSessions clears the expired refresh tokens of the users, they cannot be refreshed anyway
+func purgeSessions(repo *repo.Service) cronutil.Func {
+	return func(ctx context.Context) error {
+		now := time.Now()
+		parser := gjwt.NewParser()
+		users := []types.User{}
+		return repo.User.DB(ctx).Select("id", "refresh_token").Where("refresh_token IS NOT NULL").
+			FindInBatches(&users, 500, func(*gorm.DB, int) error {
+				for _, u := range users {
+					// the signature was verified when issued, only the expiration matters here
+					claims := gjwt.RegisteredClaims{}
+					if _, _, err := parser.ParseUnverified(*u.RefreshToken, &claims); err == nil &&
+						(claims.ExpiresAt == nil || claims.ExpiresAt.After(now)) {
+						continue
+					}
+					// unless refreshed meanwhile
+					if _, err := repo.User.UpdateMany(ctx, map[string]any{"refresh_token": nil},
+						`id = ? AND refresh_token = ?`, u.ID, *u.RefreshToken); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	}
+}
+
+// purgeOTPs clears the OTPs sent earlier than the TTL
+func purgeOTPs(repo *repo.Service, ttl time.Duration) cronutil.Func {
+	return func(ctx context.Context) error {
+		_, err := repo.User.UpdateMany(ctx, map[string]any{"otp": nil, "otp_sent_at": nil},
+			`otp IS NOT NULL AND (otp_sent_at IS NULL OR otp_sent_at < ?)`, time.Now().Add(-ttl).UTC())
+		return err
+	}
+}
+
+// purgeRecords deletes the delivered events, the succeeded jobs & webhook deliveries, and the run history
+// older than the retention. The failed ones are kept for investigation.
+func purgeRecords(repo *repo.Service, retention time.Duration) cronutil.Func {
+	return func(ctx context.Context) error {
+		before := time.Now().Add(-retention).UTC()
+		if err := repo.Outbox.Delete(ctx, `status = ? AND delivered_at < ?`, outboxutil.StatusDelivered, before); err != nil {
+			return err
+		}
+		if err := repo.Job.Delete(ctx, `status = ? AND finished_at < ?`, jobutil.StatusSucceeded, before); err != nil {
+			return err
+		}
+		if err := repo.WebhookDelivery.Delete(ctx, `status = ? AND delivered_at < ?`, webhookutil.StatusSucceeded, before); err != nil {
+			return err
+		}
+		return repo.CronRun.Delete(ctx, `started_at < ?`, before)
+	}
+}
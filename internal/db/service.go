@@ -3,42 +3,41 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/imdatngo/gowhere"
 
 	"runar-himmel/config"
+	auditutil "runar-himmel/pkg/util/audit"
 	dbutil "runar-himmel/pkg/util/db"
+	repoutil "runar-himmel/pkg/util/repo"
 
-	// _ "gorm.io/driver/sqlite" // DB adapter
-	// _ "gorm.io/gorm/dialects/postgres" // DB adapter
-	_ "gorm.io/driver/mysql" // DB adapter
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	// EnablePostgreSQL: remove the mysql package above, uncomment the following
 )
 
-// New creates new database connection to the database server
+// New creates new database connection to the database server, the dialect is selected by `DB_DRIVER`
 func New(cfg config.DB) (db *gorm.DB, sqldb *sql.DB, err error) {
 	// Add your DB related stuffs here, such as:
 	// - gorm.DefaultTableNameHandler
 	// - gowhere.DefaultConfig
 
-	// ! EnablePostgreSQL
-	// gowhere.DefaultConfig.Dialect = gowhere.DialectPostgreSQL
-	gowhere.DefaultConfig.Dialect = gowhere.DialectMySQL
+	gowhere.DefaultConfig.Dialect = dbutil.WhereDialect(cfg.Driver)
 
 	// logger config
 	var lo logger.Interface
 	if cfg.Logging > 0 {
-		lo = logger.Default.LogMode(logger.LogLevel(cfg.Logging))
+		lo = dbutil.NewLogger(log.New(os.Stdout, "\r\n", log.LstdFlags), dbutil.LoggerConfig{
+			SlowThreshold: time.Duration(cfg.SlowThreshold) * time.Millisecond,
+			LogLevel:      logger.LogLevel(cfg.Logging),
+		})
 	} else {
 		lo = logger.Discard
 	}
 
-	// ! EnablePostgreSQL: remove 2 lines above, uncomment the following
-	// db, err := dbutil.New("postgres", dbPsn, enableLog)
-	db, err = dbutil.New("mysql", cfg, &gorm.Config{
+	db, err = dbutil.New(cfg.Driver, cfg, &gorm.Config{
 		Logger:                                   lo,
 		AllowGlobalUpdate:                        false,
 		CreateBatchSize:                          1000,
@@ -47,17 +46,33 @@ func New(cfg config.DB) (db *gorm.DB, sqldb *sql.DB, err error) {
 		// 	// SingularTable: true, // use singular table name, table for `User` would be `user` with this option enabled
 		// },
 	})
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// connection pool settings
+	if err := dbutil.SetPool(db, dbutil.NewPoolConfig(cfg)); err != nil {
+		return nil, nil, fmt.Errorf("cannot set connection pool: %w", err)
+	}
+	// per-query deadline, applied to the replicas as well
+	if err := repoutil.UseTimeout(db, time.Duration(cfg.QueryTimeout)*time.Second); err != nil {
+		return nil, nil, fmt.Errorf("cannot set query timeout: %w", err)
+	}
+	// route reads to the replicas if any, see dbutil.WithPrimary for reading from the primary
+	if err := dbutil.UseReplicas(db, cfg.Driver, cfg); err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to db replicas: %w", err)
+	}
+	// audit logs of the data changes, attributed to the actor of the context
+	if cfg.Audit {
+		if err := auditutil.Use(db); err != nil {
+			return nil, nil, fmt.Errorf("cannot register audit callbacks: %w", err)
+		}
+	}
+
 	sqldb, err = db.DB()
 	if err != nil {
 		return nil, nil, fmt.Errorf("cannot get generic db instance: %w", err)
 	}
-	//! NOTE: These are not one-size-fits-all settings. Turn it based on your db settings!
-	sqldb.SetMaxIdleConns(10)
-	sqldb.SetMaxOpenConns(10)
-	sqldb.SetConnMaxLifetime(30 * time.Minute)
-	sqldb.SetConnMaxIdleTime(10 * time.Minute)
 
 	return
 }
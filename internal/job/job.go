@@ -0,0 +1,17 @@
+package job
+
+import (
+	jobutil "runar-himmel/pkg/util/job"
+)
+
+// Job types run by the worker, enqueued by the services using repo.Job.Enqueue
+const (
+	TypePushSend   = "push.send"
+	TypeNotifyUser = "notify.user"
+)
+
+// Register registers the handlers of all job types
+func Register(w *jobutil.Worker, pusher Pusher, notifier Notifier) {
+	w.Handle(TypePushSend, PushHandler(pusher))
+	w.Handle(TypeNotifyUser, NotifyUserHandler(notifier))
+}
@@ -0,0 +1,91 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	jobutil "runar-himmel/pkg/util/job"
+	notifyutil "runar-himmel/pkg/util/notify"
+	snsutil "runar-himmel/pkg/util/sns"
+)
+
+// Platforms of the push notifications
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	// Sent to all devices subscribed to the topic
+	PlatformTopic = "topic"
+)
+
+// Push represents the payload of the push.send jobs
+type Push struct {
+	Platform string `json:"platform"`
+	// The SNS endpoint ARN of the device, or the topic ARN
+	Target string `json:"target"`
+	notifyutil.Payload
+}
+
+// Pusher sends the push notifications, implemented by snsutil.Service
+type Pusher interface {
+	SendToIOS(target string, payload snsutil.APNSPayload) (string, error)
+	SendToAndroid(target string, payload snsutil.FCMPayload) (string, error)
+	SendToTopic(topic string, msg snsutil.Message) (string, error)
+}
+
+// PushHandler returns the handler sending the push notifications of the push.send jobs
+func PushHandler(pusher Pusher) jobutil.Handler {
+	return func(ctx context.Context, j *jobutil.Job) error {
+		p := Push{}
+		if err := j.Decode(&p); err != nil {
+			return jobutil.Permanent(err)
+		}
+		if p.Target == "" {
+			return jobutil.Permanent(errors.New("no target"))
+		}
+
+		var err error
+		switch p.Platform {
+		case PlatformIOS:
+			_, err = pusher.SendToIOS(p.Target, p.APNS())
+		case PlatformAndroid:
+			_, err = pusher.SendToAndroid(p.Target, p.FCM())
+		case PlatformTopic:
+			_, err = pusher.SendToTopic(p.Target, p.Message())
+		default:
+			return jobutil.Permanent(fmt.Errorf("unknown platform %q", p.Platform))
+		}
+		return err
+	}
+}
+
+// NotifyUser represents the payload of the notify.user jobs
+type NotifyUser struct {
+	UserID string `json:"user_id"`
+	notifyutil.Payload
+}
+
+// Notifier sends the push notifications to the devices of the users, implemented by notifyutil.Service
+type Notifier interface {
+	SendToUser(ctx context.Context, userID string, p notifyutil.Payload) (int, error)
+}
+
+// NotifyUserHandler returns the handler sending the push notifications of the notify.user jobs
+func NotifyUserHandler(notifier Notifier) jobutil.Handler {
+	return func(ctx context.Context, j *jobutil.Job) error {
+		p := NotifyUser{}
+		if err := j.Decode(&p); err != nil {
+			return jobutil.Permanent(err)
+		}
+		if p.UserID == "" {
+			return jobutil.Permanent(errors.New("no user"))
+		}
+
+		n, err := notifier.SendToUser(ctx, p.UserID, p.Payload)
+		// not retried once sent to some devices, so they are not notified twice
+		if err != nil && n > 0 {
+			return jobutil.Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"runar-himmel/config"
+	notifyutil "runar-himmel/pkg/util/notify"
+
+	"gorm.io/gorm"
+)
+
+// New creates the notification service of the given config, sending through client
+func New(db *gorm.DB, client notifyutil.Client, cfg config.Notify) (*notifyutil.Service, error) {
+	topics, err := cfg.TopicARNs()
+	if err != nil {
+		return nil, err
+	}
+	return notifyutil.New(db, client, notifyutil.Config{
+		IOSAppARN:     cfg.IOSAppARN,
+		AndroidAppARN: cfg.AndroidAppARN,
+		Topics:        topics,
+	}), nil
+}
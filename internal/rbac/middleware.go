@@ -0,0 +1,20 @@
+package rbac
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+// RequireRoles returns a middleware that only allows users having one of the given roles.
+// It must be placed after the JWT middleware, which sets the `role` claim into the context.
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("role").(string)
+			if !lo.Contains(roles, role) {
+				return ErrForbiddenAccess
+			}
+			return next(c)
+		}
+	}
+}
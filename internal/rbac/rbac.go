@@ -22,12 +22,19 @@ var (
 // ValidRoles for validation
 var ValidRoles = []string{RoleSuperAdmin, RoleAdmin, RoleCustomer}
 
+// DomainAny matches all domains (organizations) in a policy
+const DomainAny = "*"
+
 // RBAC objects
 const (
 	ObjectAny  = "*"
 	ObjectUser = "user"
+	ObjectMemo = "memo"
 )
 
+// ValidObjects for validation
+var ValidObjects = []string{ObjectAny, ObjectUser, ObjectMemo}
+
 // RBAC actions
 const (
 	ActionAny       = "*"
@@ -40,3 +47,23 @@ const (
 	ActionDeleteAll = "delete_all"
 	ActionDelete    = "delete"
 )
+
+// ValidActions for validation
+var ValidActions = []string{
+	ActionAny,
+	ActionViewAll, ActionView,
+	ActionCreateAll, ActionCreate,
+	ActionUpdateAll, ActionUpdate,
+	ActionDeleteAll, ActionDelete,
+}
+
+// DefaultPolicies are the initial [role, object, action] rules, seeded by the migration.
+// They were seeded without domain, the later migration moves them to the `*` domain (DomainAny).
+var DefaultPolicies = [][]string{
+	{RoleSuperAdmin, ObjectAny, ActionAny},
+	{RoleAdmin, ObjectUser, ActionViewAll},
+	{RoleAdmin, ObjectUser, ActionCreateAll},
+	{RoleAdmin, ObjectUser, ActionUpdateAll},
+	{RoleCustomer, ObjectUser, ActionView},
+	{RoleCustomer, ObjectUser, ActionUpdate},
+}
@@ -1,14 +1,28 @@
 package rbac
 
 import (
+	"runar-himmel/config"
 	"runar-himmel/pkg/rbac"
+
+	"gorm.io/gorm"
 )
 
-// New returns new RBAC service
-func New(enableLog bool) *rbac.RBAC {
-	r := rbac.NewWithConfig(rbac.Config{EnableLog: enableLog})
+// New returns new RBAC service, policies are loaded from and saved to the given database.
+// Roles are assigned per organization (domain), see rbac.NewRBACWithDomainModel.
+// If the model & policy files are configured, they are loaded in addition to the database policies,
+// see rbac.NewFromFiles.
+func New(db *gorm.DB, cfg config.RBAC, enableLog bool) (*rbac.RBAC, error) {
+	var r *rbac.RBAC
+	if cfg.ModelFile != "" && cfg.PolicyFile != "" {
+		var err error
+		if r, err = rbac.NewFromFiles(rbac.Config{GormDB: db, EnableLog: enableLog}, cfg.ModelFile, cfg.PolicyFile); err != nil {
+			return nil, err
+		}
+	} else {
+		r = rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: enableLog})
+	}
 
-	r.GetModel().PrintPolicy()
+	r.PrintPolicy()
 
-	return r
+	return r, nil
 }
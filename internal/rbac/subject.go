@@ -0,0 +1,19 @@
+package rbac
+
+import (
+	"runar-himmel/pkg/rbac"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CurrentSubject returns the RBAC subject of the current request, built from the access token claims.
+// Superadmins are granted their role in all organizations.
+func CurrentSubject(c echo.Context) rbac.Subject {
+	sub := rbac.Subject{}
+	sub.ID, _ = c.Get("id").(string)
+	sub.Domain, _ = c.Get("org").(string)
+	if role, _ := c.Get("role").(string); role == RoleSuperAdmin {
+		sub.Roles = []string{RoleSuperAdmin}
+	}
+	return sub
+}
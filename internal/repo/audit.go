@@ -0,0 +1,18 @@
+package repo
+
+import (
+	auditutil "runar-himmel/pkg/util/audit"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// AuditLog represents the client for audit_logs table, which is append-only
+type AuditLog struct {
+	*repoutil.Repo[auditutil.Log]
+}
+
+// NewAuditLog returns a new audit log database instance
+func NewAuditLog(gdb *gorm.DB) *AuditLog {
+	return &AuditLog{repoutil.NewRepo[auditutil.Log](gdb)}
+}
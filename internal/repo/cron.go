@@ -0,0 +1,18 @@
+package repo
+
+import (
+	cronutil "runar-himmel/pkg/util/cron"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// CronRun represents the client for cron_runs table, the run history of the periodic tasks
+type CronRun struct {
+	*repoutil.Repo[cronutil.Run]
+}
+
+// NewCronRun returns a new cron run database instance
+func NewCronRun(gdb *gorm.DB) *CronRun {
+	return &CronRun{repoutil.NewRepo[cronutil.Run](gdb)}
+}
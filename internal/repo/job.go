@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"context"
+
+	jobutil "runar-himmel/pkg/util/job"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// Job represents the client for jobs table, holding the background jobs run by the worker
+type Job struct {
+	*repoutil.Repo[jobutil.Job]
+}
+
+// NewJob returns a new job database instance
+func NewJob(gdb *gorm.DB) *Job {
+	return &Job{repoutil.NewRepo[jobutil.Job](gdb)}
+}
+
+// Enqueue creates a job, in the transaction of the repo or the one carried by ctx if any
+func (r *Job) Enqueue(ctx context.Context, typ string, payload any, opts jobutil.EnqueueOptions) (*jobutil.Job, error) {
+	return jobutil.Enqueue(ctx, r.DB(ctx), typ, payload, opts)
+}
+
+// Retry enqueues a dead job again, see jobutil.Retry
+func (r *Job) Retry(ctx context.Context, id string) error {
+	return jobutil.Retry(ctx, r.DB(ctx), id)
+}
@@ -0,0 +1,26 @@
+package repo
+
+import (
+	"context"
+	"runar-himmel/internal/types"
+
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// Membership represents the client for membership table, scoped by organization
+type Membership struct {
+	*repoutil.Repo[types.Membership]
+}
+
+// NewMembership returns a new membership database instance
+func NewMembership(gdb *gorm.DB) *Membership {
+	return &Membership{repoutil.NewTenantRepo[types.Membership](gdb, "organization_id")}
+}
+
+// FindByUser finds all memberships of the given user across organizations, the oldest first
+func (r *Membership) FindByUser(ctx context.Context, userID string) (recs []*types.Membership, err error) {
+	err = r.DB(ctx).Order(`created_at`).Find(&recs, `user_id = ?`, userID).Error
+	return
+}
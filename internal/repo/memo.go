@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"runar-himmel/internal/types"
+
+	repoutil "runar-himmel/pkg/util/repo"
+	searchutil "runar-himmel/pkg/util/search"
+
+	"gorm.io/gorm"
+)
+
+// Memo represents the client for memo table, scoped by organization
+type Memo struct {
+	*repoutil.Repo[types.Memo]
+}
+
+// MemoSearchIndex is the full-text index of memos, created by migrations
+var MemoSearchIndex = searchutil.Index{Name: "ftx_memos", Table: "memos", Columns: []string{"content"}}
+
+// NewMemo returns a new memo database instance, searchable by the content
+func NewMemo(gdb *gorm.DB) *Memo {
+	r := repoutil.NewTenantRepo[types.Memo](gdb, "organization_id")
+	r.Searcher = searchutil.New(gdb, MemoSearchIndex)
+	return &Memo{r}
+}
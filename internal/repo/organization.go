@@ -0,0 +1,19 @@
+package repo
+
+import (
+	"runar-himmel/internal/types"
+
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// Organization represents the client for organization table
+type Organization struct {
+	*repoutil.Repo[types.Organization]
+}
+
+// NewOrganization returns a new organization database instance
+func NewOrganization(gdb *gorm.DB) *Organization {
+	return &Organization{repoutil.NewRepo[types.Organization](gdb)}
+}
@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"context"
+
+	outboxutil "runar-himmel/pkg/util/outbox"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// Outbox represents the client for outbox table, holding the domain events to be delivered
+type Outbox struct {
+	*repoutil.Repo[outboxutil.Event]
+}
+
+// NewOutbox returns a new outbox database instance
+func NewOutbox(gdb *gorm.DB) *Outbox {
+	return &Outbox{repoutil.NewRepo[outboxutil.Event](gdb)}
+}
+
+// Emit writes an event into the outbox, in the transaction of the repo or the one carried by ctx if any
+func (r *Outbox) Emit(ctx context.Context, typ, key string, payload any) error {
+	return outboxutil.Emit(ctx, r.DB(ctx), typ, key, payload)
+}
@@ -1,15 +1,64 @@
 package repo
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"time"
+
+	cacheutil "runar-himmel/pkg/util/cache"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
 
 // Service provides all databases
 type Service struct {
-	User *User
+	User            *User
+	Organization    *Organization
+	Membership      *Membership
+	Memo            *Memo
+	AuditLog        *AuditLog
+	Outbox          *Outbox
+	Webhook         *Webhook
+	WebhookDelivery *WebhookDelivery
+	Job             *Job
+	CronRun         *CronRun
+
+	db       *gorm.DB
+	cache    cacheutil.Store
+	cacheTTL time.Duration
 }
 
 // New creates db service
 func New(db *gorm.DB) *Service {
+	return NewWithCache(db, nil, 0)
+}
+
+// NewWithCache creates db service, the hot paths such as loading users are cached in the given store.
+// Nil store disables the cache.
+func NewWithCache(db *gorm.DB, store cacheutil.Store, ttl time.Duration) *Service {
 	return &Service{
-		User: NewUser(db),
+		User:            NewUser(db, store, ttl),
+		Organization:    NewOrganization(db),
+		Membership:      NewMembership(db),
+		Memo:            NewMemo(db),
+		AuditLog:        NewAuditLog(db),
+		Outbox:          NewOutbox(db),
+		Webhook:         NewWebhook(db),
+		WebhookDelivery: NewWebhookDelivery(db),
+		Job:             NewJob(db),
+		CronRun:         NewCronRun(db),
+
+		db:       db,
+		cache:    store,
+		cacheTTL: ttl,
 	}
 }
+
+// Transaction runs fn in a transaction, all repos of tx are bound to it.
+// Calling Transaction of tx creates a nested transaction using savepoints.
+// It also joins the transaction carried by ctx if any, see repoutil.WithTx.
+func (s *Service) Transaction(ctx context.Context, fn func(tx *Service) error) error {
+	return repoutil.RunTx(ctx, repoutil.Conn(ctx, s.db), func(tx *gorm.DB) error {
+		return fn(NewWithCache(tx, s.cache, s.cacheTTL))
+	})
+}
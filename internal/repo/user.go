@@ -3,31 +3,39 @@ package repo
 import (
 	"context"
 	"runar-himmel/internal/types"
+	"time"
 
+	cacheutil "runar-himmel/pkg/util/cache"
 	repoutil "runar-himmel/pkg/util/repo"
+	searchutil "runar-himmel/pkg/util/search"
 
 	"gorm.io/gorm"
 )
 
-// User represents the client for user table
+// User represents the client for user table, the reads by ID & email are cached if the cache is enabled
 type User struct {
-	*repoutil.Repo[types.User]
+	*repoutil.CachedRepo[types.User]
 }
 
-// NewUser returns a new user database instance
-func NewUser(gdb *gorm.DB) *User {
-	return &User{repoutil.NewRepo[types.User](gdb)}
+// UserSearchIndex is the full-text index of users, created by migrations
+var UserSearchIndex = searchutil.Index{Name: "ftx_users", Table: "users", Columns: []string{"first_name", "last_name", "email", "phone"}}
+
+// NewUser returns a new user database instance searchable by names, email & phone. Nil store disables the cache.
+func NewUser(gdb *gorm.DB, store cacheutil.Store, ttl time.Duration) *User {
+	r := repoutil.NewRepo[types.User](gdb)
+	r.Searcher = searchutil.New(gdb, UserSearchIndex)
+	return &User{repoutil.NewCachedRepo(r, store, ttl)}
 }
 
 // FindByEmail finds a user by the given email
 func (r *User) FindByEmail(ctx context.Context, email string) (rec *types.User, err error) {
 	rec = &types.User{}
-	err = r.GDB.Take(rec, `email = ?`, email).Error
+	err = r.ReadBy(ctx, rec, "email", email)
 
 	return
 }
 
 // UpdateRefreshToken updates the refresh token of the given user
 func (r *User) UpdateRefreshToken(ctx context.Context, userID, refreshToken string) error {
-	return r.GDB.Model(&types.User{}).Where(`id = ?`, userID).Update(`refresh_token`, refreshToken).Error
+	return r.Update(ctx, map[string]any{"refresh_token": refreshToken}, `id = ?`, userID)
 }
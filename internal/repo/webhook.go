@@ -0,0 +1,28 @@
+package repo
+
+import (
+	repoutil "runar-himmel/pkg/util/repo"
+	webhookutil "runar-himmel/pkg/util/webhook"
+
+	"gorm.io/gorm"
+)
+
+// Webhook represents the client for webhooks table, holding the webhook subscriptions
+type Webhook struct {
+	*repoutil.Repo[webhookutil.Subscription]
+}
+
+// NewWebhook returns a new webhook database instance
+func NewWebhook(gdb *gorm.DB) *Webhook {
+	return &Webhook{repoutil.NewRepo[webhookutil.Subscription](gdb)}
+}
+
+// WebhookDelivery represents the client for webhook_deliveries table, the delivery log of the webhooks
+type WebhookDelivery struct {
+	*repoutil.Repo[webhookutil.Delivery]
+}
+
+// NewWebhookDelivery returns a new webhook delivery database instance
+func NewWebhookDelivery(gdb *gorm.DB) *WebhookDelivery {
+	return &WebhookDelivery{repoutil.NewRepo[webhookutil.Delivery](gdb)}
+}
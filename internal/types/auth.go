@@ -10,7 +10,7 @@ type Session struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	UserID    string    `json:"user_id"`
 	IsBlocked bool      `json:"is_blocked"`
-	ExpiresAt time.Time `json:"expires_at" gorm:"type:datetime(3)"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // AuthToken holds authentication token details with refresh token
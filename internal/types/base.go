@@ -1,6 +1,7 @@
 package types
 
 import (
+	repoutil "runar-himmel/pkg/util/repo"
 	"runar-himmel/pkg/util/ulidutil"
 	"time"
 
@@ -10,13 +11,18 @@ import (
 // Base contains common fields for all models
 type Base struct {
 	// ID of the record
-	ID string `json:"id" gorm:"primaryKey"`
+	ID string `json:"id" gorm:"primaryKey" filter:"exact,in" sort:"true"`
 	// The time that record is created
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `json:"created_at" filter:"gte,lte,date" sort:"true"`
 	// The latest time that record is updated
-	UpdatedAt time.Time `json:"updated_at"`
-	// Enable soft-delete
-	// DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at" filter:"gte,lte,date" sort:"true"`
+}
+
+// SoftDelete enables soft-delete for the model embedding it along with Base.
+// Models having unique indexes should declare their own DeletedAt field instead, see User.
+type SoftDelete struct {
+	// The time that record is deleted
+	DeletedAt repoutil.DeletedAt `json:"deleted_at,omitempty" gorm:"not null;default:0;index"`
 }
 
 // BeforeCreate hook executed by gorm
@@ -26,3 +32,15 @@ func (b *Base) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return
 }
+
+// Audited enables the audit logs of the model embedding Base, see auditutil.Use.
+// Models can override it to opt out.
+func (Base) Audited() bool {
+	return true
+}
+
+// Versioned enables optimistic locking for the model embedding it along with Base, see repoutil.Repo.UpdateWithVersion
+type Versioned struct {
+	// The version of the record, increased by every update
+	Version repoutil.Version `json:"version" gorm:"not null;default:1"`
+}
@@ -0,0 +1,59 @@
+package types
+
+// Domain events published through the outbox, see outboxutil.Emit
+const (
+	EventUserRegistered = "user.registered"
+	EventUserBlocked    = "user.blocked"
+	EventUserUnblocked  = "user.unblocked"
+	EventMemoCreated    = "memo.created"
+	EventMemoUpdated    = "memo.updated"
+	EventMemoDeleted    = "memo.deleted"
+)
+
+// Events lists all domain events, for validating the webhook subscriptions
+var Events = []string{
+	EventUserRegistered, EventUserBlocked, EventUserUnblocked,
+	EventMemoCreated, EventMemoUpdated, EventMemoDeleted,
+}
+
+// UserEvent represents the payload of the user events
+type UserEvent struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role"`
+	Status    string `json:"status"`
+}
+
+// NewUserEvent returns the event payload of the user
+func NewUserEvent(u *User) UserEvent {
+	return UserEvent{
+		ID:        u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Role:      u.Role,
+		Status:    u.Status,
+	}
+}
+
+// MemoEvent represents the payload of the memo events
+type MemoEvent struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	Content        string `json:"memo,omitempty"`
+	Version        int64  `json:"version,omitempty"`
+}
+
+// NewMemoEvent returns the event payload of the memo
+func NewMemoEvent(m *Memo) MemoEvent {
+	return MemoEvent{
+		ID:             m.ID,
+		OrganizationID: m.OrganizationID,
+		UserID:         m.UserID,
+		Content:        m.Content,
+		Version:        int64(m.Version),
+	}
+}
@@ -4,6 +4,8 @@ package types
 // swagger:model
 type Memo struct {
 	Base
-	UserID  string `json:"user_id"`
-	Content string `json:"memo" gorm:"type:text"`
+	Versioned
+	OrganizationID string `json:"organization_id" gorm:"index"`
+	UserID         string `json:"user_id" filter:"exact,in"`
+	Content        string `json:"memo" gorm:"type:text" filter:"icontains"`
 }
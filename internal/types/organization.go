@@ -0,0 +1,17 @@
+package types
+
+// Organization represents the organization model, aka the tenant
+// swagger:model
+type Organization struct {
+	Base
+	Name string `json:"name"`
+}
+
+// Membership represents the membership of a user in an organization
+// swagger:model
+type Membership struct {
+	Base
+	OrganizationID string `json:"organization_id" gorm:"uniqueIndex:uix_memberships_organization_user"`
+	UserID         string `json:"user_id" gorm:"uniqueIndex:uix_memberships_organization_user;index"`
+	Role           string `json:"role"`
+}
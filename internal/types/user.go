@@ -1,6 +1,10 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	repoutil "runar-himmel/pkg/util/repo"
+)
 
 // cosnt
 const (
@@ -30,20 +34,25 @@ func (s Status) String() string {
 // swagger:model
 type User struct {
 	Base
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Role      string `json:"role"`
+	// Increased by the changes made by the admins, e.g. blocking, not by the sessions of the user
+	Versioned
+	FirstName string `json:"first_name" filter:"exact,icontains" sort:"true"`
+	LastName  string `json:"last_name" filter:"exact,icontains" sort:"true"`
+	Role      string `json:"role" filter:"exact,in"`
 
 	Password     string     `json:"-" gorm:"not null"`
 	RefreshToken *string    `json:"-" gorm:"uniqueIndex:uix_users_refresh_token"`
-	LastLogin    *time.Time `json:"last_login,omitempty" gorm:"type:datetime(3)"`
+	LastLogin    *time.Time `json:"last_login,omitempty"`
 
-	Phone           string     `json:"phone" gorm:"uniqueIndex:uix_users_phone"`
-	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty" gorm:"type:datetime(3)"`
+	Phone           string     `json:"phone" gorm:"uniqueIndex:uix_users_phone,priority:1" filter:"exact"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
 	OTP             *string    `json:"-" gorm:"varchar(10)"`
-	OTPSentAt       *time.Time `json:"-" gorm:"type:datetime(3)"`
-	Email           string     `json:"email" gorm:"uniqueIndex:uix_users_email"`
-	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" gorm:"type:datetime(3)"`
+	OTPSentAt       *time.Time `json:"-"`
+	Email           string     `json:"email" gorm:"uniqueIndex:uix_users_email,priority:1" filter:"exact,icontains" sort:"true"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	Status string `json:"status" gorm:"type:varchar(20);default:active" filter:"exact,in"` // active || blocked || deleted
 
-	Status string `json:"status" gorm:"type:varchar(20);default:active"` // active || blocked || deleted
+	// Soft-delete, a part of the unique indexes so emails & phones of deleted users can be reused
+	DeletedAt repoutil.DeletedAt `json:"-" gorm:"not null;default:0;uniqueIndex:uix_users_email,priority:2;uniqueIndex:uix_users_phone,priority:2"`
 }
@@ -0,0 +1,98 @@
+package rbac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Assertion represents an expected enforcement result, e.g. `alice, data1, read, allow`
+type Assertion struct {
+	// The request values, e.g. [sub, obj, act]
+	Request []string
+	// Whether the request is expected to be allowed
+	Allow bool
+	// The line number in the assertion file
+	Line int
+}
+
+// AssertionResult represents the result of running an assertion
+type AssertionResult struct {
+	Assertion
+	// The actual enforcement result
+	Got bool
+	// The enforcement error, e.g. the number of request values does not match the model
+	Err error
+}
+
+// Passed checks whether the actual result matches the expected one
+func (r AssertionResult) Passed() bool {
+	return r.Err == nil && r.Got == r.Allow
+}
+
+func (r AssertionResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("FAIL line %d: %s => error: %v", r.Line, strings.Join(r.Request, ", "), r.Err)
+	}
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+	return fmt.Sprintf("%s line %d: %s => got %s, want %s", status, r.Line, strings.Join(r.Request, ", "), effect(r.Got), effect(r.Allow))
+}
+
+// ParseAssertions parses assertions in the CSV format of casbin policy files.
+// Each line holds the request values followed by the expected effect `allow` or `deny`.
+// Blank lines and lines starting with `#` are ignored.
+func ParseAssertions(r io.Reader) ([]Assertion, error) {
+	var list []Assertion
+	scanner := bufio.NewScanner(r)
+	for ln := 1; scanner.Scan(); ln++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		values := strings.Split(line, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		if len(values) < 2 {
+			return nil, fmt.Errorf("line %d: expecting request values followed by allow or deny", ln)
+		}
+
+		a := Assertion{Request: values[:len(values)-1], Line: ln}
+		switch strings.ToLower(values[len(values)-1]) {
+		case "allow":
+			a.Allow = true
+		case "deny":
+			a.Allow = false
+		default:
+			return nil, fmt.Errorf("line %d: invalid effect %q, expecting allow or deny", ln, values[len(values)-1])
+		}
+		list = append(list, a)
+	}
+	return list, scanner.Err()
+}
+
+// RunAssertions enforces the requests of the given assertions and returns the results
+func (s *RBAC) RunAssertions(list []Assertion) []AssertionResult {
+	results := make([]AssertionResult, 0, len(list))
+	for _, a := range list {
+		rvals := make([]interface{}, 0, len(a.Request))
+		for _, v := range a.Request {
+			rvals = append(rvals, v)
+		}
+		got, err := s.EnforceSafe(rvals...)
+		results = append(results, AssertionResult{Assertion: a, Got: got, Err: err})
+	}
+	return results
+}
+
+func effect(allow bool) string {
+	if allow {
+		return "allow"
+	}
+	return "deny"
+}
@@ -0,0 +1,124 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/casbin/casbin"
+	"github.com/casbin/casbin/model"
+	"github.com/casbin/casbin/persist"
+	fileadapter "github.com/casbin/casbin/persist/file-adapter"
+
+	"runar-himmel/pkg/rbac/casbinadapter"
+)
+
+// NewFromFiles creates new RBAC service with the model and policy loaded from files, e.g. `rbac_model.conf` and `rbac_policy.csv`.
+// The `Model` of the given config is ignored. If an adapter (or GormDB) is configured,
+// its policies are loaded on top of the file ones, and runtime changes are saved there since the policy file is read-only.
+func NewFromFiles(cfg Config, modelPath, policyPath string) (*RBAC, error) {
+	if cfg.GormDB != nil {
+		cfg.Adapter = casbinadapter.NewAdapter(cfg.GormDB)
+	}
+
+	r := &RBAC{modelPath: modelPath, policyPath: policyPath}
+	r.loadedAt = r.filesModTime()
+	m, err := loadFiles(modelPath, policyPath, cfg.Adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	ce, err := casbin.NewEnforcerSafe(m, cfg.EnableLog)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: %w", err)
+	}
+	ce.SetAdapter(cfg.Adapter)
+	ce.BuildRoleLinks()
+
+	r.e = ce
+	return r, nil
+}
+
+// Reload reloads the model and policy from the files, plus the policies saved by the adapter.
+// The current ones are kept if the files are invalid. Without adapter, the runtime changes are lost.
+func (s *RBAC) Reload() error {
+	if s.modelPath == "" || s.policyPath == "" {
+		return fmt.Errorf("rbac: not loaded from files")
+	}
+
+	// loaded under the lock, so the runtime changes saved meanwhile are not lost
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	modTime := s.filesModTime()
+	m, err := loadFiles(s.modelPath, s.policyPath, s.e.GetAdapter())
+	if err != nil {
+		return err
+	}
+	s.loadedAt = modTime
+	s.e.SetModel(m)
+	s.e.BuildRoleLinks()
+	return nil
+}
+
+// WatchFiles checks the files for changes every interval and reloads them, until the context is done.
+// Reloading errors are reported to onError, which can be nil, then retried until the files are fixed.
+func (s *RBAC) WatchFiles(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// only advanced by the successful reloads
+			s.mu.RLock()
+			loadedAt := s.loadedAt
+			s.mu.RUnlock()
+			if !s.filesModTime().After(loadedAt) {
+				continue
+			}
+			if err := s.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// filesModTime returns the latest modification time of the model & policy files
+func (s *RBAC) filesModTime() (t time.Time) {
+	for _, p := range []string{s.modelPath, s.policyPath} {
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return
+}
+
+// loadFiles loads the model & policy files, plus the policies from the adapter if any.
+// Returns error instead of panicking on invalid files.
+func loadFiles(modelPath, policyPath string, adapter persist.Adapter) (m model.Model, err error) {
+	for _, p := range []string{modelPath, policyPath} {
+		if _, err := os.Stat(p); err != nil {
+			return nil, fmt.Errorf("rbac: %w", err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			m, err = nil, fmt.Errorf("rbac: invalid model or policy file: %v", r)
+		}
+	}()
+
+	m = casbin.NewModel(modelPath, "")
+	if err := fileadapter.NewAdapter(policyPath).LoadPolicy(m); err != nil {
+		return nil, fmt.Errorf("rbac: cannot load policy file: %w", err)
+	}
+	if adapter != nil {
+		if err := adapter.LoadPolicy(m); err != nil {
+			return nil, fmt.Errorf("rbac: cannot load policy from adapter: %w", err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,213 @@
+package rbac_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/rbac"
+	"runar-himmel/pkg/rbac/casbinadapter"
+	"runar-himmel/pkg/util/db/dbtest"
+)
+
+// copyTestFiles copies the model & policy test files into a temp dir, so they can be modified
+func copyTestFiles(t *testing.T) (modelPath, policyPath string) {
+	dir := t.TempDir()
+	modelPath, policyPath = filepath.Join(dir, "rbac_model.conf"), filepath.Join(dir, "rbac_policy.csv")
+	for src, dst := range map[string]string{"testdata/rbac_model.conf": modelPath, "testdata/rbac_policy.csv": policyPath} {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0o644))
+	}
+	return
+}
+
+func TestNewFromFiles(t *testing.T) {
+	_, err := rbac.NewFromFiles(rbac.Config{}, "testdata/missing.conf", "testdata/rbac_policy.csv")
+	assert.Error(t, err)
+
+	r, err := rbac.NewFromFiles(rbac.Config{}, "testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	require.NoError(t, err)
+	assert.True(t, r.Enforce("alice", "data1", "read"))
+	assert.True(t, r.Enforce("alice", "data2", "write"))
+	assert.False(t, r.Enforce("bob", "data1", "read"))
+}
+
+func TestNewFromFilesWithDB(t *testing.T) {
+	db := dbtest.New(t, &casbinadapter.CasbinRule{})
+	r, err := rbac.NewFromFiles(rbac.Config{GormDB: db}, "testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	require.NoError(t, err)
+
+	// runtime changes are saved to the database and survive reloading
+	assert.True(t, r.AddRoleForUser("bob", "data2_admin"))
+	assert.True(t, r.Enforce("bob", "data2", "read"))
+	require.NoError(t, r.Reload())
+	assert.True(t, r.Enforce("bob", "data2", "read"))
+	assert.True(t, r.Enforce("alice", "data1", "read"))
+}
+
+func TestReload(t *testing.T) {
+	modelPath, policyPath := copyTestFiles(t)
+	r, err := rbac.NewFromFiles(rbac.Config{}, modelPath, policyPath)
+	require.NoError(t, err)
+	assert.False(t, r.Enforce("bob", "data1", "read"))
+
+	f, err := os.OpenFile(policyPath, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("p, bob, data1, read\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, r.Reload())
+	assert.True(t, r.Enforce("bob", "data1", "read"))
+
+	// invalid model keeps the current one
+	require.NoError(t, os.WriteFile(modelPath, []byte("[matchers]\nm = r.sub ==\n"), 0o644))
+	assert.Error(t, r.Reload())
+	assert.True(t, r.Enforce("bob", "data1", "read"))
+
+	// not loaded from files
+	assert.Error(t, rbac.NewWithConfig(rbac.DefaultConfig).Reload())
+}
+
+func TestWatchFiles(t *testing.T) {
+	modelPath, policyPath := copyTestFiles(t)
+	r, err := rbac.NewFromFiles(rbac.Config{}, modelPath, policyPath)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.WatchFiles(ctx, 10*time.Millisecond, nil)
+
+	data, err := os.ReadFile(policyPath)
+	require.NoError(t, err)
+	data = []byte(strings.Replace(string(data), "p, alice, data1, read", "p, alice, data1, write", 1))
+	require.NoError(t, os.WriteFile(policyPath, data, 0o644))
+	// make sure the modification time changes on filesystems with coarse timestamps
+	require.NoError(t, os.Chtimes(policyPath, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+
+	assert.Eventually(t, func() bool {
+		return r.Enforce("alice", "data1", "write") && !r.Enforce("alice", "data1", "read")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchFilesRetry(t *testing.T) {
+	modelPath, policyPath := copyTestFiles(t)
+	r, err := rbac.NewFromFiles(rbac.Config{}, modelPath, policyPath)
+	require.NoError(t, err)
+	model, err := os.ReadFile(modelPath)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 100)
+	go r.WatchFiles(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+
+	// the policy & the invalid model are changed at once
+	modTime := time.Now().Add(time.Second)
+	require.NoError(t, os.WriteFile(policyPath, []byte("p, bob, data1, read\n"), 0o644))
+	require.NoError(t, os.WriteFile(modelPath, []byte("[matchers]\nm = r.sub ==\n"), 0o644))
+	require.NoError(t, os.Chtimes(policyPath, modTime, modTime))
+	require.NoError(t, os.Chtimes(modelPath, modTime, modTime))
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("the invalid model is not reported")
+	}
+	assert.False(t, r.Enforce("bob", "data1", "read"))
+
+	// retried once fixed, even if the modification time is the same
+	require.NoError(t, os.WriteFile(modelPath, model, 0o644))
+	require.NoError(t, os.Chtimes(modelPath, modTime, modTime))
+	assert.Eventually(t, func() bool { return r.Enforce("bob", "data1", "read") }, time.Second, 10*time.Millisecond)
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	modelPath, policyPath := copyTestFiles(t)
+	r, err := rbac.NewFromFiles(rbac.Config{GormDB: dbtest.New(t, &casbinadapter.CasbinRule{})}, modelPath, policyPath)
+	require.NoError(t, err)
+
+	// the policy management, e.g. by the admin API, runs along with reloading the files
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := fmt.Sprintf("user%d", i)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				r.AddPolicy(user, "data3", "read")
+				r.AddGroupingPolicy(user, "data2_admin")
+				r.Enforce(user, "data3", "read")
+				r.GetPolicy()
+				r.GetFilteredPolicy(0, user)
+				r.GetRolesForUserInDomain(user, "")
+				r.RemoveGroupingPolicy(user, "data2_admin")
+				r.RemovePolicy(user, "data3", "read")
+			}
+		}(i)
+	}
+	// the policies added while reloading are not lost
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		for i := 0; i < 50; i++ {
+			r.AddPolicy(fmt.Sprintf("editor%d", i), "data4", "write")
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, r.Reload())
+	}
+	close(done)
+	wg.Wait()
+	<-added
+
+	assert.True(t, r.Enforce("alice", "data1", "read"))
+	for i := 0; i < 50; i++ {
+		assert.True(t, r.Enforce(fmt.Sprintf("editor%d", i), "data4", "write"), i)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	f, err := os.Open("testdata/rbac_assertions.csv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	list, err := rbac.ParseAssertions(f)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, rbac.Assertion{Request: []string{"alice", "data1", "read"}, Allow: true, Line: 2}, list[0])
+
+	r, err := rbac.NewFromFiles(rbac.Config{}, "testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	require.NoError(t, err)
+	for _, res := range r.RunAssertions(list) {
+		assert.True(t, res.Passed(), res.String())
+	}
+
+	// wrong expectation & wrong number of request values
+	results := r.RunAssertions([]rbac.Assertion{
+		{Request: []string{"bob", "data1", "read"}, Allow: true, Line: 1},
+		{Request: []string{"bob", "data1"}, Allow: false, Line: 2},
+	})
+	assert.False(t, results[0].Passed())
+	assert.False(t, results[1].Passed())
+	assert.Error(t, results[1].Err)
+
+	_, err = rbac.ParseAssertions(strings.NewReader("alice, data1, read, maybe"))
+	assert.Error(t, err)
+	_, err = rbac.ParseAssertions(strings.NewReader("allow"))
+	assert.Error(t, err)
+}
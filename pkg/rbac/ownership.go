@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// Subject represents the requester to be authorized, usually built from the access token claims
+type Subject struct {
+	// The user ID, which is compared with the owner of the records
+	ID string
+	// The domain (organization) of the request, empty if the model has no domain
+	Domain string
+	// Additional roles granted to the subject regardless of the assignments, e.g. from the token claims
+	Roles []string
+}
+
+// EnforceSubject determines whether the subject, or any of its additional roles, has permission to do an action on an object
+func (s *RBAC) EnforceSubject(sub Subject, obj, act string) bool {
+	enforce := func(name string) bool {
+		if sub.Domain != "" {
+			return s.EnforceUserIDInDomain(name, sub.Domain, obj, act)
+		}
+		return s.EnforceUserID(name, obj, act)
+	}
+
+	if sub.ID != "" && enforce(sub.ID) {
+		return true
+	}
+	for _, role := range sub.Roles {
+		if enforce(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllAction returns the action that applies to all records instead of the owned ones only, e.g. `view` => `view_all`
+func AllAction(act string) string {
+	return act + "_all"
+}
+
+// SubjectEnforcer represents the interface to enforce the permissions of a subject
+type SubjectEnforcer interface {
+	EnforceSubject(sub Subject, obj, act string) bool
+}
+
+// NewOwnership creates new ownership authorizer for the given object, the owner ID is read from the `UserID` field
+func NewOwnership[T any](repo *repoutil.Repo[T], enforcer SubjectEnforcer, obj string) *Ownership[T] {
+	return &Ownership[T]{
+		Repo:       repo,
+		Enforcer:   enforcer,
+		Object:     obj,
+		OwnerField: "UserID",
+	}
+}
+
+// Ownership authorizes actions on records of type T, considering whether the records belong to the subject.
+// The subject may act on its own records with the given action (e.g. `view`),
+// otherwise the corresponding `*_all` action (e.g. `view_all`) is required.
+type Ownership[T any] struct {
+	Repo     *repoutil.Repo[T]
+	Enforcer SubjectEnforcer
+	// The RBAC object, e.g. `memo`
+	Object string
+	// The struct field holding the owner ID
+	OwnerField string
+}
+
+// Authorize loads the record by ID then checks whether the subject is allowed to do the action on it.
+// Returns the loaded record on success, the repo error if not found, or ErrForbiddenAction.
+func (o *Ownership[T]) Authorize(ctx context.Context, sub Subject, act string, id string) (*T, error) {
+	rec := new(T)
+	if err := o.Repo.ReadByID(ctx, rec, id); err != nil {
+		return nil, err
+	}
+	ok, err := o.Can(sub, act, rec)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrForbiddenAction
+	}
+	return rec, nil
+}
+
+// Can checks whether the subject is allowed to do the action on the given record.
+// Returns an error if the owner field is misconfigured.
+func (o *Ownership[T]) Can(sub Subject, act string, rec *T) (bool, error) {
+	if sub.ID != "" {
+		ownerID, err := o.OwnerID(rec)
+		if err != nil {
+			return false, err
+		}
+		if ownerID == sub.ID && o.Enforcer.EnforceSubject(sub, o.Object, act) {
+			return true, nil
+		}
+	}
+	return o.Enforcer.EnforceSubject(sub, o.Object, AllAction(act)), nil
+}
+
+// Filter returns the conditions to list the records that the subject is allowed to do the action on:
+// nil for all records, the owner condition for the owned records, or ErrForbiddenAction.
+func (o *Ownership[T]) Filter(sub Subject, act string) ([]any, error) {
+	if o.Enforcer.EnforceSubject(sub, o.Object, AllAction(act)) {
+		return nil, nil
+	}
+	if sub.ID != "" && o.Enforcer.EnforceSubject(sub, o.Object, act) {
+		if _, err := o.ownerField(reflect.TypeOf((*T)(nil)).Elem()); err != nil {
+			return nil, err
+		}
+		col := o.Repo.GDB.NamingStrategy.ColumnName("", o.OwnerField)
+		return []any{col + " = ?", sub.ID}, nil
+	}
+	return nil, ErrForbiddenAction
+}
+
+// OwnerID returns the owner ID of the given record, or an error if the owner field is misconfigured
+func (o *Ownership[T]) OwnerID(rec *T) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(rec))
+	idx, err := o.ownerField(v.Type())
+	if err != nil {
+		return "", err
+	}
+	f := v.FieldByIndex(idx)
+	if f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			return "", nil
+		}
+		f = f.Elem()
+	}
+	return fmt.Sprint(f.Interface()), nil
+}
+
+// ownerField returns the index of the owner field in the struct type
+func (o *Ownership[T]) ownerField(t reflect.Type) ([]int, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rbac: %s is not a struct", t)
+	}
+	f, ok := t.FieldByName(o.OwnerField)
+	if !ok {
+		return nil, fmt.Errorf("rbac: owner field %q not found in %s", o.OwnerField, t)
+	}
+	return f.Index, nil
+}
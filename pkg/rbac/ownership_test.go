@@ -0,0 +1,88 @@
+package rbac_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/rbac"
+	"runar-himmel/pkg/rbac/casbinadapter"
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type memo struct {
+	ID      string `gorm:"primaryKey"`
+	UserID  string
+	Content string
+}
+
+func TestOwnership(t *testing.T) {
+	db := dbtest.New(t, &casbinadapter.CasbinRule{}, &memo{})
+	repo := repoutil.NewRepo[memo](db)
+	ctx := context.Background()
+	assert.NoError(t, repo.CreateInBatches(ctx, []memo{{ID: "1", UserID: userA}, {ID: "2", UserID: userB}}, 10))
+
+	r := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	r.AddPolicy("customer", "*", "memo", "view")
+	r.AddPolicy("admin", "*", "memo", "view_all")
+	r.AddPolicy("superadmin", "*", "*", "*")
+	r.AddRoleForUserIDInDomain(userA, "customer", "org1")
+	r.AddRoleForUserIDInDomain(userB, "admin", "org1")
+
+	o := rbac.NewOwnership[memo](repo, r, "memo")
+	customer := rbac.Subject{ID: userA, Domain: "org1"}
+	admin := rbac.Subject{ID: userB, Domain: "org1"}
+	outsider := rbac.Subject{ID: userA, Domain: "org2"}
+	superadmin := rbac.Subject{ID: "god", Domain: "org2", Roles: []string{"superadmin"}}
+
+	rec, err := o.Authorize(ctx, customer, "view", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", rec.ID)
+	_, err = o.Authorize(ctx, customer, "view", "2")
+	assert.ErrorIs(t, err, rbac.ErrForbiddenAction)
+	_, err = o.Authorize(ctx, customer, "update", "1")
+	assert.ErrorIs(t, err, rbac.ErrForbiddenAction)
+	_, err = o.Authorize(ctx, customer, "view", "3")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = o.Authorize(ctx, admin, "view", "1")
+	assert.NoError(t, err)
+	_, err = o.Authorize(ctx, outsider, "view", "1")
+	assert.ErrorIs(t, err, rbac.ErrForbiddenAction)
+	_, err = o.Authorize(ctx, superadmin, "delete", "1")
+	assert.NoError(t, err)
+
+	conds, err := o.Filter(customer, "view")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"user_id = ?", userA}, conds)
+	conds, err = o.Filter(admin, "view")
+	assert.NoError(t, err)
+	assert.Nil(t, conds)
+	_, err = o.Filter(outsider, "view")
+	assert.ErrorIs(t, err, rbac.ErrForbiddenAction)
+}
+
+func TestOwnershipInvalidField(t *testing.T) {
+	db := dbtest.New(t, &casbinadapter.CasbinRule{}, &memo{})
+	repo := repoutil.NewRepo[memo](db)
+	ctx := context.Background()
+	assert.NoError(t, repo.Create(ctx, &memo{ID: "1", UserID: userA}))
+
+	r := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	r.AddPolicy("customer", "*", "memo", "view")
+	r.AddRoleForUserIDInDomain(userA, "customer", "org1")
+
+	o := rbac.NewOwnership[memo](repo, r, "memo")
+	o.OwnerField = "AuthorID"
+	customer := rbac.Subject{ID: userA, Domain: "org1"}
+
+	_, err := o.OwnerID(&memo{ID: "1"})
+	assert.ErrorContains(t, err, `owner field "AuthorID" not found`)
+	_, err = o.Authorize(ctx, customer, "view", "1")
+	assert.ErrorContains(t, err, `owner field "AuthorID" not found`)
+	_, err = o.Filter(customer, "view")
+	assert.ErrorContains(t, err, `owner field "AuthorID" not found`)
+}
@@ -1,57 +1,131 @@
 package rbac
 
+// User IDs are strings to support both ULID models and numeric IDs, use NormalizeUser to convert the latter.
+
 // AddRoleForUserID adds a role for a user by ID. Returns false if the user already has the role (aka not affected).
-func (s *RBAC) AddRoleForUserID(uid int, role string) bool {
-	return s.Enforcer.AddRoleForUser(NormalizeUser(uid), role)
+func (s *RBAC) AddRoleForUserID(uid string, role string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddRoleForUser(NormalizeUser(uid), role)
 }
 
 // GetRolesForUserID gets the roles that a user has.
-func (s *RBAC) GetRolesForUserID(uid int) []string {
-	roles, _ := s.Enforcer.GetRolesForUser(NormalizeUser(uid))
+func (s *RBAC) GetRolesForUserID(uid string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	roles, _ := s.e.GetRolesForUser(NormalizeUser(uid))
 	return roles
 }
 
 // ReplaceRoleForUserID removes all current roles then adds the new role for a user ID
-func (s *RBAC) ReplaceRoleForUserID(uid int, role string) bool {
-	s.DeleteRolesForUserID(uid)
-	return s.AddRoleForUserID(uid, role)
+func (s *RBAC) ReplaceRoleForUserID(uid string, role string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.e.DeleteRolesForUser(NormalizeUser(uid))
+	return s.e.AddRoleForUser(NormalizeUser(uid), role)
 }
 
 // DeleteRoleForUserID deletes a role for a user ID. Returns false if the user does not have the role (aka not affected).
-func (s *RBAC) DeleteRoleForUserID(uid int, role string) bool {
-	return s.Enforcer.DeleteRoleForUser(NormalizeUser(uid), role)
+func (s *RBAC) DeleteRoleForUserID(uid string, role string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.DeleteRoleForUser(NormalizeUser(uid), role)
 }
 
 // DeleteRolesForUserID delete all roles for a user ID. Returns false if the user does not have any roles (aka not affected).
-func (s *RBAC) DeleteRolesForUserID(uid int) bool {
-	return s.Enforcer.DeleteRolesForUser(NormalizeUser(uid))
+func (s *RBAC) DeleteRolesForUserID(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.DeleteRolesForUser(NormalizeUser(uid))
 }
 
 // DeleteUserID deletes a user ID. Returns false if the user does not exist (aka not affected).
-func (s *RBAC) DeleteUserID(uid int) bool {
-	return s.Enforcer.DeleteUser(NormalizeUser(uid))
+func (s *RBAC) DeleteUserID(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.DeleteUser(NormalizeUser(uid))
 }
 
 // HasRoleForUserID determines whether a user has a role.
-func (s *RBAC) HasRoleForUserID(uid int, role string) bool {
-	has, _ := s.Enforcer.HasRoleForUser(NormalizeUser(uid), role)
+func (s *RBAC) HasRoleForUserID(uid string, role string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	has, _ := s.e.HasRoleForUser(NormalizeUser(uid), role)
 	return has
 }
 
 // EnforceUserID determines whether a user ID has permission to do stuff
-func (s *RBAC) EnforceUserID(uid int, rvals ...interface{}) bool {
+func (s *RBAC) EnforceUserID(uid string, rvals ...interface{}) bool {
 	rvals = append([]interface{}{NormalizeUser(uid)}, rvals...)
-	return s.Enforcer.Enforce(rvals...)
+	return s.Enforce(rvals...)
 }
 
 // AddGroupingPolicy2 adds a role inheritance rule to the current policy.
 // If the rule already exists, the function returns false and the rule will not be added.
 // Otherwise the function returns true by adding the new rule.
 func (s *RBAC) AddGroupingPolicy2(params ...interface{}) bool {
-	return s.Enforcer.AddNamedGroupingPolicy("g2", params...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddNamedGroupingPolicy("g2", params...)
 }
 
 // RemoveGroupingPolicy2 removes a role inheritance rule from the current policy.
 func (s *RBAC) RemoveGroupingPolicy2(params ...interface{}) bool {
-	return s.Enforcer.RemoveNamedGroupingPolicy("g2", params...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.RemoveNamedGroupingPolicy("g2", params...)
+}
+
+///// Domain-aware functions, to be used with NewRBACWithDomainModel /////
+
+// AddRoleForUserIDInDomain adds a role for a user ID inside a domain. Returns false if the user already has the role (aka not affected).
+func (s *RBAC) AddRoleForUserIDInDomain(uid string, role, domain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddRoleForUserInDomain(NormalizeUser(uid), role, domain)
+}
+
+// GetRolesForUserIDInDomain gets the roles that a user ID has inside a domain.
+func (s *RBAC) GetRolesForUserIDInDomain(uid string, domain string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetRolesForUserInDomain(NormalizeUser(uid), domain)
+}
+
+// ReplaceRoleForUserIDInDomain removes all current roles inside a domain then adds the new role for a user ID
+func (s *RBAC) ReplaceRoleForUserIDInDomain(uid string, role, domain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.e.RemoveFilteredGroupingPolicy(0, NormalizeUser(uid), "", domain)
+	return s.e.AddRoleForUserInDomain(NormalizeUser(uid), role, domain)
+}
+
+// DeleteRoleForUserIDInDomain deletes a role for a user ID inside a domain. Returns false if the user does not have the role (aka not affected).
+func (s *RBAC) DeleteRoleForUserIDInDomain(uid string, role, domain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.DeleteRoleForUserInDomain(NormalizeUser(uid), role, domain)
+}
+
+// DeleteRolesForUserIDInDomain deletes all roles for a user ID inside a domain. Returns false if the user does not have any roles (aka not affected).
+func (s *RBAC) DeleteRolesForUserIDInDomain(uid string, domain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.RemoveFilteredGroupingPolicy(0, NormalizeUser(uid), "", domain)
+}
+
+// HasRoleForUserIDInDomain determines whether a user ID has a role inside a domain.
+func (s *RBAC) HasRoleForUserIDInDomain(uid string, role, domain string) bool {
+	for _, r := range s.GetRolesForUserIDInDomain(uid, domain) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// EnforceUserIDInDomain determines whether a user ID has permission to do stuff inside a domain
+func (s *RBAC) EnforceUserIDInDomain(uid string, domain string, rvals ...interface{}) bool {
+	rvals = append([]interface{}{NormalizeUser(uid), domain}, rvals...)
+	return s.Enforce(rvals...)
 }
@@ -0,0 +1,99 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"runar-himmel/pkg/rbac"
+	"runar-himmel/pkg/rbac/casbinadapter"
+	"runar-himmel/pkg/util/db/dbtest"
+)
+
+const (
+	userA = "01HKQ8ZJ4N0S7V2DXP5W1Y3T6M"
+	userB = "01HKQ8ZJ4N0S7V2DXP5W1Y3T6N"
+)
+
+func TestNormalizeUser(t *testing.T) {
+	type ID string
+
+	assert.Equal(t, userA, rbac.NormalizeUser(userA))
+	assert.Equal(t, userA, rbac.NormalizeUser(ID(userA)))
+	assert.Equal(t, "42", rbac.NormalizeUser(42))
+	assert.Equal(t, "42", rbac.NormalizeUser(int64(42)))
+	assert.Equal(t, "42", rbac.NormalizeUser(uint(42)))
+}
+
+func TestUserID(t *testing.T) {
+	db := dbtest.New(t, &casbinadapter.CasbinRule{})
+	r := rbac.NewWithConfig(rbac.Config{GormDB: db, EnableLog: false})
+	r.AddPolicy("admin", "user", "view_all")
+	r.AddPolicy("customer", "user", "view")
+
+	assert.True(t, r.AddRoleForUserID(userA, "admin"))
+	assert.False(t, r.AddRoleForUserID(userA, "admin"))
+	assert.True(t, r.AddRoleForUserID(userB, "customer"))
+
+	assert.Equal(t, []string{"admin"}, r.GetRolesForUserID(userA))
+	assert.True(t, r.HasRoleForUserID(userA, "admin"))
+	assert.False(t, r.HasRoleForUserID(userA, "customer"))
+
+	assert.True(t, r.EnforceUserID(userA, "user", "view_all"))
+	assert.False(t, r.EnforceUserID(userB, "user", "view_all"))
+	assert.True(t, r.EnforceUserID(userB, "user", "view"))
+
+	assert.True(t, r.ReplaceRoleForUserID(userB, "admin"))
+	assert.Equal(t, []string{"admin"}, r.GetRolesForUserID(userB))
+
+	// changes must be persisted by the adapter
+	reloaded := rbac.NewWithConfig(rbac.Config{GormDB: db, EnableLog: false})
+	assert.Equal(t, []string{"admin"}, reloaded.GetRolesForUserID(userA))
+	assert.Equal(t, []string{"admin"}, reloaded.GetRolesForUserID(userB))
+
+	assert.True(t, r.DeleteRoleForUserID(userA, "admin"))
+	assert.False(t, r.DeleteRoleForUserID(userA, "admin"))
+	assert.True(t, r.DeleteUserID(userB))
+	assert.False(t, r.EnforceUserID(userB, "user", "view_all"))
+
+	reloaded = rbac.NewWithConfig(rbac.Config{GormDB: db, EnableLog: false})
+	assert.Empty(t, reloaded.GetRolesForUserID(userA))
+	assert.Empty(t, reloaded.GetRolesForUserID(userB))
+}
+
+func TestUserIDInDomain(t *testing.T) {
+	db := dbtest.New(t, &casbinadapter.CasbinRule{})
+	r := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	r.AddPolicy("admin", "org1", "user", "view_all")
+	r.AddPolicy("admin", "org2", "user", "view_all")
+	r.AddPolicy("customer", "org2", "user", "view")
+
+	assert.True(t, r.AddRoleForUserIDInDomain(userA, "admin", "org1"))
+	assert.True(t, r.AddRoleForUserIDInDomain(userA, "customer", "org2"))
+	assert.False(t, r.AddRoleForUserIDInDomain(userA, "customer", "org2"))
+
+	assert.Equal(t, []string{"admin"}, r.GetRolesForUserIDInDomain(userA, "org1"))
+	assert.Equal(t, []string{"customer"}, r.GetRolesForUserIDInDomain(userA, "org2"))
+	assert.True(t, r.HasRoleForUserIDInDomain(userA, "admin", "org1"))
+	assert.False(t, r.HasRoleForUserIDInDomain(userA, "admin", "org2"))
+
+	assert.True(t, r.EnforceUserIDInDomain(userA, "org1", "user", "view_all"))
+	assert.False(t, r.EnforceUserIDInDomain(userA, "org2", "user", "view_all"))
+	assert.True(t, r.EnforceUserIDInDomain(userA, "org2", "user", "view"))
+
+	assert.True(t, r.ReplaceRoleForUserIDInDomain(userA, "admin", "org2"))
+	assert.True(t, r.EnforceUserIDInDomain(userA, "org2", "user", "view_all"))
+	assert.Equal(t, []string{"admin"}, r.GetRolesForUserIDInDomain(userA, "org1"))
+
+	reloaded := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	assert.Equal(t, []string{"admin"}, reloaded.GetRolesForUserIDInDomain(userA, "org2"))
+
+	assert.True(t, r.DeleteRoleForUserIDInDomain(userA, "admin", "org1"))
+	assert.False(t, r.EnforceUserIDInDomain(userA, "org1", "user", "view_all"))
+	assert.True(t, r.DeleteRolesForUserIDInDomain(userA, "org2"))
+	assert.Empty(t, r.GetRolesForUserIDInDomain(userA, "org2"))
+
+	reloaded = rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	assert.Empty(t, reloaded.GetRolesForUserIDInDomain(userA, "org1"))
+	assert.Empty(t, reloaded.GetRolesForUserIDInDomain(userA, "org2"))
+}
@@ -2,6 +2,8 @@ package rbac
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/casbin/casbin"
 	"github.com/casbin/casbin/model"
@@ -26,9 +28,18 @@ type Config struct {
 	EnableLog bool
 }
 
-// RBAC is RBAC application service
+// RBAC is RBAC application service.
+// It is safe for concurrent use, the casbin enforcer is only accessed through the methods guarded by the lock.
 type RBAC struct {
-	*casbin.Enforcer
+	e *casbin.Enforcer
+
+	// guards the enforcer, as casbin is not thread-safe and the model & policy files may be reloaded
+	mu sync.RWMutex
+	// the model & policy files, see NewFromFiles
+	modelPath  string
+	policyPath string
+	// the latest modification time of the files when they were loaded
+	loadedAt time.Time
 }
 
 // Intf represents common interface for the RBAC service
@@ -52,7 +63,8 @@ func New() *RBAC {
 // NewWithConfig creates new RBAC service with custom configuration
 func NewWithConfig(cfg Config) *RBAC {
 	if cfg.Model == nil {
-		cfg.Model = DefaultConfig.Model
+		// casbin keeps the policies inside the model, so each enforcer needs its own copy
+		cfg.Model = NewRBACModel()
 	}
 	if cfg.GormDB == nil {
 		cfg.GormDB = DefaultConfig.GormDB
@@ -70,7 +82,126 @@ func NewWithConfig(cfg Config) *RBAC {
 		ce = casbin.NewEnforcer(cfg.Model, cfg.EnableLog)
 	}
 
-	return &RBAC{ce}
+	return &RBAC{e: ce}
+}
+
+// Enforce decides whether a "subject" can access an "object" with the operation "action"
+func (s *RBAC) Enforce(rvals ...interface{}) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.Enforce(rvals...)
+}
+
+// EnforceSafe calls Enforce in a safe way, returns error instead of causing panic
+func (s *RBAC) EnforceSafe(rvals ...interface{}) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.EnforceSafe(rvals...)
+}
+
+// GetPolicy gets all the authorization rules in the policy
+func (s *RBAC) GetPolicy() [][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetPolicy()
+}
+
+// GetFilteredPolicy gets all the authorization rules in the policy, field filters can be specified
+func (s *RBAC) GetFilteredPolicy(fieldIndex int, fieldValues ...string) [][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetFilteredPolicy(fieldIndex, fieldValues...)
+}
+
+// HasPolicy determines whether an authorization rule exists
+func (s *RBAC) HasPolicy(params ...interface{}) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.HasPolicy(params...)
+}
+
+// AddPolicy adds an authorization rule to the current policy. Returns false if the rule already exists.
+func (s *RBAC) AddPolicy(params ...interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddPolicy(params...)
+}
+
+// RemovePolicy removes an authorization rule from the current policy. Returns false if the rule does not exist.
+func (s *RBAC) RemovePolicy(params ...interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.RemovePolicy(params...)
+}
+
+// GetGroupingPolicy gets all the role inheritance rules in the policy
+func (s *RBAC) GetGroupingPolicy() [][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetGroupingPolicy()
+}
+
+// GetFilteredGroupingPolicy gets all the role inheritance rules in the policy, field filters can be specified
+func (s *RBAC) GetFilteredGroupingPolicy(fieldIndex int, fieldValues ...string) [][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetFilteredGroupingPolicy(fieldIndex, fieldValues...)
+}
+
+// AddGroupingPolicy adds a role inheritance rule to the current policy. Returns false if the rule already exists.
+func (s *RBAC) AddGroupingPolicy(params ...interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddGroupingPolicy(params...)
+}
+
+// RemoveGroupingPolicy removes a role inheritance rule from the current policy. Returns false if the rule does not exist.
+func (s *RBAC) RemoveGroupingPolicy(params ...interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.RemoveGroupingPolicy(params...)
+}
+
+// AddRoleForUser adds a role for a user. Returns false if the user already has the role.
+func (s *RBAC) AddRoleForUser(user string, role string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.AddRoleForUser(user, role)
+}
+
+// GetRolesForUser gets the roles that a user has
+func (s *RBAC) GetRolesForUser(name string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetRolesForUser(name)
+}
+
+// GetRolesForUserInDomain gets the roles that a user has inside a domain
+func (s *RBAC) GetRolesForUserInDomain(name string, domain string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.GetRolesForUserInDomain(name, domain)
+}
+
+// LoadPolicy reloads the policy from the adapter
+func (s *RBAC) LoadPolicy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.LoadPolicy()
+}
+
+// SavePolicy saves the current policy to the adapter
+func (s *RBAC) SavePolicy() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.e.SavePolicy()
+}
+
+// PrintPolicy prints the current policy to the casbin log
+func (s *RBAC) PrintPolicy() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.e.GetModel().PrintPolicy()
 }
 
 // NewRBACModel initializes the RBAC casbin model
@@ -96,13 +227,14 @@ func NewRBACWithLevelInheritanceModel() model.Model {
 	return m
 }
 
-// NewRBACWithDomainModel initializes the RBAC with domain model
+// NewRBACWithDomainModel initializes the RBAC with domain model.
+// Policies with `*` domain apply to all domains, while roles are always assigned per domain.
 func NewRBACWithDomainModel() model.Model {
 	m := casbin.NewModel()
 	m.AddDef("r", "r", "sub, dom, obj, act")
 	m.AddDef("p", "p", "sub, dom, obj, act")
 	m.AddDef("g", "g", "_, _, _")
 	m.AddDef("e", "e", "some(where (p.eft == allow))")
-	m.AddDef("m", "m", `g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act`)
+	m.AddDef("m", "m", `g(r.sub, p.sub, r.dom) && (r.dom == p.dom || p.dom == "*") && (r.obj == p.obj || p.obj == "*") && (r.act == p.act || p.act == "*")`)
 	return m
 }
@@ -1,9 +1,15 @@
 package rbac
 
 import (
+	"fmt"
 	"strconv"
 )
 
+// UserID represents the supported types of user ID
+type UserID interface {
+	~string | ~int | ~int64 | ~uint | ~uint64
+}
+
 // NormalizeRole corrects role ID for RBAC service
 func NormalizeRole(r int) string {
 	return "r" + strconv.Itoa(r)
@@ -15,7 +21,21 @@ func UnnormalizeRole(r string) int {
 	return iRole
 }
 
-// NormalizeUser corrects user ID for RBAC service
-func NormalizeUser(uid int) string {
-	return strconv.Itoa(uid)
+// NormalizeUser corrects user ID for RBAC service. String IDs (e.g. ULID) are kept as is.
+func NormalizeUser[T UserID](uid T) string {
+	switch v := any(uid).(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	}
+
+	// named types, e.g. `type ID string`
+	return fmt.Sprint(uid)
 }
@@ -17,6 +17,10 @@ const (
 	GenericErrorType = "GENERIC"
 	// ValidationErrorType type of common errors
 	ValidationErrorType = "VALIDATION"
+	// ConflictErrorType type of errors caused by concurrent changes or duplicated records
+	ConflictErrorType = "CONFLICT"
+	// NotFoundErrorType type of errors caused by missing records
+	NotFoundErrorType = "NOT_FOUND"
 )
 
 // ErrorResponse represents the error response
@@ -87,14 +91,19 @@ func (he *HTTPError) MarshalJSON() ([]byte, error) {
 	return json.Marshal(output)
 }
 
+// ErrorTranslator converts the known errors of lower layers into HTTPError, e.g. missing records into 404.
+// It returns nil for the unknown errors, which are left to the next translator.
+type ErrorTranslator func(err error) *HTTPError
+
 // ErrorHandler represents the custom http error handler
 type ErrorHandler struct {
-	e *echo.Echo
+	e           *echo.Echo
+	translators []ErrorTranslator
 }
 
-// NewErrorHandler returns the ErrorHandler instance
-func NewErrorHandler(e *echo.Echo) *ErrorHandler {
-	return &ErrorHandler{e}
+// NewErrorHandler returns the ErrorHandler instance, the errors other than HTTPError are given to the translators in order
+func NewErrorHandler(e *echo.Echo, translators ...ErrorTranslator) *ErrorHandler {
+	return &ErrorHandler{e: e, translators: translators}
 }
 
 // Handle is a centralized HTTP error handler.
@@ -148,7 +157,9 @@ func (ce *ErrorHandler) Handle(err error, c echo.Context) {
 		}
 		httpErr.Message = strings.Join(errMsg, "\n")
 	default:
-		if ce.e.Debug {
+		if he := ce.translate(err); he != nil {
+			httpErr = he
+		} else if ce.e.Debug {
 			httpErr.Message = err.Error()
 		}
 	}
@@ -172,6 +183,16 @@ func (ce *ErrorHandler) Handle(err error, c echo.Context) {
 	}
 }
 
+// translate returns the HTTPError of the first translator knowing the error, nil if none
+func (ce *ErrorHandler) translate(err error) *HTTPError {
+	for _, t := range ce.translators {
+		if he := t(err); he != nil {
+			return he
+		}
+	}
+	return nil
+}
+
 var validationErrors = map[string]string{
 	"required":   " is required, but was not received",
 	"min":        "'s value or length is less than allowed",
@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"runar-himmel/pkg/server"
+)
+
+var errMissing = errors.New("missing")
+
+func handleError(err error, translators ...server.ErrorTranslator) *httptest.ResponseRecorder {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	server.NewErrorHandler(e, translators...).Handle(err, c)
+	return rec
+}
+
+func TestErrorHandler(t *testing.T) {
+	rec := handleError(server.NewHTTPValidationError("invalid"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = handleError(errors.New("unknown"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestErrorHandlerTranslators(t *testing.T) {
+	unknown := func(error) *server.HTTPError { return nil }
+	notFound := func(err error) *server.HTTPError {
+		if errors.Is(err, errMissing) {
+			return server.NewHTTPError(http.StatusNotFound, server.NotFoundErrorType, "Record not found")
+		}
+		return nil
+	}
+
+	// the first translator knowing the error wins
+	rec := handleError(fmt.Errorf("reading memo: %w", errMissing), unknown, notFound)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"type":"NOT_FOUND"`)
+
+	rec = handleError(errors.New("unknown"), unknown, notFound)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
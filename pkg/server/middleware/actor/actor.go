@@ -0,0 +1,23 @@
+package actor
+
+import (
+	"github.com/labstack/echo/v4"
+
+	auditutil "runar-himmel/pkg/util/audit"
+)
+
+// Middleware attributes the changes made by the request to the user ID from the given claim and the client IP,
+// see auditutil.Use. It must be placed after the JWT middleware, which sets the claims into the context.
+// Requests without the claim, e.g. signing in, are recorded with the IP only.
+func Middleware(claim string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor := auditutil.Actor{IP: c.RealIP()}
+			actor.ID, _ = c.Get(claim).(string)
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(auditutil.WithActor(req.Context(), actor)))
+			return next(c)
+		}
+	}
+}
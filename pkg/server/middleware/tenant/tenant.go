@@ -0,0 +1,31 @@
+package tenant
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"runar-himmel/pkg/server"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// ErrTenantRequired is returned when the access token does not carry any tenant
+var ErrTenantRequired = server.NewHTTPError(http.StatusForbidden, "TENANT_REQUIRED", "Please select an organization to continue")
+
+// Middleware scopes the request context to the tenant ID from the given claim,
+// so tenant aware repositories only see the records of that tenant.
+// It must be placed after the JWT middleware, which sets the claims into the context.
+func Middleware(claim string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID, _ := c.Get(claim).(string)
+			if tenantID == "" {
+				return ErrTenantRequired
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(repoutil.WithTenant(req.Context(), tenantID)))
+			return next(c)
+		}
+	}
+}
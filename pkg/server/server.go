@@ -17,6 +17,7 @@ import (
 	"github.com/labstack/gommon/log"
 
 	"runar-himmel/pkg/server/middleware/secure"
+	dbutil "runar-himmel/pkg/util/db"
 
 	echoadapter "github.com/awslabs/aws-lambda-go-api-proxy/echo"
 )
@@ -46,6 +47,8 @@ type Config struct {
 	// The `Content-Security-Policy` header providing security against XSS and other code injection attacks.
 	// Sample for production: `default-src 'self'`
 	ContentSecurityPolicy string
+	// Convert the errors of lower layers into HTTPError, e.g. the database errors, see ErrorTranslator
+	ErrorTranslators []ErrorTranslator
 }
 
 var (
@@ -93,7 +96,7 @@ func New(cfg *Config) *echo.Echo {
 	cfg.fillDefaults()
 	e := echo.New()
 	e.Validator = NewValidator()
-	e.HTTPErrorHandler = NewErrorHandler(e).Handle
+	e.HTTPErrorHandler = NewErrorHandler(e, cfg.ErrorTranslators...).Handle
 	e.Binder = NewBinder()
 	e.Debug = cfg.Debug
 	e.Server.Addr = fmt.Sprintf(":%d", cfg.Port)
@@ -101,7 +104,12 @@ func New(cfg *Config) *echo.Echo {
 	e.Server.ReadTimeout = time.Duration(cfg.ReadTimeout) * time.Second
 	e.Server.WriteTimeout = time.Duration(cfg.WriteTimeout) * time.Second
 
-	e.Use(middleware.Recover(), middleware.RequestID(), middleware.Logger())
+	e.Use(middleware.Recover(), middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		// the queries of the request are logged with the request ID, see dbutil.NewLogger
+		RequestIDHandler: func(c echo.Context, id string) {
+			c.SetRequest(c.Request().WithContext(dbutil.WithRequestID(c.Request().Context(), id)))
+		},
+	}), middleware.Logger())
 	e.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
 		Limit:   cfg.BodyLimit,
 		Skipper: cfg.BodyLimitSkipper,
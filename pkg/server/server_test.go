@@ -1,9 +1,15 @@
 package server_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
 	"runar-himmel/pkg/server"
+	dbutil "runar-himmel/pkg/util/db"
 )
 
 // Improve tests
@@ -15,3 +21,17 @@ func TestNew(t *testing.T) {
 		t.Errorf("Server should not be nil")
 	}
 }
+
+func TestRequestIDContext(t *testing.T) {
+	e := server.New(&server.Config{Port: 8080})
+	var ctxID string
+	e.GET("/", func(c echo.Context) error {
+		ctxID = dbutil.RequestIDFromContext(c.Request().Context())
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotEmpty(t, ctxID)
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), ctxID)
+}
@@ -0,0 +1,127 @@
+package auditutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	ActionCreate = "create"
+	ActionUpsert = "upsert"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Redacted replaces the values of the hidden fields, i.e. `json:"-"`, such as passwords
+const Redacted = "[REDACTED]"
+
+// ErrAppendOnly is returned when updating or deleting audit logs
+var ErrAppendOnly = errors.New("auditutil: audit logs are append-only")
+
+// Auditable is implemented by the models whose changes are audited, e.g. by embedding types.Base
+type Auditable interface {
+	Audited() bool
+}
+
+// Log represents a change of a record
+// swagger:model AuditLog
+type Log struct {
+	ID string `json:"id" gorm:"primaryKey;type:varchar(26)" filter:"exact,in" sort:"true"`
+	// The table of the changed record
+	Entity   string `json:"entity" gorm:"type:varchar(64);index:idx_audit_logs_entity,priority:1" filter:"exact,in"`
+	EntityID string `json:"entity_id" gorm:"type:varchar(64);index:idx_audit_logs_entity,priority:2" filter:"exact,in"`
+	// create, upsert, update or delete
+	Action string `json:"action" gorm:"type:varchar(10)" filter:"exact,in"`
+	// The user making the change, empty for system changes
+	ActorID string `json:"actor_id" gorm:"type:varchar(64);index" filter:"exact,in"`
+	// The changed columns: all of them for creations & deletions, only the changed ones for updates
+	Before    Values    `json:"before,omitempty" gorm:"type:text"`
+	After     Values    `json:"after,omitempty" gorm:"type:text"`
+	RequestID string    `json:"request_id,omitempty" gorm:"type:varchar(64)" filter:"exact"`
+	IP        string    `json:"ip,omitempty" gorm:"type:varchar(45)" filter:"exact"`
+	CreatedAt time.Time `json:"created_at" gorm:"index" filter:"gte,lte,between" sort:"true"`
+}
+
+// TableName returns the table of audit logs
+func (Log) TableName() string {
+	return "audit_logs"
+}
+
+// BeforeCreate hook executed by gorm
+func (l *Log) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = ulidutil.NewString()
+	}
+	return nil
+}
+
+// BeforeUpdate hook executed by gorm, audit logs cannot be changed
+func (l *Log) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAppendOnly
+}
+
+// BeforeDelete hook executed by gorm, audit logs cannot be deleted
+func (l *Log) BeforeDelete(tx *gorm.DB) error {
+	return ErrAppendOnly
+}
+
+// Values holds the JSON encoded values of the columns, stored as a JSON object
+type Values map[string]json.RawMessage
+
+// Scan implements the sql.Scanner interface
+func (v *Values) Scan(value any) error {
+	var b []byte
+	switch value := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		b = value
+	case string:
+		b = []byte(value)
+	default:
+		return fmt.Errorf("auditutil: cannot scan %T into Values", value)
+	}
+	if len(b) == 0 {
+		*v = nil
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Value implements the driver.Valuer interface
+func (v Values) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Actor is the user making the changes
+type Actor struct {
+	ID string
+	IP string
+}
+
+type actorCtxKey struct{}
+
+// WithActor returns a copy of ctx which attributes the changes made with it to the given actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx, if any
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorCtxKey{}).(Actor)
+	return actor, ok
+}
@@ -0,0 +1,206 @@
+package auditutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	auditutil "runar-himmel/pkg/util/audit"
+	dbutil "runar-himmel/pkg/util/db"
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type ship struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex"`
+	Crew      int
+	Secret    string             `json:"-"`
+	DeletedAt repoutil.DeletedAt `gorm:"not null;default:0"`
+}
+
+func (ship) Audited() bool { return true }
+
+type voyage struct {
+	ID        string `gorm:"primaryKey"`
+	Days      int
+	Token     string `json:"-"`
+	Heartbeat int    `audit:"-"`
+	UpdatedAt time.Time
+}
+
+func (voyage) Audited() bool { return true }
+
+type sigil struct {
+	ID   string `gorm:"primaryKey"`
+	Name string
+}
+
+// newAuditedDB returns a test db auditing the changes
+func newAuditedDB(t *testing.T) *gorm.DB {
+	db := dbtest.New(t, &ship{}, &voyage{}, &sigil{}, &auditutil.Log{})
+	require.NoError(t, auditutil.Use(db))
+	return db
+}
+
+func decode(t *testing.T, v json.RawMessage) any {
+	var out any
+	require.NoError(t, json.Unmarshal(v, &out))
+	return out
+}
+
+func TestAudit(t *testing.T) {
+	db := newAuditedDB(t)
+	ctx := auditutil.WithActor(dbutil.WithRequestID(context.Background(), "req-1"), auditutil.Actor{ID: "odin", IP: "10.0.0.1"})
+	tx := db.WithContext(ctx)
+
+	// creations have all columns except the hidden ones
+	require.NoError(t, tx.Create(&ship{ID: "1", Name: "Naglfar", Crew: 10, Secret: "x"}).Error)
+	require.NoError(t, tx.Create([]ship{{ID: "2", Name: "Skidbladnir"}, {ID: "3", Name: "Hringhorni"}}).Error)
+	logs := dbtest.Find[auditutil.Log](t, db)
+	require.Len(t, logs, 3)
+	assert.Equal(t, "ships", logs[0].Entity)
+	assert.Equal(t, "1", logs[0].EntityID)
+	assert.Equal(t, auditutil.ActionCreate, logs[0].Action)
+	assert.Equal(t, "odin", logs[0].ActorID)
+	assert.Equal(t, "req-1", logs[0].RequestID)
+	assert.Equal(t, "10.0.0.1", logs[0].IP)
+	assert.Nil(t, logs[0].Before)
+	assert.Equal(t, "Naglfar", decode(t, logs[0].After["name"]))
+	assert.EqualValues(t, 10, decode(t, logs[0].After["crew"]))
+	assert.NotContains(t, logs[0].After, "secret")
+	assert.Equal(t, []string{"2", "3"}, []string{logs[1].EntityID, logs[2].EntityID})
+
+	// updates have the changed columns only, hidden ones are redacted
+	require.NoError(t, tx.Model(&ship{}).Where("crew = ?", 0).Updates(map[string]any{"crew": 5, "secret": "y"}).Error)
+	logs = dbtest.Find[auditutil.Log](t, db)[3:]
+	require.Len(t, logs, 2)
+	assert.Equal(t, auditutil.ActionUpdate, logs[0].Action)
+	assert.Equal(t, "2", logs[0].EntityID)
+	assert.EqualValues(t, 0, decode(t, logs[0].Before["crew"]))
+	assert.EqualValues(t, 5, decode(t, logs[0].After["crew"]))
+	assert.Equal(t, auditutil.Redacted, decode(t, logs[0].After["secret"]))
+	assert.Equal(t, auditutil.Redacted, decode(t, logs[0].Before["secret"]))
+	assert.NotContains(t, logs[0].After, "name")
+
+	// by the primary key of the model, nothing is logged without changes
+	rec := ship{ID: "1"}
+	require.NoError(t, tx.Model(&rec).Update("name", "Naglfar II").Error)
+	require.NoError(t, tx.Model(&ship{}).Where("id = ?", "1").Update("crew", 10).Error)
+	logs = dbtest.Find[auditutil.Log](t, db)[5:]
+	require.Len(t, logs, 1)
+	assert.Equal(t, "1", logs[0].EntityID)
+	assert.Equal(t, auditutil.Values{"name": json.RawMessage(`"Naglfar"`)}, logs[0].Before)
+
+	// soft & hard deletions have all columns before
+	require.NoError(t, tx.Delete(&ship{ID: "2"}).Error)
+	require.NoError(t, tx.Unscoped().Delete(&ship{}, "id = ?", "3").Error)
+	logs = dbtest.Find[auditutil.Log](t, db)[6:]
+	require.Len(t, logs, 2)
+	assert.Equal(t, auditutil.ActionDelete, logs[0].Action)
+	assert.Equal(t, "2", logs[0].EntityID)
+	assert.Equal(t, "Skidbladnir", decode(t, logs[0].Before["name"]))
+	assert.Nil(t, logs[0].After)
+	assert.Equal(t, "3", logs[1].EntityID)
+
+	// upserts log the existing record when hitting the conflict, instead of the given primary key
+	require.NoError(t, tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, UpdateAll: true}).
+		Create(&ship{ID: "4", Name: "Naglfar II", Crew: 1}).Error)
+	require.NoError(t, tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoUpdates: clause.AssignmentColumns([]string{"crew"})}).
+		Create(&ship{ID: "5", Name: "Ellida", Crew: 2}).Error)
+	logs = dbtest.Find[auditutil.Log](t, db)[8:]
+	require.Len(t, logs, 2)
+	assert.Equal(t, auditutil.ActionUpsert, logs[0].Action)
+	assert.Equal(t, "1", logs[0].EntityID)
+	assert.Equal(t, "1", decode(t, logs[0].After["id"]))
+	assert.EqualValues(t, 1, decode(t, logs[0].After["crew"]))
+	assert.Equal(t, "5", logs[1].EntityID)
+
+	// models which are not auditable
+	require.NoError(t, tx.Create(&sigil{ID: "1", Name: "fehu"}).Error)
+	assert.Len(t, dbtest.Find[auditutil.Log](t, db), 10)
+}
+
+func TestAuditUpdates(t *testing.T) {
+	db := newAuditedDB(t)
+	require.NoError(t, db.Create([]voyage{{ID: "1", Days: 1}, {ID: "2", Days: 2}}).Error)
+	count := func() int { return len(dbtest.Find[auditutil.Log](t, db)) }
+	require.Equal(t, 2, count())
+
+	// opted out columns are never logged, hidden & auto update time ones only along with the other changes
+	require.NoError(t, db.Model(&voyage{}).Where("id = ?", "1").Updates(map[string]any{"token": "x", "heartbeat": 1}).Error)
+	require.NoError(t, db.Model(&voyage{ID: "1"}).Update("token", "y").Error)
+	require.NoError(t, db.Model(&voyage{ID: "2"}).Updates(voyage{Heartbeat: 2}).Error)
+	assert.Equal(t, 2, count())
+	assert.NotContains(t, dbtest.Find[auditutil.Log](t, db)[0].After, "heartbeat")
+
+	require.NoError(t, db.Model(&voyage{ID: "1"}).Updates(map[string]any{"days": 3, "token": "z", "heartbeat": 3}).Error)
+	logs := dbtest.Find[auditutil.Log](t, db)[2:]
+	require.Len(t, logs, 1)
+	assert.EqualValues(t, 3, decode(t, logs[0].After["days"]))
+	assert.Equal(t, auditutil.Redacted, decode(t, logs[0].After["token"]))
+	assert.Contains(t, logs[0].After, "updated_at")
+	assert.NotContains(t, logs[0].After, "heartbeat")
+
+	// batch updates are diffed by the assigned values, or the values read again for the expressions
+	require.NoError(t, db.Model(&voyage{}).Where("days > ?", 0).Update("days", 7).Error)
+	require.NoError(t, db.Model(&voyage{}).Where("days > ?", 0).Update("days", gorm.Expr("days + ?", 1)).Error)
+	logs = dbtest.Find[auditutil.Log](t, db)[3:]
+	require.Len(t, logs, 4)
+	for i, want := range []struct {
+		id            string
+		before, after int
+	}{{"1", 3, 7}, {"2", 2, 7}, {"1", 7, 8}, {"2", 7, 8}} {
+		assert.Equal(t, want.id, logs[i].EntityID)
+		assert.EqualValues(t, want.before, decode(t, logs[i].Before["days"]))
+		assert.EqualValues(t, want.after, decode(t, logs[i].After["days"]))
+	}
+	assert.Equal(t, 8, dbtest.Take[voyage](t, db, "id = ?", "2").Days)
+}
+
+func TestAuditTransaction(t *testing.T) {
+	db := newAuditedDB(t)
+
+	// system changes have no actor
+	require.NoError(t, db.Create(&ship{ID: "1", Name: "Naglfar"}).Error)
+	logs := dbtest.Find[auditutil.Log](t, db)
+	require.Len(t, logs, 1)
+	assert.Empty(t, logs[0].ActorID)
+	assert.Empty(t, logs[0].RequestID)
+
+	// the logs are rolled back along with the changes
+	errRollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ship{ID: "2", Name: "Skidbladnir"}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ship{}).Where("id = ?", "1").Update("crew", 3).Error; err != nil {
+			return err
+		}
+		assert.Len(t, dbtest.Find[auditutil.Log](t, tx), 3)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	assert.Len(t, dbtest.Find[auditutil.Log](t, db), 1)
+
+	// failed changes are not logged
+	require.Error(t, db.Create(&ship{ID: "3", Name: "Naglfar"}).Error)
+	assert.Len(t, dbtest.Find[auditutil.Log](t, db), 1)
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	db := newAuditedDB(t)
+	require.NoError(t, db.Create(&ship{ID: "1", Name: "Naglfar"}).Error)
+	log := dbtest.Find[auditutil.Log](t, db)[0]
+
+	assert.ErrorIs(t, db.Model(&log).Update("actor_id", "loki").Error, auditutil.ErrAppendOnly)
+	assert.ErrorIs(t, db.Delete(&log).Error, auditutil.ErrAppendOnly)
+	assert.Len(t, dbtest.Find[auditutil.Log](t, db), 1)
+}
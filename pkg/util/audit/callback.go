@@ -0,0 +1,360 @@
+package auditutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	dbutil "runar-himmel/pkg/util/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// snapshotKey holds the records matching the update or delete statement in the statement settings
+const snapshotKey = "auditutil:snapshot"
+
+// Use registers the callbacks auditing the creations, updates & deletions of Auditable models into audit_logs.
+// The logs are written in the same transaction as the changes, attributed to the actor of the context, see WithActor.
+// Updates & deletions read the matching records beforehand. Updates apply the assigned values to them to compute the diff,
+// or read them again afterward if any value is an SQL expression, e.g. `version + 1`.
+// Updates changing only the hidden, opted out (`audit:"-"`) or auto update time columns are not logged, e.g. refreshing tokens.
+// Raw queries are not audited.
+func Use(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:commit_or_rollback_transaction").Register("auditutil:after_create", afterCreate),
+		cb.Update().Before("gorm:update").Register("auditutil:before_update", snapshot),
+		cb.Update().Before("gorm:commit_or_rollback_transaction").Register("auditutil:after_update", afterUpdate),
+		cb.Delete().Before("gorm:delete").Register("auditutil:before_delete", snapshot),
+		cb.Delete().Before("gorm:commit_or_rollback_transaction").Register("auditutil:after_delete", afterDelete),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func audited(tx *gorm.DB) bool {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return false
+	}
+	a, ok := reflect.New(tx.Statement.Schema.ModelType).Interface().(Auditable)
+	return ok && a.Audited()
+}
+
+func afterCreate(tx *gorm.DB) {
+	if !audited(tx) {
+		return
+	}
+	action := ActionCreate
+	var conflict []clause.Column
+	if c, ok := tx.Statement.Clauses["ON CONFLICT"]; ok {
+		action = ActionUpsert
+		if onConflict, ok := c.Expression.(clause.OnConflict); ok {
+			conflict = onConflict.Columns
+		}
+	}
+
+	logs := []*Log{}
+	eachRecord(tx.Statement.ReflectValue, func(rv reflect.Value) {
+		if len(conflict) > 0 {
+			var err error
+			if rv, err = stored(tx, rv, conflict); err != nil {
+				_ = tx.AddError(fmt.Errorf("auditutil: cannot read upserted record: %w", err))
+				return
+			}
+		}
+		logs = append(logs, newLog(tx, action, rv, nil, values(tx, rv)))
+	})
+	write(tx, logs)
+}
+
+// stored reads the upserted record by the conflict columns,
+// the given one still has the generated primary key if the existing record was updated instead
+func stored(tx *gorm.DB, rv reflect.Value, conflict []clause.Column) (reflect.Value, error) {
+	db := session(tx).Unscoped()
+	for _, col := range conflict {
+		f := tx.Statement.Schema.LookUpField(col.Name)
+		if f == nil {
+			return rv, fmt.Errorf("unknown conflict column %q", col.Name)
+		}
+		v, _ := f.ValueOf(tx.Statement.Context, reflect.Indirect(rv))
+		db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: v})
+	}
+	rec := reflect.New(tx.Statement.Schema.ModelType)
+	if err := db.Take(rec.Interface()).Error; err != nil {
+		return rv, err
+	}
+	return rec.Elem(), nil
+}
+
+func afterUpdate(tx *gorm.DB) {
+	if !audited(tx) {
+		return
+	}
+	before, ok := tx.InstanceGet(snapshotKey)
+	if !ok || before.(reflect.Value).Len() == 0 {
+		return
+	}
+	befores := before.(reflect.Value)
+	set, _ := tx.Statement.Clauses["SET"].Expression.(clause.Set)
+	if len(set) > 0 && !tracksAny(tx, assignedColumns(set)) {
+		return
+	}
+
+	afters, ok := applied(tx, befores, set)
+	if !ok {
+		// read again by the primary keys, the update may change the columns of the conditions
+		ids := make([]any, 0, befores.Len())
+		for i := 0; i < befores.Len(); i++ {
+			ids = append(ids, primaryKey(tx, befores.Index(i)))
+		}
+		records := reflect.New(reflect.SliceOf(tx.Statement.Schema.ModelType))
+		if err := session(tx).Unscoped().Where(clause.IN{Column: clause.PrimaryColumn, Values: ids}).Find(records.Interface()).Error; err != nil {
+			_ = tx.AddError(fmt.Errorf("auditutil: cannot read updated records: %w", err))
+			return
+		}
+		afters = records.Elem()
+	}
+	afterByID := map[string]reflect.Value{}
+	eachRecord(afters, func(rv reflect.Value) {
+		afterByID[fmt.Sprint(primaryKey(tx, rv))] = rv
+	})
+
+	logs := []*Log{}
+	for i := 0; i < befores.Len(); i++ {
+		b := befores.Index(i)
+		a, ok := afterByID[fmt.Sprint(primaryKey(tx, b))]
+		if !ok {
+			continue
+		}
+		if bv, av := diff(tx, b, a); len(av) > 0 {
+			logs = append(logs, newLog(tx, ActionUpdate, b, bv, av))
+		}
+	}
+	write(tx, logs)
+}
+
+// applied returns copies of the records with the assigned values, false if any of them is computed by the database
+func applied(tx *gorm.DB, befores reflect.Value, set clause.Set) (reflect.Value, bool) {
+	if len(set) == 0 {
+		return reflect.Value{}, false
+	}
+	fields := make([]*schema.Field, 0, len(set))
+	for _, a := range set {
+		f := tx.Statement.Schema.LookUpField(a.Column.Name)
+		if _, isExpr := a.Value.(clause.Expression); f == nil || isExpr {
+			return reflect.Value{}, false
+		}
+		fields = append(fields, f)
+	}
+
+	afters := reflect.MakeSlice(befores.Type(), befores.Len(), befores.Len())
+	for i := 0; i < befores.Len(); i++ {
+		rv := afters.Index(i)
+		rv.Set(befores.Index(i))
+		for j, f := range fields {
+			if err := f.Set(tx.Statement.Context, rv, set[j].Value); err != nil {
+				return reflect.Value{}, false
+			}
+		}
+	}
+	return afters, true
+}
+
+func afterDelete(tx *gorm.DB) {
+	if !audited(tx) {
+		return
+	}
+	before, ok := tx.InstanceGet(snapshotKey)
+	if !ok {
+		return
+	}
+
+	logs := []*Log{}
+	eachRecord(before.(reflect.Value), func(rv reflect.Value) {
+		logs = append(logs, newLog(tx, ActionDelete, rv, values(tx, rv), nil))
+	})
+	write(tx, logs)
+}
+
+// snapshot reads the records matching the statement before they are changed.
+// Nothing is read for the updates of the given columns which are not tracked, see tracked.
+func snapshot(tx *gorm.DB) {
+	if !audited(tx) {
+		return
+	}
+	stmt := tx.Statement
+	if updates, ok := stmt.Dest.(map[string]any); ok && stmt.ReflectValue.Kind() != reflect.Invalid && len(updates) > 0 {
+		cols := make([]string, 0, len(updates))
+		for k := range updates {
+			cols = append(cols, k)
+		}
+		if !tracksAny(tx, cols) {
+			return
+		}
+	}
+	db := session(tx)
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			db = db.Clauses(clause.Where{Exprs: where.Exprs})
+		}
+	}
+	// gorm adds the primary keys of the given record(s) as conditions later on.
+	// The reflect value is the model for updates, the deleted value for deletions.
+	for _, rv := range []reflect.Value{stmt.ReflectValue, reflect.ValueOf(stmt.Model)} {
+		rv = reflect.Indirect(rv)
+		if !rv.IsValid() || (rv.Kind() != reflect.Struct && rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			continue
+		}
+		_, queryValues := schema.GetIdentityFieldValuesMap(stmt.Context, rv, stmt.Schema.PrimaryFields)
+		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+		if len(values) > 0 {
+			db = db.Where(clause.IN{Column: column, Values: values})
+		}
+	}
+	if stmt.Unscoped {
+		db = db.Unscoped()
+	}
+
+	records := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	if err := db.Find(records.Interface()).Error; err != nil {
+		_ = tx.AddError(fmt.Errorf("auditutil: cannot read records before changing: %w", err))
+		return
+	}
+	tx.InstanceSet(snapshotKey, records.Elem())
+}
+
+// session returns a new session of the model in the same transaction & context as tx
+func session(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Model(reflect.New(tx.Statement.Schema.ModelType).Interface())
+}
+
+func write(tx *gorm.DB, logs []*Log) {
+	if len(logs) == 0 {
+		return
+	}
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(logs).Error; err != nil {
+		_ = tx.AddError(fmt.Errorf("auditutil: cannot write audit logs: %w", err))
+	}
+}
+
+func newLog(tx *gorm.DB, action string, rv reflect.Value, before, after Values) *Log {
+	ctx := tx.Statement.Context
+	actor, _ := ActorFromContext(ctx)
+	return &Log{
+		Entity:    tx.Statement.Table,
+		EntityID:  fmt.Sprint(primaryKey(tx, rv)),
+		Action:    action,
+		ActorID:   actor.ID,
+		Before:    before,
+		After:     after,
+		RequestID: dbutil.RequestIDFromContext(ctx),
+		IP:        actor.IP,
+	}
+}
+
+func primaryKey(tx *gorm.DB, rv reflect.Value) any {
+	if f := tx.Statement.Schema.PrioritizedPrimaryField; f != nil {
+		v, _ := f.ValueOf(tx.Statement.Context, reflect.Indirect(rv))
+		return v
+	}
+	return nil
+}
+
+// values returns the values of all columns except the hidden & opted out ones
+func values(tx *gorm.DB, rv reflect.Value) Values {
+	vals := Values{}
+	for _, f := range tx.Statement.Schema.Fields {
+		if f.DBName == "" || hidden(f) || optedOut(f) {
+			continue
+		}
+		vals[f.DBName] = encode(tx, f, rv)
+	}
+	return vals
+}
+
+// diff returns the values of the changed columns before & after, hidden columns are redacted & opted out ones omitted.
+// Nothing is returned unless a tracked column is changed, see tracked.
+func diff(tx *gorm.DB, before, after reflect.Value) (Values, Values) {
+	bv, av := Values{}, Values{}
+	changed := false
+	for _, f := range tx.Statement.Schema.Fields {
+		if f.DBName == "" || optedOut(f) {
+			continue
+		}
+		b, a := encode(tx, f, before), encode(tx, f, after)
+		if bytes.Equal(b, a) {
+			continue
+		}
+		changed = changed || tracked(f)
+		if hidden(f) {
+			b, _ = json.Marshal(Redacted)
+			a = b
+		}
+		bv[f.DBName], av[f.DBName] = b, a
+	}
+	if !changed {
+		return nil, nil
+	}
+	return bv, av
+}
+
+func hidden(f *schema.Field) bool {
+	return f.Tag.Get("json") == "-"
+}
+
+// optedOut reports whether the column is left out of the logs by the `audit:"-"` tag
+func optedOut(f *schema.Field) bool {
+	return f.Tag.Get("audit") == "-"
+}
+
+// tracked reports whether changing the column is worth a log by itself,
+// the hidden, opted out & auto update time columns are only logged along with the other ones
+func tracked(f *schema.Field) bool {
+	return !hidden(f) && !optedOut(f) && f.AutoUpdateTime == 0
+}
+
+// tracksAny reports whether any of the given columns or fields is tracked, the unknown ones are assumed to be
+func tracksAny(tx *gorm.DB, cols []string) bool {
+	for _, col := range cols {
+		if f := tx.Statement.Schema.LookUpField(col); f == nil || tracked(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func assignedColumns(set clause.Set) []string {
+	cols := make([]string, 0, len(set))
+	for _, a := range set {
+		cols = append(cols, a.Column.Name)
+	}
+	return cols
+}
+
+func encode(tx *gorm.DB, f *schema.Field, rv reflect.Value) json.RawMessage {
+	v, _ := f.ValueOf(tx.Statement.Context, reflect.Indirect(rv))
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return b
+}
+
+// eachRecord calls fn with each record of the struct, slice or array value
+func eachRecord(rv reflect.Value, fn func(reflect.Value)) {
+	rv = reflect.Indirect(rv)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			fn(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
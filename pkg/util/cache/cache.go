@@ -0,0 +1,16 @@
+package cacheutil
+
+import (
+	"context"
+	"time"
+)
+
+// Store represents a key-value cache backend
+type Store interface {
+	// Get returns the value of the key, ok is false if the key does not exist or has expired
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set sets the value of the key, which expires after the given TTL. Zero TTL means no expiration.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys, missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
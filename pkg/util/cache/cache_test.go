@@ -0,0 +1,79 @@
+package cacheutil_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cacheutil "runar-himmel/pkg/util/cache"
+)
+
+// testStore runs the common behaviors of all stores, expire moves the clock of the store forward
+func testStore(t *testing.T, s cacheutil.Store, expire func(d time.Duration)) {
+	ctx := context.Background()
+
+	_, ok, err := s.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, s.Set(ctx, "b", []byte("2"), time.Second))
+	v, ok, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	// overwrite
+	require.NoError(t, s.Set(ctx, "a", []byte("3"), 0))
+	v, _, _ = s.Get(ctx, "a")
+	assert.Equal(t, []byte("3"), v)
+
+	// expiration
+	expire(2 * time.Second)
+	_, ok, _ = s.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok, _ = s.Get(ctx, "a")
+	assert.True(t, ok)
+
+	// deletion
+	require.NoError(t, s.Delete(ctx, "a", "missing"))
+	_, ok, _ = s.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.NoError(t, s.Delete(ctx))
+}
+
+func TestLRU(t *testing.T) {
+	testStore(t, cacheutil.NewLRU(10), func(d time.Duration) { time.Sleep(d) })
+
+	// the least recently used entries are evicted first
+	ctx := context.Background()
+	s := cacheutil.NewLRU(3)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Set(ctx, fmt.Sprint(i), []byte{byte(i)}, 0))
+	}
+	_, _, _ = s.Get(ctx, "0")
+	require.NoError(t, s.Set(ctx, "3", []byte{3}, 0))
+	assert.Equal(t, 3, s.Len())
+	for key, existed := range map[string]bool{"0": true, "1": false, "2": true, "3": true} {
+		_, ok, _ := s.Get(ctx, key)
+		assert.Equal(t, existed, ok, key)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	testStore(t, cacheutil.NewRedis(client, "test:"), mr.FastForward)
+
+	// keys are prefixed
+	require.NoError(t, cacheutil.NewRedis(client, "test:").Set(context.Background(), "k", []byte("v"), 0))
+	assert.True(t, mr.Exists("test:k"))
+}
@@ -0,0 +1,74 @@
+package cronutil
+
+import (
+	"time"
+
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// Triggers of the runs
+const (
+	// Run by the scheduler when due
+	TriggerSchedule = "schedule"
+	// Run on demand, see Scheduler.RunTask
+	TriggerManual = "manual"
+	// Run by an EventBridge-style event, see Scheduler.HandleEvent
+	TriggerEvent = "event"
+)
+
+// Statuses of the runs
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Task holds the schedule state of a task shared by the instances.
+// The instance running the task holds the lock until LockedUntil, so the task runs on a single instance at a time.
+type Task struct {
+	Name string `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	// The schedule the next run is computed from, the next run is recomputed when it changes
+	Spec      string    `json:"spec" gorm:"type:varchar(100)"`
+	NextRunAt time.Time `json:"next_run_at"`
+	// The run holding the lock, or the last run if the lock has been released
+	RunID       string     `json:"run_id,omitempty" gorm:"type:varchar(26)"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table of the tasks
+func (Task) TableName() string {
+	return "cron_tasks"
+}
+
+// Run represents a run of a task, the history of the scheduler
+// swagger:model CronRun
+type Run struct {
+	ID   string `json:"id" gorm:"primaryKey;type:varchar(26)" filter:"exact,in" sort:"true"`
+	Task string `json:"task" gorm:"type:varchar(64);index:idx_cron_runs_task,priority:1" filter:"exact,in"`
+	// schedule, manual or event
+	Trigger string `json:"trigger" gorm:"type:varchar(10)" filter:"exact,in"`
+	// The instance running the task, see SchedulerConfig.Instance
+	Instance   string     `json:"instance" gorm:"type:varchar(100)" filter:"exact"`
+	Status     string     `json:"status" gorm:"type:varchar(10);not null" filter:"exact,in"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt  time.Time  `json:"started_at" gorm:"index:idx_cron_runs_task,priority:2" filter:"gte,lte" sort:"true"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// In millisecond
+	Duration int64 `json:"duration"`
+}
+
+// TableName returns the table of the runs
+func (Run) TableName() string {
+	return "cron_runs"
+}
+
+// BeforeCreate hook executed by gorm
+func (r *Run) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = ulidutil.NewString()
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package cronutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cronutil "runar-himmel/pkg/util/cron"
+	"runar-himmel/pkg/util/db/dbtest"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2024, 1, 6, 10, 7, 30, 0, time.UTC) // Saturday
+	cases := []struct {
+		spec string
+		next []string
+	}{
+		{"*/15 * * * *", []string{"2024-01-06 10:15", "2024-01-06 10:30"}},
+		{"5/20 * * * *", []string{"2024-01-06 10:25", "2024-01-06 10:45", "2024-01-06 11:05"}},
+		{"30 3 * * 1-5", []string{"2024-01-08 03:30", "2024-01-09 03:30"}},
+		{"0 9,18 * * MON-fri", []string{"2024-01-08 09:00", "2024-01-08 18:00"}},
+		{"0 0 * * 7", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		// either the day of month or the day of week
+		{"0 12 13 * 5", []string{"2024-01-12 12:00", "2024-01-13 12:00", "2024-01-19 12:00"}},
+		{"0 0 29 2 *", []string{"2024-02-29 00:00", "2028-02-29 00:00"}},
+		{"0 0 1 jan *", []string{"2025-01-01 00:00"}},
+		{"@hourly", []string{"2024-01-06 11:00", "2024-01-06 12:00"}},
+		{"@daily", []string{"2024-01-07 00:00"}},
+		{"@weekly", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		{"@monthly", []string{"2024-02-01 00:00"}},
+		{"@every 90m", []string{"2024-01-06 11:37", "2024-01-06 13:07"}},
+	}
+	for _, c := range cases {
+		s, err := cronutil.Parse(c.spec)
+		require.NoError(t, err, c.spec)
+		got := []string{}
+		for next := from; len(got) < len(c.next); {
+			next = s.Next(next)
+			got = append(got, next.Format("2006-01-02 15:04"))
+		}
+		assert.Equal(t, c.next, got, c.spec)
+	}
+
+	// in the location of the given time
+	s, err := cronutil.Parse("0 9 * * *")
+	require.NoError(t, err)
+	loc := time.FixedZone("ICT", 7*3600)
+	assert.Equal(t, time.Date(2024, 1, 7, 2, 0, 0, 0, time.UTC), s.Next(from.In(loc)).UTC())
+
+	for _, spec := range []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"5-1 * * * *", "*/0 * * * *", "a * * * *", "* * * foo *", "0 0 30 2 *", "@every 0s", "@every 1ms", "@every x",
+	} {
+		_, err := cronutil.Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	db := dbtest.New(t, &cronutil.Task{}, &cronutil.Run{})
+	ctx := context.Background()
+	s := cronutil.NewScheduler(db, cronutil.SchedulerConfig{Instance: "node-1"})
+	runs := atomic.Int32{}
+	require.NoError(t, s.Add("sessions.purge", "@hourly", func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		runs.Add(1)
+		return nil
+	}))
+	require.NoError(t, s.Add("otps.purge", "@every 15m", func(ctx context.Context) error {
+		return errors.New("db down")
+	}))
+	require.NoError(t, s.Add("keys.rotate", "@daily", func(ctx context.Context) error {
+		panic("boom")
+	}))
+	assert.ErrorContains(t, s.Add("sessions.purge", "@daily", nil), "already exists")
+	assert.Error(t, s.Add("foo", "@sometimes", nil))
+	assert.Equal(t, []string{"sessions.purge", "otps.purge", "keys.rotate"}, s.Tasks())
+
+	// scheduled, nothing due yet
+	n, err := s.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	task := dbtest.Take[cronutil.Task](t, db, "name = ?", "sessions.purge")
+	assert.Equal(t, "@hourly", task.Spec)
+	assert.Equal(t, time.Now().UTC().Truncate(time.Hour).Add(time.Hour), task.NextRunAt.UTC())
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), dbtest.Take[cronutil.Task](t, db, "name = ?", "otps.purge").NextRunAt, time.Second)
+
+	// due
+	dbtest.MakeDue(t, db, &cronutil.Task{}, "next_run_at", "name = ?", "sessions.purge")
+	n, err = s.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.EqualValues(t, 1, runs.Load())
+	history := dbtest.Find[cronutil.Run](t, db, "task = ?", "sessions.purge")
+	require.Len(t, history, 1)
+	assert.Equal(t, cronutil.StatusSucceeded, history[0].Status)
+	assert.Equal(t, cronutil.TriggerSchedule, history[0].Trigger)
+	assert.Equal(t, "node-1", history[0].Instance)
+	assert.NotNil(t, history[0].FinishedAt)
+	task = dbtest.Take[cronutil.Task](t, db, "name = ?", "sessions.purge")
+	assert.Nil(t, task.LockedUntil)
+	assert.Equal(t, history[0].ID, task.RunID)
+	assert.True(t, task.NextRunAt.After(time.Now()))
+	n, err = s.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// failures & panics are recorded
+	dbtest.MakeDue(t, db, &cronutil.Task{}, "next_run_at", "name = ?", "otps.purge")
+	dbtest.MakeDue(t, db, &cronutil.Task{}, "next_run_at", "name = ?", "keys.rotate")
+	n, err = s.RunDue(ctx)
+	assert.Equal(t, 2, n)
+	assert.ErrorContains(t, err, "task otps.purge failed: db down")
+	assert.ErrorContains(t, err, "task keys.rotate failed: panic: boom")
+	history = dbtest.Find[cronutil.Run](t, db, "task = ?", "otps.purge")
+	require.Len(t, history, 1)
+	assert.Equal(t, cronutil.StatusFailed, history[0].Status)
+	assert.Equal(t, "db down", history[0].Error)
+	assert.Nil(t, dbtest.Take[cronutil.Task](t, db, "name = ?", "otps.purge").LockedUntil)
+	assert.Nil(t, dbtest.Take[cronutil.Task](t, db, "name = ?", "keys.rotate").LockedUntil)
+
+	// on demand
+	require.NoError(t, s.RunTask(ctx, "sessions.purge", cronutil.TriggerManual))
+	assert.EqualValues(t, 2, runs.Load())
+	assert.Equal(t, cronutil.TriggerManual, dbtest.Find[cronutil.Run](t, db, "task = ?", "sessions.purge")[1].Trigger)
+	assert.ErrorIs(t, s.RunTask(ctx, "foo", cronutil.TriggerManual), cronutil.ErrUnknownTask)
+
+	// rescheduled when the schedule changes
+	s2 := cronutil.NewScheduler(db, cronutil.SchedulerConfig{})
+	require.NoError(t, s2.Add("sessions.purge", "@every 1m", func(ctx context.Context) error { return nil }))
+	_, err = s2.RunDue(ctx)
+	require.NoError(t, err)
+	task = dbtest.Take[cronutil.Task](t, db, "name = ?", "sessions.purge")
+	assert.Equal(t, "@every 1m", task.Spec)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), task.NextRunAt, time.Second)
+}
+
+func TestSchedulerLock(t *testing.T) {
+	db := dbtest.New(t, &cronutil.Task{}, &cronutil.Run{})
+	ctx := context.Background()
+	started, release := make(chan struct{}), make(chan struct{})
+	runs := atomic.Int32{}
+	task := func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+	s1 := cronutil.NewScheduler(db, cronutil.SchedulerConfig{Instance: "node-1"})
+	s2 := cronutil.NewScheduler(db, cronutil.SchedulerConfig{Instance: "node-2"})
+	require.NoError(t, s1.Add("sessions.purge", "@hourly", task))
+	require.NoError(t, s2.Add("sessions.purge", "@hourly", task))
+	_, err := s1.RunDue(ctx)
+	require.NoError(t, err)
+	dbtest.MakeDue(t, db, &cronutil.Task{}, "next_run_at", "name = ?", "sessions.purge")
+
+	done := make(chan error)
+	go func() {
+		_, err := s1.RunDue(ctx)
+		done <- err
+	}()
+	<-started
+	// only one instance runs the task at a time
+	n, err := s2.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.ErrorIs(t, s2.RunTask(ctx, "sessions.purge", cronutil.TriggerManual), cronutil.ErrTaskLocked)
+	close(release)
+	require.NoError(t, <-done)
+	assert.EqualValues(t, 1, runs.Load())
+
+	// the lock expires after the lease, e.g. when the instance crashed
+	s3 := cronutil.NewScheduler(db, cronutil.SchedulerConfig{Lease: 50 * time.Millisecond})
+	require.NoError(t, s3.Add("otps.purge", "@hourly", func(ctx context.Context) error {
+		<-ctx.Done()
+		require.NoError(t, db.Model(&cronutil.Task{}).Where("name = ?", "otps.purge").Update("run_id", "crashed").Error)
+		return ctx.Err()
+	}))
+	assert.ErrorIs(t, s3.RunTask(ctx, "otps.purge", cronutil.TriggerManual), context.DeadlineExceeded)
+	assert.NotNil(t, dbtest.Take[cronutil.Task](t, db, "name = ?", "otps.purge").LockedUntil)
+	time.Sleep(10 * time.Millisecond)
+	assert.ErrorIs(t, s3.RunTask(ctx, "otps.purge", cronutil.TriggerManual), context.DeadlineExceeded)
+}
+
+func TestSchedulerHandleEvent(t *testing.T) {
+	db := dbtest.New(t, &cronutil.Task{}, &cronutil.Run{})
+	ctx := context.Background()
+	s := cronutil.NewScheduler(db, cronutil.SchedulerConfig{})
+	for _, name := range []string{"sessions.purge", "otps.purge"} {
+		require.NoError(t, s.Add(name, "@hourly", func(ctx context.Context) error { return nil }))
+	}
+
+	events := []string{
+		`{"task": "sessions.purge"}`,
+		`{"detail-type": "Scheduled Event", "detail": {"task": "sessions.purge"}}`,
+		`{"detail-type": "Scheduled Event", "resources": ["arn:aws:events:us-east-1:123456789012:rule/sessions.purge"], "detail": {}}`,
+	}
+	for _, raw := range events {
+		e := cronutil.Event{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &e))
+		require.NoError(t, s.HandleEvent(ctx, e), raw)
+	}
+	history := dbtest.Find[cronutil.Run](t, db, "task = ?", "sessions.purge")
+	require.Len(t, history, 3)
+	assert.Equal(t, cronutil.TriggerEvent, history[2].Trigger)
+	assert.Empty(t, dbtest.Find[cronutil.Run](t, db, "task = ?", "otps.purge"))
+
+	assert.ErrorIs(t, s.HandleEvent(ctx, cronutil.Event{Task: "foo"}), cronutil.ErrUnknownTask)
+
+	// the due tasks without a task name
+	dbtest.MakeDue(t, db, &cronutil.Task{}, "next_run_at", "name = ?", "otps.purge")
+	e := cronutil.Event{DetailType: "Scheduled Event", Resources: []string{"arn:aws:events:us-east-1:123456789012:rule/every-minute"}}
+	require.NoError(t, s.HandleEvent(ctx, e))
+	history = dbtest.Find[cronutil.Run](t, db, "task = ?", "otps.purge")
+	require.Len(t, history, 1)
+	assert.Equal(t, cronutil.TriggerSchedule, history[0].Trigger)
+}
+
+func TestSchedulerRun(t *testing.T) {
+	db := dbtest.New(t, &cronutil.Task{}, &cronutil.Run{})
+	s := cronutil.NewScheduler(db, cronutil.SchedulerConfig{PollInterval: 10 * time.Millisecond})
+	runs := atomic.Int32{}
+	require.NoError(t, s.Add("sessions.purge", "@every 1s", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, func(err error) { t.Error(err) })
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool { return runs.Load() == 1 }, 3*time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+	assert.EqualValues(t, 1, runs.Load())
+}
@@ -0,0 +1,346 @@
+package cronutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Func runs a task, the context is canceled at the end of the lease, see SchedulerConfig.Lease
+type Func func(ctx context.Context) error
+
+// Errors of running the tasks on demand
+var (
+	ErrUnknownTask = errors.New("cronutil: unknown task")
+	ErrTaskLocked  = errors.New("cronutil: the task is running")
+)
+
+// SchedulerConfig holds the scheduler configurations, zero values are replaced by the defaults
+type SchedulerConfig struct {
+	// Identifies the instance in the run history, default `<hostname>:<pid>`
+	Instance string
+	// How often the due tasks are checked, default 15 seconds
+	PollInterval time.Duration
+	// How long a running task is locked for its instance, default 10 minutes.
+	// It is also the timeout of the tasks, the lock is released afterward even if the instance crashed.
+	Lease time.Duration
+	// The location of the cron expressions, default UTC
+	Location *time.Location
+}
+
+// Scheduler runs the periodic tasks.
+// Multiple instances may run the same tasks, each run is locked in the database so only one instance runs it.
+// Missed runs, e.g. while all instances are down, are run once when due instead of being caught up.
+type Scheduler struct {
+	db    *gorm.DB
+	cfg   SchedulerConfig
+	tasks map[string]*entry
+	names []string
+
+	mu     sync.Mutex
+	synced bool
+}
+
+type entry struct {
+	spec     string
+	schedule Schedule
+	fn       Func
+}
+
+// NewScheduler creates a new scheduler storing the state & history of the tasks in db
+func NewScheduler(db *gorm.DB, cfg SchedulerConfig) *Scheduler {
+	if cfg.Instance == "" {
+		host, _ := os.Hostname()
+		cfg.Instance = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 15 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 10 * time.Minute
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &Scheduler{db: db, cfg: cfg, tasks: map[string]*entry{}}
+}
+
+// Add registers a task running on the schedule, see Parse for the accepted specs.
+// The tasks must be added before running the scheduler.
+func (s *Scheduler) Add(name, spec string, fn Func) error {
+	if name == "" || len(name) > 64 {
+		return fmt.Errorf("cronutil: invalid task name %q", name)
+	}
+	if _, ok := s.tasks[name]; ok {
+		return fmt.Errorf("cronutil: task %q already exists", name)
+	}
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[name] = &entry{spec: spec, schedule: schedule, fn: fn}
+	s.names = append(s.names, name)
+	s.synced = false
+	return nil
+}
+
+// Tasks returns the names of the tasks in the order they were added
+func (s *Scheduler) Tasks() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Run runs the due tasks until the context is done, then waits for the running tasks.
+// Errors of both the tasks & the database are reported to onError if given.
+func (s *Scheduler) Run(ctx context.Context, onError func(error)) {
+	report := func(err error) {
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+	}
+	wg := sync.WaitGroup{}
+	for {
+		_, err := s.start(ctx, &wg, report)
+		report(err)
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// RunDue runs the due tasks concurrently and waits for them, returns the number of tasks run along with their errors
+func (s *Scheduler) RunDue(ctx context.Context) (int, error) {
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	errs := []error{}
+	n, err := s.start(ctx, &wg, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	wg.Wait()
+	return n, errors.Join(append(errs, err)...)
+}
+
+// RunTask runs a task now regardless of its schedule, unless it is already running (ErrTaskLocked).
+// The next scheduled run is computed from now. Returns the error of the task if any.
+func (s *Scheduler) RunTask(ctx context.Context, name, trigger string) error {
+	e, ok := s.tasks[name]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownTask, name)
+	}
+	if err := s.sync(ctx); err != nil {
+		return err
+	}
+	run, err := s.acquire(ctx, name, e, trigger, time.Now().UTC(), true)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		return ErrTaskLocked
+	}
+	return s.exec(ctx, e, run)
+}
+
+// Event represents an EventBridge-style event triggering the tasks, see Scheduler.HandleEvent
+type Event struct {
+	// The task to run, e.g. given as the constant input of the rule: `{"task": "sessions.purge"}`
+	Task string `json:"task"`
+	// The fields of the EventBridge events, the task is either given in the detail or as the name of the rule
+	DetailType string          `json:"detail-type,omitempty"`
+	Resources  []string        `json:"resources,omitempty"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+}
+
+// HandleEvent runs the task named by the event, or all due tasks if none is named, e.g. from a rule running every minute.
+// The task is named by either the `task` field, the `task` field of the detail or the name of the rule if it is a task.
+func (s *Scheduler) HandleEvent(ctx context.Context, event Event) error {
+	name := event.Task
+	if name == "" && len(event.Detail) > 0 {
+		detail := struct {
+			Task string `json:"task"`
+		}{}
+		if err := json.Unmarshal(event.Detail, &detail); err == nil {
+			name = detail.Task
+		}
+	}
+	if name == "" {
+		// e.g. arn:aws:events:us-east-1:123456789012:rule/sessions.purge
+		for _, res := range event.Resources {
+			if _, rule, ok := strings.Cut(res, ":rule/"); ok {
+				if _, ok := s.tasks[rule]; ok {
+					name = rule
+				}
+			}
+		}
+	}
+	if name != "" {
+		return s.RunTask(ctx, name, TriggerEvent)
+	}
+	_, err := s.RunDue(ctx)
+	return err
+}
+
+// sync creates the state of the new tasks, and reschedules the tasks whose schedule has changed
+func (s *Scheduler) sync(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.synced {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	db := s.db.WithContext(ctx)
+	for _, name := range s.names {
+		e := s.tasks[name]
+		task := &Task{Name: name, Spec: e.spec, NextRunAt: s.next(e, now)}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(task).Error; err != nil {
+			return fmt.Errorf("cronutil: cannot create task %s: %w", name, err)
+		}
+		if err := db.Model(&Task{}).Where("name = ? AND spec <> ?", name, e.spec).
+			Updates(map[string]any{"spec": e.spec, "next_run_at": task.NextRunAt}).Error; err != nil {
+			return fmt.Errorf("cronutil: cannot reschedule task %s: %w", name, err)
+		}
+	}
+	s.synced = true
+	return nil
+}
+
+// start starts the due tasks in goroutines added to wg, their errors are reported to report.
+// Returns the number of tasks started.
+func (s *Scheduler) start(ctx context.Context, wg *sync.WaitGroup, report func(error)) (int, error) {
+	if err := s.sync(ctx); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	due := []Task{}
+	if err := s.db.WithContext(ctx).Where("next_run_at <= ? AND (locked_until IS NULL OR locked_until <= ?)", now, now).
+		Order("next_run_at").Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("cronutil: cannot find the due tasks: %w", err)
+	}
+	n := 0
+	for _, task := range due {
+		// may be a task of another version of the app
+		e, ok := s.tasks[task.Name]
+		if !ok {
+			continue
+		}
+		run, err := s.acquire(ctx, task.Name, e, TriggerSchedule, now, false)
+		if err != nil {
+			return n, err
+		}
+		// started by another instance
+		if run == nil {
+			continue
+		}
+		n++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report(s.exec(ctx, e, run))
+		}()
+	}
+	return n, nil
+}
+
+// acquire locks the task for a new run, then schedules its next run.
+// Returns nil if the task is locked by another run, or not due unless force is true.
+func (s *Scheduler) acquire(ctx context.Context, name string, e *entry, trigger string, now time.Time, force bool) (*Run, error) {
+	run := &Run{
+		ID:        ulidutil.NewString(),
+		Task:      name,
+		Trigger:   trigger,
+		Instance:  s.cfg.Instance,
+		Status:    StatusRunning,
+		StartedAt: now,
+	}
+	q := s.db.WithContext(ctx).Model(&Task{}).Where("name = ? AND (locked_until IS NULL OR locked_until <= ?)", name, now)
+	if !force {
+		q = q.Where("next_run_at <= ?", now)
+	}
+	res := q.Updates(map[string]any{"run_id": run.ID, "locked_until": now.Add(s.cfg.Lease), "next_run_at": s.next(e, now)})
+	if res.Error != nil {
+		return nil, fmt.Errorf("cronutil: cannot lock task %s: %w", name, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, errors.Join(fmt.Errorf("cronutil: cannot create the run of task %s: %w", name, err), s.release(context.WithoutCancel(ctx), run))
+	}
+	return run, nil
+}
+
+// exec runs the task, then records the result & releases the lock
+func (s *Scheduler) exec(ctx context.Context, e *entry, run *Run) error {
+	runCtx, cancel := context.WithDeadline(ctx, run.StartedAt.Add(s.cfg.Lease))
+	runErr := call(runCtx, e.fn)
+	cancel()
+
+	finishedAt := time.Now().UTC()
+	updates := map[string]any{
+		"status":      StatusSucceeded,
+		"finished_at": finishedAt,
+		"duration":    finishedAt.Sub(run.StartedAt).Milliseconds(),
+	}
+	if runErr != nil {
+		updates["status"] = StatusFailed
+		updates["error"] = runErr.Error()
+		runErr = fmt.Errorf("cronutil: task %s failed: %w", run.Task, runErr)
+	}
+	// not canceled along with ctx, so the result is recorded
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if err = s.db.WithContext(ctx).Model(&Run{}).Where("id = ?", run.ID).Updates(updates).Error; err != nil {
+		err = fmt.Errorf("cronutil: cannot update the run of task %s: %w", run.Task, err)
+	}
+	return errors.Join(runErr, err, s.release(ctx, run))
+}
+
+// release unlocks the task if it is still locked by the run
+func (s *Scheduler) release(ctx context.Context, run *Run) error {
+	err := s.db.WithContext(ctx).Model(&Task{}).Where("name = ? AND run_id = ?", run.Task, run.ID).
+		Update("locked_until", nil).Error
+	if err != nil {
+		return fmt.Errorf("cronutil: cannot unlock task %s: %w", run.Task, err)
+	}
+	return nil
+}
+
+// next returns the next run time of the task after now, in UTC
+func (s *Scheduler) next(e *entry, now time.Time) time.Time {
+	next := e.schedule.Next(now.In(s.cfg.Location))
+	if next.IsZero() {
+		// the schedules are checked when parsing, so it is unlikely
+		return now.AddDate(maxYears, 0, 0)
+	}
+	return next.UTC()
+}
+
+// call runs the task, panics are returned as errors
+func call(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx)
+}
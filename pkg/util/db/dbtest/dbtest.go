@@ -0,0 +1,50 @@
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// New opens a SQLite database in a temporary directory of the test and migrates the models,
+// it is closed at the end of the test. Concurrent transactions wait for each other instead of failing,
+// e.g. multiple workers claiming from the same table. Foreign keys are enforced like on the other databases.
+func New(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000&_txlock=immediate&_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(models...))
+	t.Cleanup(func() {
+		if sqldb, err := db.DB(); err == nil {
+			sqldb.Close()
+		}
+	})
+	return db
+}
+
+// Find reads the records matching the conditions, ordered by the id column
+func Find[T any](t testing.TB, db *gorm.DB, conds ...any) []T {
+	t.Helper()
+	recs := []T{}
+	require.NoError(t, db.Order("id").Find(&recs, conds...).Error)
+	return recs
+}
+
+// Take reads the first record matching the conditions
+func Take[T any](t testing.TB, db *gorm.DB, conds ...any) *T {
+	t.Helper()
+	rec := new(T)
+	require.NoError(t, db.Take(rec, conds...).Error)
+	return rec
+}
+
+// MakeDue sets the time column of the records of the model matching the query to the past,
+// so they are due now instead of waiting for the backoff or the schedule
+func MakeDue(t testing.TB, db *gorm.DB, model any, column string, query any, args ...any) {
+	t.Helper()
+	require.NoError(t, db.Model(model).Where(query, args...).Update(column, time.Now().UTC().Add(-time.Second)).Error)
+}
@@ -0,0 +1,81 @@
+package dbutil
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"runar-himmel/config"
+
+	"github.com/imdatngo/gowhere"
+)
+
+// Supported dialects
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite3"
+)
+
+// sqliteMemory is the database name for the SQLite in-memory mode
+const sqliteMemory = ":memory:"
+
+// Dialect returns the dialect of the given driver name, accepting the common aliases such as `postgresql` or `sqlite`.
+// Unknown drivers are returned as is.
+func Dialect(driver string) string {
+	switch d := strings.ToLower(strings.TrimSpace(driver)); d {
+	case "mysql", "mariadb":
+		return DialectMySQL
+	case "postgres", "postgresql", "pgx":
+		return DialectPostgres
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	default:
+		return d
+	}
+}
+
+// WhereDialect returns the gowhere dialect of the given driver, SQLite quotes identifiers the same as PostgreSQL
+func WhereDialect(driver string) gowhere.Dialect {
+	if Dialect(driver) == DialectMySQL {
+		return gowhere.DialectMySQL
+	}
+	return gowhere.DialectPostgreSQL
+}
+
+// TableOptions returns the options to create tables of the given driver, e.g. for `gorm:table_options`
+func TableOptions(driver string) string {
+	if Dialect(driver) == DialectMySQL {
+		return "ENGINE=InnoDB ROW_FORMAT=DYNAMIC"
+	}
+	return ""
+}
+
+// IsMemory checks whether the config uses SQLite in-memory mode, which is lost once the last connection closes
+func IsMemory(cfg config.DB) bool {
+	return Dialect(cfg.Driver) == DialectSQLite && (cfg.Database == "" || cfg.Database == sqliteMemory || strings.Contains(cfg.Database, "mode=memory"))
+}
+
+// sqliteDSN returns the SQLite connection string of the config. The database is either:
+//   - a file path, e.g. `tmp/main.db`
+//   - empty or `:memory:` for the in-memory mode, shared by all connections of the pool
+//   - a URI, e.g. `file:test?mode=memory&cache=shared` for a named in-memory database
+func sqliteDSN(cfg config.DB) (string, error) {
+	params, err := url.ParseQuery(cfg.Params)
+	if err != nil {
+		return "", fmt.Errorf("invalid db params '%s': %w", cfg.Params, err)
+	}
+	if params.Get("_busy_timeout") == "" {
+		params.Set("_busy_timeout", "5000")
+	}
+
+	name := cfg.Database
+	if name == "" || name == sqliteMemory {
+		name = "file::memory:?cache=shared"
+	}
+	sep := "?"
+	if strings.Contains(name, "?") {
+		sep = "&"
+	}
+	return name + sep + params.Encode(), nil
+}
@@ -0,0 +1,73 @@
+package dbutil_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imdatngo/gowhere"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/config"
+	dbutil "runar-himmel/pkg/util/db"
+)
+
+func TestDialect(t *testing.T) {
+	assert.Equal(t, dbutil.DialectMySQL, dbutil.Dialect("MySQL"))
+	assert.Equal(t, dbutil.DialectPostgres, dbutil.Dialect("postgresql"))
+	assert.Equal(t, dbutil.DialectSQLite, dbutil.Dialect("sqlite"))
+	assert.Equal(t, "oracle", dbutil.Dialect("oracle"))
+
+	assert.Equal(t, gowhere.DialectMySQL, dbutil.WhereDialect("mysql"))
+	assert.Equal(t, gowhere.DialectPostgreSQL, dbutil.WhereDialect("postgres"))
+	assert.Equal(t, gowhere.DialectPostgreSQL, dbutil.WhereDialect("sqlite3"))
+
+	assert.NotEmpty(t, dbutil.TableOptions("mysql"))
+	assert.Empty(t, dbutil.TableOptions("postgres"))
+	assert.Empty(t, dbutil.TableOptions("sqlite3"))
+
+	_, err := dbutil.Dialector("oracle", config.DB{})
+	assert.ErrorContains(t, err, "unsupported")
+	_, err = dbutil.Dialector("postgres", config.DB{Database: "maindb"})
+	assert.ErrorContains(t, err, "host is required")
+}
+
+func TestNewPoolConfig(t *testing.T) {
+	cfg := config.DB{Driver: "sqlite3", Database: ":memory:", MaxOpenConns: 4, ConnMaxLifetime: 60, ConnMaxIdleTime: 60}
+	// in-memory databases must keep a connection, otherwise the data is lost
+	assert.Equal(t, dbutil.PoolConfig{MaxIdleConns: 1, MaxOpenConns: 4}, dbutil.NewPoolConfig(cfg))
+
+	cfg.Database = "main.db"
+	assert.Equal(t, dbutil.PoolConfig{MaxOpenConns: 4, ConnMaxLifetime: time.Minute, ConnMaxIdleTime: time.Minute}, dbutil.NewPoolConfig(cfg))
+}
+
+func TestNewSQLite(t *testing.T) {
+	cases := map[string]config.DB{
+		"file":         {Driver: "sqlite", Database: filepath.Join(t.TempDir(), "main.db")},
+		"memory":       {Driver: "sqlite3", Database: ":memory:"},
+		"named memory": {Driver: "sqlite3", Database: "file:dialect_test?mode=memory&cache=shared", Params: "_foreign_keys=1"},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg.MaxIdleConns, cfg.MaxOpenConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime = 0, 4, 1, 1
+			db, err := dbutil.New(cfg.Driver, cfg, &gorm.Config{})
+			require.NoError(t, err)
+			sqldb, err := db.DB()
+			require.NoError(t, err)
+			t.Cleanup(func() { sqldb.Close() })
+			require.NoError(t, dbutil.SetPool(db, dbutil.NewPoolConfig(cfg)))
+
+			require.NoError(t, db.AutoMigrate(&team{}))
+			require.NoError(t, db.Create(&team{ID: "a"}).Error)
+
+			// the data is shared by all connections of the pool
+			var count int64
+			require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+				return db.Model(&team{}).Count(&count).Error
+			}))
+			assert.EqualValues(t, 1, count)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package dbutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// UniqueViolationError is returned when a record violates a unique index, e.g. duplicated email
+type UniqueViolationError struct {
+	// The violated index, may be empty if the database does not report it, e.g. SQLite
+	Index string
+	// The offending field, the JSON name of the first field of the index if the model is known, or the column name
+	Field string
+	// The original database error
+	Err error
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("dbutil: duplicated %s: %v", e.Field, e.Err)
+}
+
+func (e *UniqueViolationError) Unwrap() error {
+	return e.Err
+}
+
+// ForeignKeyViolationError is returned when a record references a missing record, or is still referenced by others
+type ForeignKeyViolationError struct {
+	// The violated constraint, may be empty if the database does not report it, e.g. SQLite
+	Constraint string
+	// The original database error
+	Err error
+}
+
+func (e *ForeignKeyViolationError) Error() string {
+	return fmt.Sprintf("dbutil: foreign key violation: %v", e.Err)
+}
+
+func (e *ForeignKeyViolationError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	mysqlDupKeyRe     = regexp.MustCompile(`for key '(?:[^'.]+\.)?([^']+)'`)
+	mysqlFKRe         = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+	sqliteUniqueRe    = regexp.MustCompile(`UNIQUE constraint failed: (.+)$`)
+	sqliteUniqueCodes = map[int]bool{1555: true, 2067: true}
+	sqliteFKCode      = 787
+	indexPrefixes     = []string{"uix_", "idx_", "ux_", "uk_"}
+)
+
+// TranslateError converts the database specific errors of MySQL, PostgreSQL and SQLite into
+// UniqueViolationError and ForeignKeyViolationError. Other errors are returned as is.
+func TranslateError(err error) error {
+	return translateError(err, nil)
+}
+
+// translateError translates the error, using the schema of the model to derive the offending field if given
+func translateError(err error, s *schema.Schema) error {
+	if err == nil {
+		return nil
+	}
+	var uniqueErr *UniqueViolationError
+	var fkErr *ForeignKeyViolationError
+	if errors.As(err, &uniqueErr) || errors.As(err, &fkErr) {
+		return err
+	}
+
+	var myErr *mysql.MySQLError
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &myErr):
+		switch myErr.Number {
+		case 1062:
+			var index string
+			if m := mysqlDupKeyRe.FindStringSubmatch(myErr.Message); m != nil {
+				index = m[1]
+			}
+			return &UniqueViolationError{Index: index, Field: fieldFromIndex(index, s), Err: err}
+		case 1451, 1452:
+			var constraint string
+			if m := mysqlFKRe.FindStringSubmatch(myErr.Message); m != nil {
+				constraint = m[1]
+			}
+			return &ForeignKeyViolationError{Constraint: constraint, Err: err}
+		}
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case "23505":
+			return &UniqueViolationError{Index: pgErr.ConstraintName, Field: fieldFromIndex(pgErr.ConstraintName, s), Err: err}
+		case "23503":
+			return &ForeignKeyViolationError{Constraint: pgErr.ConstraintName, Err: err}
+		}
+	default:
+		// same as gorm sqlite driver, the error is not used by type to avoid the dependency on CGO
+		var sqliteErr struct{ ExtendedCode int }
+		if data, jsonErr := json.Marshal(err); jsonErr == nil && json.Unmarshal(data, &sqliteErr) == nil {
+			if sqliteUniqueCodes[sqliteErr.ExtendedCode] {
+				var col string
+				if m := sqliteUniqueRe.FindStringSubmatch(err.Error()); m != nil {
+					// e.g. `users.email, users.deleted_at`
+					first, _, _ := strings.Cut(m[1], ",")
+					_, col, _ = strings.Cut(strings.TrimSpace(first), ".")
+				}
+				return &UniqueViolationError{Field: fieldFromColumn(col, s), Err: err}
+			}
+			if sqliteErr.ExtendedCode == sqliteFKCode {
+				return &ForeignKeyViolationError{Err: err}
+			}
+		}
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &UniqueViolationError{Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &ForeignKeyViolationError{Err: err}
+	}
+	return err
+}
+
+// fieldFromIndex returns the offending field of the given index.
+// Without the schema, the field is guessed from the naming convention `uix_<table>_<column>`.
+func fieldFromIndex(index string, s *schema.Schema) string {
+	if index == "" {
+		return ""
+	}
+
+	if s != nil {
+		// the fields are sorted by priority
+		if idx, ok := s.ParseIndexes()[index]; ok && len(idx.Fields) > 0 {
+			return fieldName(idx.Fields[0].Field)
+		}
+	}
+
+	col := index
+	for _, p := range indexPrefixes {
+		if strings.HasPrefix(col, p) {
+			col = col[len(p):]
+			break
+		}
+	}
+	if s != nil {
+		col = strings.TrimPrefix(col, s.Table+"_")
+	}
+	return fieldFromColumn(col, s)
+}
+
+// fieldFromColumn returns the JSON name of the field of the given column if the schema is known
+func fieldFromColumn(col string, s *schema.Schema) string {
+	if s != nil {
+		if f := s.LookUpField(col); f != nil {
+			return fieldName(f)
+		}
+	}
+	return col
+}
+
+// fieldName returns the JSON name of the field, or its column name
+func fieldName(f *schema.Field) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.DBName
+}
+
+// ErrorTranslator is a gorm plugin which translates the errors of all queries, see TranslateError.
+// Having the model, the offending field of unique violations is derived from the index definition.
+type ErrorTranslator struct{}
+
+// Name implements gorm.Plugin interface
+func (ErrorTranslator) Name() string {
+	return "dbutil:error_translator"
+}
+
+// Initialize implements gorm.Plugin interface
+func (ErrorTranslator) Initialize(db *gorm.DB) error {
+	translate := func(db *gorm.DB) {
+		if db.Error != nil {
+			db.Error = translateError(db.Error, db.Statement.Schema)
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register("dbutil:translate_error", translate),
+		cb.Update().After("gorm:update").Register("dbutil:translate_error", translate),
+		cb.Delete().After("gorm:delete").Register("dbutil:translate_error", translate),
+		cb.Query().After("gorm:query").Register("dbutil:translate_error", translate),
+		cb.Raw().After("gorm:raw").Register("dbutil:translate_error", translate),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package dbutil_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	dbutil "runar-himmel/pkg/util/db"
+	"runar-himmel/pkg/util/db/dbtest"
+)
+
+type team struct {
+	ID string `gorm:"primaryKey"`
+}
+
+type player struct {
+	ID        string `gorm:"primaryKey"`
+	Email     string `json:"email_address" gorm:"uniqueIndex:uix_players_email,priority:1"`
+	DeletedAt int64  `gorm:"uniqueIndex:uix_players_email,priority:2"`
+	TeamID    *string
+	Team      *team
+}
+
+func TestErrorTranslator(t *testing.T) {
+	db := dbtest.New(t, &team{}, &player{})
+	require.NoError(t, db.Use(dbutil.ErrorTranslator{}))
+	require.NoError(t, db.Create(&team{ID: "t1"}).Error)
+	require.NoError(t, db.Create(&player{ID: "1", Email: "odin@asgard"}).Error)
+
+	var uniqueErr *dbutil.UniqueViolationError
+	err := db.Create(&player{ID: "2", Email: "odin@asgard"}).Error
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "email_address", uniqueErr.Field)
+
+	// other columns of the index do not matter
+	require.NoError(t, db.Create(&player{ID: "2", Email: "odin@asgard", DeletedAt: 1}).Error)
+
+	err = db.Create(&player{ID: "1", Email: "thor@asgard"}).Error
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "id", uniqueErr.Field)
+
+	var fkErr *dbutil.ForeignKeyViolationError
+	missing := "t2"
+	err = db.Create(&player{ID: "3", Email: "loki@asgard", TeamID: &missing}).Error
+	assert.ErrorAs(t, err, &fkErr)
+
+	err = db.Model(&player{}).Where("id = ?", "2").Update("deleted_at", 0).Error
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "email_address", uniqueErr.Field)
+
+	assert.ErrorIs(t, db.Take(&player{}, "id = ?", "0").Error, gorm.ErrRecordNotFound)
+}
+
+func TestTranslateError(t *testing.T) {
+	var uniqueErr *dbutil.UniqueViolationError
+	var fkErr *dbutil.ForeignKeyViolationError
+
+	// MySQL 8 & 5.7
+	err := dbutil.TranslateError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c-0' for key 'users.uix_users_email'"})
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "uix_users_email", uniqueErr.Index)
+	assert.Equal(t, "users_email", uniqueErr.Field)
+
+	err = dbutil.TranslateError(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'uix_phone'"}))
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "phone", uniqueErr.Field)
+
+	err = dbutil.TranslateError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`memos`, CONSTRAINT `fk_memos_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"})
+	require.ErrorAs(t, err, &fkErr)
+	assert.Equal(t, "fk_memos_user", fkErr.Constraint)
+
+	// PostgreSQL
+	err = dbutil.TranslateError(&pgconn.PgError{Code: "23505", ConstraintName: "uix_users_phone"})
+	require.ErrorAs(t, err, &uniqueErr)
+	assert.Equal(t, "users_phone", uniqueErr.Field)
+
+	err = dbutil.TranslateError(&pgconn.PgError{Code: "23503", ConstraintName: "fk_memos_user"})
+	require.ErrorAs(t, err, &fkErr)
+
+	// others are kept as is
+	assert.ErrorIs(t, dbutil.TranslateError(gorm.ErrRecordNotFound), gorm.ErrRecordNotFound)
+	other := errors.New("other")
+	assert.Equal(t, other, dbutil.TranslateError(other))
+	assert.Nil(t, dbutil.TranslateError(nil))
+}
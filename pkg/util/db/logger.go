@@ -0,0 +1,86 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+type requestIDCtxKey struct{}
+
+// WithRequestID returns a copy of ctx which carries the request ID, so the queries of the request can be traced in the logs
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, empty if none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// LoggerConfig holds the query logger settings
+type LoggerConfig struct {
+	// Queries taking longer are logged as slow queries regardless of the level, even silent. Zero to disable.
+	SlowThreshold time.Duration
+	LogLevel      logger.LogLevel
+}
+
+// queryLogger is the gorm logger prefixing the queries with the request ID of the context, see WithRequestID
+type queryLogger struct {
+	logger.Interface
+	writer logger.Writer
+	cfg    LoggerConfig
+}
+
+// NewLogger returns the gorm logger writing to w. Unlike the default one, slow queries are logged even at the silent level,
+// and all query logs include the request ID of the context if any. Not found errors are not logged.
+func NewLogger(w logger.Writer, cfg LoggerConfig) logger.Interface {
+	return &queryLogger{
+		Interface: logger.New(w, logger.Config{LogLevel: cfg.LogLevel}),
+		writer:    w,
+		cfg:       cfg,
+	}
+}
+
+func (l *queryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.Interface = l.Interface.LogMode(level)
+	nl.cfg.LogLevel = level
+	return &nl
+}
+
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	slow := l.cfg.SlowThreshold > 0 && elapsed > l.cfg.SlowThreshold
+
+	var msg string
+	switch {
+	case err != nil && l.cfg.LogLevel >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		msg = err.Error()
+	case slow:
+		msg = fmt.Sprintf("SLOW SQL >= %v", l.cfg.SlowThreshold)
+	case l.cfg.LogLevel >= logger.Info:
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	rowsStr := "-"
+	if rows >= 0 {
+		rowsStr = fmt.Sprint(rows)
+	}
+	prefix := ""
+	if id := RequestIDFromContext(ctx); id != "" {
+		prefix = "[request_id:" + id + "] "
+	}
+	if msg != "" {
+		msg += " "
+	}
+	l.writer.Printf("%s %s%s[%.3fms] [rows:%s] %s", utils.FileWithLineNum(), prefix, msg, float64(elapsed.Nanoseconds())/1e6, rowsStr, sql)
+}
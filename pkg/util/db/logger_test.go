@@ -0,0 +1,68 @@
+package dbutil_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	dbutil "runar-himmel/pkg/util/db"
+)
+
+type bufWriter struct {
+	lines []string
+}
+
+func (w *bufWriter) Printf(format string, args ...any) {
+	w.lines = append(w.lines, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+	w := &bufWriter{}
+	l := dbutil.NewLogger(w, dbutil.LoggerConfig{SlowThreshold: 100 * time.Millisecond, LogLevel: logger.Silent})
+	ctx := dbutil.WithRequestID(context.Background(), "req-1")
+	fc := func() (string, int64) { return "SELECT 1", 1 }
+
+	// fast queries & errors are not logged at the silent level
+	l.Trace(ctx, time.Now(), fc, nil)
+	l.Trace(ctx, time.Now(), fc, fmt.Errorf("boom"))
+	assert.Empty(t, w.lines)
+
+	// slow queries are logged regardless of the level, with the request ID
+	l.Trace(ctx, time.Now().Add(-time.Second), fc, nil)
+	if assert.Len(t, w.lines, 1) {
+		assert.Contains(t, w.lines[0], "[request_id:req-1] SLOW SQL >= 100ms")
+		assert.Contains(t, w.lines[0], "[rows:1] SELECT 1")
+	}
+
+	// errors except not found at the error level
+	w.lines = nil
+	l = l.LogMode(logger.Error)
+	l.Trace(context.Background(), time.Now(), fc, fmt.Errorf("boom"))
+	l.Trace(context.Background(), time.Now(), fc, gorm.ErrRecordNotFound)
+	l.Trace(context.Background(), time.Now(), fc, nil)
+	if assert.Len(t, w.lines, 1) {
+		assert.Contains(t, w.lines[0], "boom")
+		assert.NotContains(t, w.lines[0], "request_id")
+	}
+
+	// everything at the info level
+	w.lines = nil
+	l = l.LogMode(logger.Info)
+	l.Trace(ctx, time.Now(), func() (string, int64) { return "UPDATE x", -1 }, nil)
+	if assert.Len(t, w.lines, 1) {
+		assert.Contains(t, w.lines[0], "[request_id:req-1] [")
+		assert.True(t, strings.HasSuffix(w.lines[0], "[rows:-] UPDATE x"))
+	}
+
+	// zero threshold disables the slow query log
+	w.lines = nil
+	l = dbutil.NewLogger(w, dbutil.LoggerConfig{LogLevel: logger.Silent})
+	l.Trace(ctx, time.Now().Add(-time.Hour), fc, nil)
+	assert.Empty(t, w.lines)
+}
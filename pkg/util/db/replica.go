@@ -0,0 +1,126 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"runar-himmel/config"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type primaryCtxKey struct{}
+
+// WithPrimary returns a copy of ctx which routes all reads to the primary database,
+// e.g. for read-after-write flows which cannot afford the replication lag
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+// UsePrimary checks whether ctx requires reading from the primary database, see WithPrimary
+func UsePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryCtxKey{}).(bool)
+	return v
+}
+
+// PoolConfig holds the connection pool settings
+type PoolConfig struct {
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// NewPoolConfig returns the connection pool settings of the database config.
+// SQLite in-memory databases keep at least one connection forever, otherwise the data is lost.
+func NewPoolConfig(cfg config.DB) PoolConfig {
+	pool := PoolConfig{
+		MaxIdleConns:    cfg.MaxIdleConns,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		ConnMaxLifetime: time.Duration(cfg.ConnMaxLifetime) * time.Second,
+		ConnMaxIdleTime: time.Duration(cfg.ConnMaxIdleTime) * time.Second,
+	}
+	if IsMemory(cfg) {
+		pool.MaxIdleConns = max(pool.MaxIdleConns, 1)
+		pool.ConnMaxLifetime, pool.ConnMaxIdleTime = 0, 0
+	}
+	return pool
+}
+
+// UseReplicas routes the reads to the replicas of the config and the writes to the primary.
+// Each replica is given as `host[:port]` and shares the other settings with the primary.
+// Reads in transactions or with WithPrimary context go to the primary.
+func UseReplicas(db *gorm.DB, dialect string, cfg config.DB) error {
+	replicas := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for _, r := range cfg.Replicas {
+		rcfg := cfg
+		host, port, err := net.SplitHostPort(r)
+		if err != nil {
+			// no port
+			host = r
+		} else if rcfg.Port, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("invalid db replica '%s': %w", r, err)
+		}
+		rcfg.Host = host
+
+		d, err := Dialector(dialect, rcfg)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, d)
+	}
+
+	return RegisterReplicas(db, replicas, NewPoolConfig(cfg))
+}
+
+// RegisterReplicas routes the reads to the given replicas and the writes to the primary, see UseReplicas
+func RegisterReplicas(db *gorm.DB, replicas []gorm.Dialector, pool PoolConfig) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: dbresolver.RandomPolicy{}}).
+		SetMaxIdleConns(pool.MaxIdleConns).
+		SetMaxOpenConns(pool.MaxOpenConns).
+		SetConnMaxLifetime(pool.ConnMaxLifetime).
+		SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	if err := db.Use(resolver); err != nil {
+		return err
+	}
+
+	// must run before dbresolver picks the connection, which is also registered before "*",
+	// the callbacks registered later run first
+	usePrimary := func(db *gorm.DB) {
+		if db.Statement.Context != nil && UsePrimary(db.Statement.Context) {
+			dbresolver.Write.ModifyStatement(db.Statement)
+		}
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Before("*").Register("dbutil:use_primary", usePrimary),
+		cb.Row().Before("*").Register("dbutil:use_primary", usePrimary),
+		cb.Raw().Before("*").Register("dbutil:use_primary", usePrimary),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPool applies the connection pool settings to the primary database
+func SetPool(db *gorm.DB, pool PoolConfig) error {
+	sqldb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqldb.SetMaxIdleConns(pool.MaxIdleConns)
+	sqldb.SetMaxOpenConns(pool.MaxOpenConns)
+	sqldb.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqldb.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	return nil
+}
@@ -0,0 +1,66 @@
+package dbutil_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	dbutil "runar-himmel/pkg/util/db"
+)
+
+func TestRegisterReplicas(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath, replicaPath := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+
+	// the replica has different data, to see where the reads go
+	replica, err := gorm.Open(sqlite.Open(replicaPath), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, replica.AutoMigrate(&team{}))
+	require.NoError(t, replica.Create(&team{ID: "from replica"}).Error)
+	sqldb, _ := replica.DB()
+	sqldb.Close()
+
+	db, err := gorm.Open(sqlite.Open(primaryPath), &gorm.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqldb, err := db.DB(); err == nil {
+			sqldb.Close()
+		}
+	})
+	require.NoError(t, db.AutoMigrate(&team{}))
+	require.NoError(t, dbutil.RegisterReplicas(db, []gorm.Dialector{sqlite.Open(replicaPath)}, dbutil.PoolConfig{MaxOpenConns: 2}))
+	require.NoError(t, dbutil.SetPool(db, dbutil.PoolConfig{MaxIdleConns: 1, MaxOpenConns: 2}))
+
+	// writes go to the primary, reads go to the replica
+	ctx := context.Background()
+	require.NoError(t, db.WithContext(ctx).Create(&team{ID: "from primary"}).Error)
+	rec := &team{}
+	require.NoError(t, db.WithContext(ctx).Take(rec).Error)
+	assert.Equal(t, "from replica", rec.ID)
+
+	var id string
+	require.NoError(t, db.WithContext(ctx).Raw("SELECT id FROM teams").Scan(&id).Error)
+	assert.Equal(t, "from replica", id)
+
+	// unless reading from the primary explicitly
+	rec = &team{}
+	require.NoError(t, db.WithContext(dbutil.WithPrimary(ctx)).Take(rec).Error)
+	assert.Equal(t, "from primary", rec.ID)
+	require.NoError(t, db.WithContext(dbutil.WithPrimary(ctx)).Raw("SELECT id FROM teams").Scan(&id).Error)
+	assert.Equal(t, "from primary", id)
+
+	// or in transactions
+	rec = &team{}
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return tx.Take(rec).Error
+	}))
+	assert.Equal(t, "from primary", rec.ID)
+
+	assert.False(t, dbutil.UsePrimary(ctx))
+	assert.NoError(t, dbutil.RegisterReplicas(db, nil, dbutil.PoolConfig{}))
+}
@@ -11,10 +11,34 @@ import (
 	"gorm.io/gorm"
 )
 
-// New creates new database connection to the database server
+// New creates new database connection to the database server, see Dialect for the supported drivers
 func New(dialect string, cfg config.DB, gormConfig *gorm.Config) (db *gorm.DB, err error) {
+	dialector, err := Dialector(dialect, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if db, err = gorm.Open(dialector, gormConfig); err != nil {
+		return nil, err
+	}
+	if err := db.Use(ErrorTranslator{}); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Dialector returns the gorm dialector of the given dialect, connecting to the database server of the config
+func Dialector(dialect string, cfg config.DB) (gorm.Dialector, error) {
+	switch dialect = Dialect(dialect); dialect {
+	case DialectMySQL, DialectPostgres:
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("db host is required for %s", dialect)
+		}
+	}
+
 	switch dialect {
-	case "mysql":
+	case DialectMySQL:
 		params, err := url.ParseQuery(cfg.Params)
 		if err != nil {
 			return nil, fmt.Errorf("invalid db params '%s': %w", cfg.Params, err)
@@ -41,15 +65,12 @@ func New(dialect string, cfg config.DB, gormConfig *gorm.Config) (db *gorm.DB, e
 		)
 
 		var datetimePrecision = 3
-		db, err = gorm.Open(mysql.New(mysql.Config{
+		return mysql.New(mysql.Config{
 			DSN:                      dbDsn,
 			DefaultStringSize:        255,
 			DefaultDatetimePrecision: &datetimePrecision,
-		}), gormConfig)
-		if err != nil {
-			return nil, err
-		}
-	case "postgres":
+		}), nil
+	case DialectPostgres:
 		params, err := url.ParseQuery(cfg.Params)
 		if err != nil {
 			return nil, fmt.Errorf("invalid db params '%s': %w", cfg.Params, err)
@@ -72,21 +93,18 @@ func New(dialect string, cfg config.DB, gormConfig *gorm.Config) (db *gorm.DB, e
 			params.Encode(),
 		)
 
-		db, err = gorm.Open(postgres.New(postgres.Config{
+		return postgres.New(postgres.Config{
 			DSN: dbDsn,
 			// Note: set to false to disable implicit prepared statement usage, in case using pgbouncer for example
 			PreferSimpleProtocol: true,
-		}), gormConfig)
-		if err != nil {
-			return nil, err
-		}
-	case "sqlite3":
-		var dbDsn string
-		db, err = gorm.Open(sqlite.Open(dbDsn), gormConfig)
+		}), nil
+	case DialectSQLite:
+		dbDsn, err := sqliteDSN(cfg)
 		if err != nil {
 			return nil, err
 		}
+		return sqlite.Open(dbDsn), nil
 	}
 
-	return db, nil
+	return nil, fmt.Errorf("unsupported db dialect '%s'", dialect)
 }
@@ -0,0 +1,40 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"runar-himmel/pkg/server"
+	dbutil "runar-himmel/pkg/util/db"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"gorm.io/gorm"
+)
+
+// TranslateError converts the errors of the database & the repos into HTTPError, nil if unknown.
+// It is meant for server.Config.ErrorTranslators.
+func TranslateError(err error) *server.HTTPError {
+	err = dbutil.TranslateError(err)
+
+	var conflictErr *repoutil.VersionConflictError
+	var uniqueErr *dbutil.UniqueViolationError
+	var fkErr *dbutil.ForeignKeyViolationError
+	switch {
+	case errors.As(err, &conflictErr):
+		return server.NewHTTPError(http.StatusConflict, server.ConflictErrorType, "The record has been changed by someone else, please reload and try again").SetInternal(err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return server.NewHTTPError(http.StatusNotFound, server.NotFoundErrorType, "Record not found").SetInternal(err)
+	case errors.As(err, &uniqueErr):
+		msg := "The record already exists"
+		if uniqueErr.Field != "" {
+			msg = fmt.Sprintf("The %s already exists", uniqueErr.Field)
+		}
+		return server.NewHTTPError(http.StatusConflict, server.ConflictErrorType, msg).SetInternal(err)
+	case errors.As(err, &fkErr):
+		return server.NewHTTPValidationError("The record references a missing record, or is still referenced by other records").SetInternal(err)
+	case errors.Is(err, repoutil.ErrSearchNotSupported):
+		return server.NewHTTPValidationError("Searching is not supported for this listing").SetInternal(err)
+	}
+	return nil
+}
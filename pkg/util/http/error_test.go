@@ -0,0 +1,44 @@
+package httputil_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/server"
+	dbutil "runar-himmel/pkg/util/db"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+func TestTranslateError(t *testing.T) {
+	cases := []struct {
+		err   error
+		code  int
+		etype string
+	}{
+		{fmt.Errorf("updating memo: %w", &repoutil.VersionConflictError{Version: 2}), http.StatusConflict, server.ConflictErrorType},
+		{fmt.Errorf("reading user: %w", gorm.ErrRecordNotFound), http.StatusNotFound, server.NotFoundErrorType},
+		{&dbutil.UniqueViolationError{Field: "email", Err: gorm.ErrDuplicatedKey}, http.StatusConflict, server.ConflictErrorType},
+		// the raw database errors are translated as well
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'users.uix_users_phone'"}, http.StatusConflict, server.ConflictErrorType},
+		{&dbutil.ForeignKeyViolationError{Err: gorm.ErrForeignKeyViolated}, http.StatusBadRequest, server.ValidationErrorType},
+		// listing with a search query by a repo without searcher
+		{fmt.Errorf("list: %w", repoutil.ErrSearchNotSupported), http.StatusBadRequest, server.ValidationErrorType},
+	}
+	for _, tc := range cases {
+		he := httputil.TranslateError(tc.err)
+		require.NotNil(t, he, tc.err)
+		assert.Equal(t, tc.code, he.Code, tc.err)
+		assert.Equal(t, tc.etype, he.Type, tc.err)
+	}
+
+	assert.Equal(t, "The email already exists", httputil.TranslateError(&dbutil.UniqueViolationError{Field: "email", Err: gorm.ErrDuplicatedKey}).Message)
+	assert.Nil(t, httputil.TranslateError(errors.New("unknown")))
+}
@@ -0,0 +1,37 @@
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"runar-himmel/pkg/server"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SetETag sets the ETag response header to the given record version, e.g. `"3"`
+func SetETag(c echo.Context, version int64) {
+	c.Response().Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ErrWeakETag is returned for the weak ETags in the If-Match header, they never match the strong ETags of the records
+var ErrWeakETag = server.NewHTTPError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "Weak ETags cannot be used in If-Match")
+
+// ReqIfMatch returns the record version from the If-Match request header, zero if not given or `*`.
+// Only strong ETags `"3"` are accepted, If-Match uses the strong comparison (RFC 7232 section 3.1).
+func ReqIfMatch(c echo.Context) (int64, error) {
+	v := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+
+	if strings.HasPrefix(v, "W/") {
+		return 0, ErrWeakETag
+	}
+	version, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+	if err != nil || version < 1 {
+		return 0, server.NewHTTPValidationError("Invalid If-Match header, expecting the ETag of the record")
+	}
+	return version, nil
+}
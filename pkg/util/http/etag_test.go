@@ -0,0 +1,42 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	httputil "runar-himmel/pkg/util/http"
+)
+
+func TestETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	httputil.SetETag(c, 3)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	cases := map[string]int64{"": 0, "*": 0, `"3"`: 3, "5": 5}
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		req.Header.Set("If-Match", header)
+		version, err := httputil.ReqIfMatch(echo.New().NewContext(req, httptest.NewRecorder()))
+		require.NoError(t, err, header)
+		assert.Equal(t, want, version, header)
+	}
+
+	for _, header := range []string{`"abc"`, `"0"`} {
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		req.Header.Set("If-Match", header)
+		_, err := httputil.ReqIfMatch(echo.New().NewContext(req, httptest.NewRecorder()))
+		assert.Error(t, err, header)
+	}
+
+	// never matching with the strong comparison
+	req := httptest.NewRequest(http.MethodPatch, "/", nil)
+	req.Header.Set("If-Match", `W/"4"`)
+	_, err := httputil.ReqIfMatch(echo.New().NewContext(req, httptest.NewRecorder()))
+	assert.ErrorIs(t, err, httputil.ErrWeakETag)
+}
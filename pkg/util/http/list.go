@@ -0,0 +1,226 @@
+package httputil
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"runar-himmel/pkg/server"
+	repoutil "runar-himmel/pkg/util/repo"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/schema"
+)
+
+// Default paging values for listing requests
+const (
+	DefaultPerPage = 25
+	MaxPerPage     = 100
+	// Maximum length of the full-text search query
+	MaxSearchLength = 100
+)
+
+// FilterOperators are the supported filter operators, see github.com/imdatngo/gowhere for the meaning of each one
+var FilterOperators = []string{
+	"exact", "iexact", "notexact", "inotexact",
+	"gt", "gte", "lt", "lte",
+	"startswith", "istartswith", "endswith", "iendswith", "contains", "icontains",
+	"in", "isnull", "date", "between",
+}
+
+// ListQueryConfig holds the optional configurations for parsing listing requests
+type ListQueryConfig struct {
+	// Number of records per page if not given, DefaultPerPage if zero
+	DefaultPerPage int
+	// Maximum number of records per page, MaxPerPage if zero
+	MaxPerPage int
+	// Sorting if not given, e.g. `-created_at`
+	DefaultSort string
+}
+
+// listField represents a field of the model which is allowed in listing requests
+type listField struct {
+	column    string
+	operators map[string]bool
+	sortable  bool
+}
+
+// listFieldsCache caches the allowed fields per model type
+var listFieldsCache sync.Map
+
+// ReqListQuery parses the listing request of the model T from the url query string:
+//
+//	?page=2&per_page=10&sort=-created_at,name&filter[name__icontains]=gopher&filter[status__in]=active,blocked&q=go
+//
+// `q` is the full-text search query, the results are ranked by relevance unless `sort` is given, DefaultSort is not applied.
+// `page` is zero if not given, so the handler can choose cursor pagination with the optional `cursor`. Fields are referred by their JSON names,
+// only the ones whitelisted by struct tags are allowed:
+//
+//	Name string `json:"name" filter:"exact,icontains" sort:"true"`
+//
+// The operator defaults to `exact` when omitted. Anything not allowed results in a validation error.
+func ReqListQuery[T any](c echo.Context, cfg ...ListQueryConfig) (*repoutil.ListQueryCondition, error) {
+	return ParseListQuery[T](c.QueryParams(), cfg...)
+}
+
+// ParseListQuery parses the listing query of the model T from the given values, e.g. from the command line, see ReqListQuery
+func ParseListQuery[T any](params url.Values, cfg ...ListQueryConfig) (*repoutil.ListQueryCondition, error) {
+	conf := ListQueryConfig{DefaultPerPage: DefaultPerPage, MaxPerPage: MaxPerPage}
+	if len(cfg) > 0 {
+		conf.DefaultSort = cfg[0].DefaultSort
+		if cfg[0].DefaultPerPage > 0 {
+			conf.DefaultPerPage = cfg[0].DefaultPerPage
+		}
+		if cfg[0].MaxPerPage > 0 {
+			conf.MaxPerPage = cfg[0].MaxPerPage
+		}
+	}
+
+	fields, err := listFields[T]()
+	if err != nil {
+		return nil, err
+	}
+	return parseListQuery(params, fields, conf)
+}
+
+func parseListQuery(params url.Values, fields map[string]*listField, conf ListQueryConfig) (*repoutil.ListQueryCondition, error) {
+	lqc := &repoutil.ListQueryCondition{PerPage: conf.DefaultPerPage, Count: true, Cursor: params.Get("cursor")}
+
+	if v := params.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return nil, server.NewHTTPValidationError("Invalid page, expecting a positive number")
+		}
+		lqc.Page = page
+	}
+	if v := params.Get("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage < 1 || perPage > conf.MaxPerPage {
+			return nil, server.NewHTTPValidationError(fmt.Sprintf("Invalid per_page, expecting a number from 1 to %d", conf.MaxPerPage))
+		}
+		lqc.PerPage = perPage
+	}
+
+	lqc.Search = strings.TrimSpace(params.Get("q"))
+	if len(lqc.Search) > MaxSearchLength {
+		return nil, server.NewHTTPValidationError(fmt.Sprintf("Invalid q, expecting at most %d characters", MaxSearchLength))
+	}
+
+	sort := params.Get("sort")
+	if sort == "" && lqc.Search == "" {
+		sort = conf.DefaultSort
+	}
+	if sort != "" {
+		values := []string{}
+		for _, v := range strings.Split(sort, ",") {
+			v = strings.TrimSpace(v)
+			dir := "+"
+			if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+				dir, v = v[:1], v[1:]
+			}
+			f, ok := fields[v]
+			if !ok || !f.sortable {
+				return nil, server.NewHTTPValidationError(fmt.Sprintf("Sorting by %q is not allowed", v))
+			}
+			values = append(values, dir+f.column)
+		}
+		lqc.Sort = strings.Join(values, ",")
+	}
+
+	filter := map[string]any{}
+	for key, values := range params {
+		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		param := key[len("filter[") : len(key)-1]
+		name, op, _ := strings.Cut(param, "__")
+		if op == "" {
+			op = "exact"
+		}
+
+		f, ok := fields[name]
+		if !ok || !f.operators[op] {
+			return nil, server.NewHTTPValidationError(fmt.Sprintf("Filtering by %q is not allowed", param))
+		}
+
+		value, err := parseFilterValue(op, values[0])
+		if err != nil {
+			return nil, server.NewHTTPValidationError(fmt.Sprintf("Invalid value for filter[%s]: %v", param, err)).SetInternal(err)
+		}
+		filter[f.column+"__"+op] = value
+	}
+	if len(filter) > 0 {
+		lqc.Filter = []any{filter}
+	}
+
+	return lqc, nil
+}
+
+// parseFilterValue converts the query string value to what the operator expects
+func parseFilterValue(op, v string) (any, error) {
+	switch op {
+	case "in":
+		return strings.Split(v, ","), nil
+	case "between":
+		values := strings.Split(v, ",")
+		if len(values) != 2 {
+			return nil, fmt.Errorf("expecting 2 comma separated values")
+		}
+		return []any{values[0], values[1]}, nil
+	case "isnull":
+		return strconv.ParseBool(v)
+	}
+	return v, nil
+}
+
+// listFields returns the fields of the model T which are allowed in listing requests, keyed by their JSON names
+func listFields[T any]() (map[string]*listField, error) {
+	typ := reflect.TypeOf(new(T)).Elem()
+	if cached, ok := listFieldsCache.Load(typ); ok {
+		return cached.(map[string]*listField), nil
+	}
+
+	s, err := schema.Parse(new(T), &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+
+	validOps := map[string]bool{}
+	for _, op := range FilterOperators {
+		validOps[op] = true
+	}
+
+	fields := map[string]*listField{}
+	for _, sf := range s.Fields {
+		filterTag, sortTag := sf.Tag.Get("filter"), sf.Tag.Get("sort")
+		if sf.DBName == "" || (filterTag == "" && sortTag == "") {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.DBName
+		}
+
+		f := &listField{column: sf.DBName, operators: map[string]bool{}, sortable: sortTag == "true"}
+		for _, op := range strings.Split(filterTag, ",") {
+			if op = strings.TrimSpace(op); op == "" {
+				continue
+			}
+			if !validOps[op] {
+				return nil, fmt.Errorf("httputil: unknown filter operator %q of %s.%s", op, s.Name, sf.Name)
+			}
+			f.operators[op] = true
+		}
+		fields[name] = f
+	}
+
+	listFieldsCache.Store(typ, fields)
+	return fields, nil
+}
@@ -0,0 +1,120 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imdatngo/gowhere"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/server"
+	httputil "runar-himmel/pkg/util/http"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type item struct {
+	ID        string     `json:"id" filter:"exact,in" sort:"true"`
+	FullName  string     `json:"name" filter:"exact,icontains" sort:"true"`
+	Secret    string     `json:"-" filter:"exact"`
+	Status    string     `json:"status" filter:"notexact,inotexact"`
+	DeletedAt *time.Time `json:"deleted_at" filter:"isnull"`
+	CreatedAt time.Time  `json:"created_at" filter:"between" sort:"true"`
+}
+
+func reqListQuery(t *testing.T, query string, cfg ...httputil.ListQueryConfig) (*repoutil.ListQueryCondition, error) {
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	return httputil.ReqListQuery[item](c, cfg...)
+}
+
+func TestReqListQuery(t *testing.T) {
+	lqc, err := reqListQuery(t, "")
+	require.NoError(t, err)
+	assert.Equal(t, &repoutil.ListQueryCondition{PerPage: httputil.DefaultPerPage, Count: true}, lqc)
+
+	lqc, err = reqListQuery(t, "", httputil.ListQueryConfig{DefaultPerPage: 10, DefaultSort: "-created_at"})
+	require.NoError(t, err)
+	assert.Equal(t, 10, lqc.PerPage)
+	assert.Equal(t, "-created_at", lqc.Sort)
+
+	lqc, err = reqListQuery(t, "page=2&per_page=50&cursor=abc&sort=-created_at,name,%2Bid"+
+		"&filter[name__icontains]=gopher&filter[id__in]=1,2&filter[deleted_at__isnull]=true&filter[name]=x"+
+		"&filter[created_at__between]=2024-01-01,2024-02-01&status=ignored")
+	require.NoError(t, err)
+	assert.Equal(t, 2, lqc.Page)
+	assert.Equal(t, 50, lqc.PerPage)
+	assert.Equal(t, "abc", lqc.Cursor)
+	assert.Equal(t, "-created_at,+full_name,+id", lqc.Sort)
+	assert.Equal(t, []any{map[string]any{
+		"full_name__icontains": "gopher",
+		"full_name__exact":     "x",
+		"id__in":               []string{"1", "2"},
+		"deleted_at__isnull":   true,
+		"created_at__between":  []any{"2024-01-01", "2024-02-01"},
+	}}, lqc.Filter)
+}
+
+func TestReqListQuerySearch(t *testing.T) {
+	cfg := httputil.ListQueryConfig{DefaultSort: "-created_at"}
+
+	lqc, err := reqListQuery(t, "q=%20odin%20asgard%20", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "odin asgard", lqc.Search)
+	assert.Empty(t, lqc.Sort, "ranked by relevance instead of the default sorting")
+
+	lqc, err = reqListQuery(t, "q=odin&sort=name", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "+full_name", lqc.Sort)
+}
+
+func TestReqListQueryInvalid(t *testing.T) {
+	cases := map[string]string{
+		"page":                 "page=0",
+		"page not a number":    "page=x",
+		"per_page":             "per_page=101",
+		"sort not allowed":     "sort=status",
+		"sort unknown":         "sort=name,id%20desc",
+		"sort hidden field":    "sort=-secret",
+		"filter not allowed":   "filter[status]=active",
+		"filter operator":      "filter[name__startswith]=a",
+		"filter hidden field":  "filter[secret]=x",
+		"filter column name":   "filter[full_name]=x",
+		"filter invalid value": "filter[deleted_at__isnull]=maybe",
+		"filter between":       "filter[created_at__between]=2024-01-01",
+		"search too long":      "q=" + strings.Repeat("a", httputil.MaxSearchLength+1),
+	}
+	for name, query := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := reqListQuery(t, query)
+			var he *server.HTTPError
+			require.ErrorAs(t, err, &he)
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+			assert.Equal(t, server.ValidationErrorType, he.Type)
+		})
+	}
+
+	lqc, err := reqListQuery(t, "per_page=200", httputil.ListQueryConfig{MaxPerPage: 500})
+	require.NoError(t, err)
+	assert.Equal(t, 200, lqc.PerPage)
+}
+
+func TestReqListQueryNegation(t *testing.T) {
+	// the negations are named as in gowhere, inotexact instead of notiexact
+	lqc, err := reqListQuery(t, "filter[status__notexact]=active&filter[status__inotexact]=Blocked")
+	require.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"status__notexact": "active", "status__inotexact": "Blocked"}}, lqc.Filter)
+
+	_, err = reqListQuery(t, "filter[status__notiexact]=Blocked")
+	assert.Error(t, err)
+}
+
+func TestFilterOperators(t *testing.T) {
+	for _, op := range httputil.FilterOperators {
+		assert.Contains(t, gowhere.OperatorsList, op)
+	}
+}
@@ -0,0 +1,167 @@
+package jobutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	dbutil "runar-himmel/pkg/util/db"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	repoutil "runar-himmel/pkg/util/repo"
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// DefaultQueue is the queue of the jobs enqueued without one
+const DefaultQueue = "default"
+
+// DefaultMaxAttempts is the number of attempts of the jobs enqueued without MaxAttempts
+const DefaultMaxAttempts = 5
+
+// Statuses of the jobs
+const (
+	// Waiting to run at RunAt, either the first time or for retrying
+	StatusPending = "pending"
+	// Claimed by a worker until RunAt, pending again afterward if the worker crashed
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	// Given up after the maximum attempts or a permanent error, see Permanent. Retry enqueues it again.
+	StatusDead = "dead"
+)
+
+// ErrNotDead is returned when retrying a job which is not dead
+var ErrNotDead = errors.New("jobutil: only dead jobs can be retried")
+
+// Job represents a unit of asynchronous work, run by Worker with the handler of its type
+// swagger:model Job
+type Job struct {
+	ID    string `json:"id" gorm:"primaryKey;type:varchar(26)" filter:"exact,in" sort:"true"`
+	Queue string `json:"queue" gorm:"type:varchar(64);not null;index:idx_jobs_pending,priority:2" filter:"exact,in"`
+	// The handler name, e.g. `push.send`
+	Type    string             `json:"type" gorm:"type:varchar(64)" filter:"exact,in"`
+	Payload outboxutil.Payload `json:"payload" gorm:"type:text"`
+	Status  string             `json:"status" gorm:"type:varchar(10);not null;default:pending;index:idx_jobs_pending,priority:1" filter:"exact,in"`
+	// When the job is due, or the end of the lease while running
+	RunAt       time.Time  `json:"run_at" gorm:"index:idx_jobs_pending,priority:3" filter:"gte,lte" sort:"true"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"not null"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	RequestID   string     `json:"request_id,omitempty" gorm:"type:varchar(64)" filter:"exact"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" filter:"gte,lte" sort:"true"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table of the jobs
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// BeforeCreate hook executed by gorm
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = ulidutil.NewString()
+	}
+	if j.Queue == "" {
+		j.Queue = DefaultQueue
+	}
+	if j.Status == "" {
+		j.Status = StatusPending
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now().UTC()
+	}
+	if j.MaxAttempts <= 0 {
+		j.MaxAttempts = DefaultMaxAttempts
+	}
+	return nil
+}
+
+// Decode decodes the JSON payload of the job into v
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// EnqueueOptions holds the options of Enqueue, all optional
+type EnqueueOptions struct {
+	// DefaultQueue if empty
+	Queue string
+	// Delays the job until the given time, now if zero. See also Delay.
+	RunAt time.Time
+	// Delays the job by the given duration, if RunAt is zero
+	Delay time.Duration
+	// DefaultMaxAttempts if zero
+	MaxAttempts int
+}
+
+// Enqueue creates a job of the type, in the transaction carried by ctx if any, see repoutil.WithTx.
+// Enqueuing in the same transaction as the changes makes sure the job runs if and only if they are committed.
+// The payload is encoded as JSON.
+func Enqueue(ctx context.Context, db *gorm.DB, typ string, payload any, opts EnqueueOptions) (*Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobutil: cannot encode the payload of %s: %w", typ, err)
+	}
+	runAt := opts.RunAt
+	if runAt.IsZero() && opts.Delay > 0 {
+		runAt = time.Now().Add(opts.Delay)
+	}
+	if !runAt.IsZero() {
+		runAt = runAt.UTC()
+	}
+	job := &Job{
+		Queue:       opts.Queue,
+		Type:        typ,
+		Payload:     b,
+		RunAt:       runAt,
+		MaxAttempts: opts.MaxAttempts,
+		RequestID:   dbutil.RequestIDFromContext(ctx),
+	}
+	if err := repoutil.Conn(ctx, db).Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Retry enqueues a dead job again with its attempts reset, to run now
+func Retry(ctx context.Context, db *gorm.DB, id string) error {
+	res := db.WithContext(ctx).Model(&Job{}).Where("id = ? AND status = ?", id, StatusDead).Updates(map[string]any{
+		"status":      StatusPending,
+		"attempts":    0,
+		"run_at":      time.Now().UTC(),
+		"finished_at": nil,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
+
+// permanentError marks the errors which must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps the error of a handler, so the job is dead immediately instead of being retried,
+// e.g. for invalid payloads
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent checks whether the error is marked by Permanent
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
@@ -0,0 +1,297 @@
+package jobutil_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	dbutil "runar-himmel/pkg/util/db"
+	"runar-himmel/pkg/util/db/dbtest"
+	jobutil "runar-himmel/pkg/util/job"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type mail struct {
+	To string `json:"to"`
+}
+
+func TestEnqueue(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	ctx := dbutil.WithRequestID(context.Background(), "req-1")
+
+	job, err := jobutil.Enqueue(ctx, db, "mail.send", mail{To: "odin@asgard"}, jobutil.EnqueueOptions{})
+	require.NoError(t, err)
+	got := dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID)
+	assert.Equal(t, jobutil.DefaultQueue, got.Queue)
+	assert.Equal(t, jobutil.StatusPending, got.Status)
+	assert.Equal(t, jobutil.DefaultMaxAttempts, got.MaxAttempts)
+	assert.Equal(t, "req-1", got.RequestID)
+	assert.WithinDuration(t, time.Now(), got.RunAt, time.Second)
+	m := mail{}
+	require.NoError(t, got.Decode(&m))
+	assert.Equal(t, "odin@asgard", m.To)
+
+	// delayed & scheduled
+	job, err = jobutil.Enqueue(ctx, db, "mail.send", nil, jobutil.EnqueueOptions{Queue: "mails", Delay: time.Hour, MaxAttempts: 1})
+	require.NoError(t, err)
+	got = dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID)
+	assert.Equal(t, "mails", got.Queue)
+	assert.Equal(t, 1, got.MaxAttempts)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), got.RunAt, time.Second)
+	at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	job, err = jobutil.Enqueue(ctx, db, "mail.send", nil, jobutil.EnqueueOptions{RunAt: at, Delay: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, at.Equal(dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID).RunAt))
+
+	// rolled back along with the transaction carried by ctx
+	errRollback := errors.New("rollback")
+	var id string
+	err = repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		job, err := jobutil.Enqueue(ctx, db, "mail.send", nil, jobutil.EnqueueOptions{})
+		if err != nil {
+			return err
+		}
+		id = job.ID
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	assert.ErrorIs(t, db.Take(&jobutil.Job{}, "id = ?", id).Error, gorm.ErrRecordNotFound)
+}
+
+func TestWorker(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	ctx := context.Background()
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{Queues: []string{"mails"}})
+	sent := []string{}
+	failures := 1
+	w.Handle("mail.send", func(ctx context.Context, job *jobutil.Job) error {
+		m := mail{}
+		if err := job.Decode(&m); err != nil {
+			return jobutil.Permanent(err)
+		}
+		if failures > 0 {
+			failures--
+			return errors.New("smtp down")
+		}
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		sent = append(sent, m.To)
+		return nil
+	})
+	w.Handle("panic", func(context.Context, *jobutil.Job) error {
+		panic("boom")
+	})
+
+	// only the due jobs of the queues
+	_, err := jobutil.Enqueue(ctx, db, "mail.send", mail{To: "thor@asgard"}, jobutil.EnqueueOptions{})
+	require.NoError(t, err)
+	_, err = jobutil.Enqueue(ctx, db, "mail.send", mail{To: "loki@asgard"}, jobutil.EnqueueOptions{Queue: "mails", Delay: time.Hour})
+	require.NoError(t, err)
+	ok, err := w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// retried with backoff
+	job, err := jobutil.Enqueue(ctx, db, "mail.send", mail{To: "odin@asgard"}, jobutil.EnqueueOptions{Queue: "mails"})
+	require.NoError(t, err)
+	ok, err = w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got := dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID)
+	assert.Equal(t, jobutil.StatusPending, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, "smtp down", got.LastError)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), got.RunAt, time.Second)
+
+	dbtest.MakeDue(t, db, &jobutil.Job{}, "run_at", "id = ?", job.ID)
+	ok, err = w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got = dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID)
+	assert.Equal(t, jobutil.StatusSucceeded, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Empty(t, got.LastError)
+	assert.NotNil(t, got.FinishedAt)
+	assert.Equal(t, []string{"odin@asgard"}, sent)
+
+	// dead after the maximum attempts, on permanent errors, panics & unknown types, then retried manually
+	failures = 1
+	job, err = jobutil.Enqueue(ctx, db, "mail.send", mail{To: "frigg@asgard"}, jobutil.EnqueueOptions{Queue: "mails", MaxAttempts: 1})
+	require.NoError(t, err)
+	dead := []string{job.ID}
+	for _, typ := range []string{"panic", "unknown"} {
+		job, err = jobutil.Enqueue(ctx, db, typ, nil, jobutil.EnqueueOptions{Queue: "mails", MaxAttempts: 2})
+		require.NoError(t, err)
+		dead = append(dead, job.ID)
+	}
+	job, err = jobutil.Enqueue(ctx, db, "mail.send", "not a mail", jobutil.EnqueueOptions{Queue: "mails"})
+	require.NoError(t, err)
+	dead = append(dead, job.ID)
+	for i := 0; i < 4; i++ {
+		ok, err = w.WorkOnce(ctx)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, jobutil.StatusPending, dbtest.Take[jobutil.Job](t, db, "id = ?", dead[1]).Status)
+	dbtest.MakeDue(t, db, &jobutil.Job{}, "run_at", "id = ?", dead[1])
+	ok, err = w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	for _, id := range dead {
+		assert.Equal(t, jobutil.StatusDead, dbtest.Take[jobutil.Job](t, db, "id = ?", id).Status, id)
+	}
+	assert.Contains(t, dbtest.Take[jobutil.Job](t, db, "id = ?", dead[1]).LastError, "panic: boom")
+	assert.Equal(t, `no handler of job type "unknown"`, dbtest.Take[jobutil.Job](t, db, "id = ?", dead[2]).LastError)
+	assert.Equal(t, 1, dbtest.Take[jobutil.Job](t, db, "id = ?", dead[3]).Attempts)
+
+	require.NoError(t, jobutil.Retry(ctx, db, dead[0]))
+	assert.ErrorIs(t, jobutil.Retry(ctx, db, dead[0]), jobutil.ErrNotDead)
+	got = dbtest.Take[jobutil.Job](t, db, "id = ?", dead[0])
+	assert.Equal(t, jobutil.StatusPending, got.Status)
+	assert.Zero(t, got.Attempts)
+	ok, err = w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"odin@asgard", "frigg@asgard"}, sent)
+}
+
+func TestWorkerLease(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	ctx := context.Background()
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{Lease: 50 * time.Millisecond})
+	runs := atomic.Int32{}
+	w.Handle("slow", func(ctx context.Context, job *jobutil.Job) error {
+		if runs.Add(1) == 1 {
+			// the handler is canceled at the end of the lease
+			<-ctx.Done()
+			// the job of a crashed worker is claimed again after the lease
+			ok, err := w.WorkOnce(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	job, err := jobutil.Enqueue(ctx, db, "slow", nil, jobutil.EnqueueOptions{})
+	require.NoError(t, err)
+	_, err = w.WorkOnce(ctx)
+	assert.ErrorContains(t, err, "has been claimed again after the lease")
+	assert.EqualValues(t, 2, runs.Load())
+	assert.Equal(t, jobutil.StatusSucceeded, dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID).Status)
+}
+
+func TestWorkerLeaseExpired(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	ctx := context.Background()
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{})
+	runs := atomic.Int32{}
+	w.Handle("crash", func(context.Context, *jobutil.Job) error {
+		runs.Add(1)
+		// the worker is gone without recording the result
+		runtime.Goexit()
+		return nil
+	})
+	crash := func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = w.WorkOnce(ctx)
+		}()
+		<-done
+	}
+
+	job, err := jobutil.Enqueue(ctx, db, "crash", nil, jobutil.EnqueueOptions{MaxAttempts: 2})
+	require.NoError(t, err)
+
+	// every claim is counted as an attempt
+	crash()
+	assert.Equal(t, 1, dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID).Attempts)
+	dbtest.MakeDue(t, db, &jobutil.Job{}, "run_at", "id = ?", job.ID)
+	crash()
+	assert.Equal(t, 2, dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID).Attempts)
+
+	// given up instead of running it again once the attempts are used up
+	dbtest.MakeDue(t, db, &jobutil.Job{}, "run_at", "id = ?", job.ID)
+	ok, err := w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.EqualValues(t, 2, runs.Load())
+	rec := dbtest.Take[jobutil.Job](t, db, "id = ?", job.ID)
+	assert.Equal(t, jobutil.StatusDead, rec.Status)
+	assert.Equal(t, 2, rec.Attempts)
+	assert.Contains(t, rec.LastError, "lease")
+	assert.NotNil(t, rec.FinishedAt)
+
+	ok, err = w.WorkOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestWorkerRun(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{Concurrency: 3, PollInterval: 10 * time.Millisecond})
+	mu := sync.Mutex{}
+	done := map[string]int{}
+	w.Handle("mail.send", func(ctx context.Context, job *jobutil.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		done[job.ID]++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(ctx, func(err error) { t.Error(err) })
+		close(stopped)
+	}()
+
+	for i := 0; i < 10; i++ {
+		_, err := jobutil.Enqueue(context.Background(), db, "mail.send", nil, jobutil.EnqueueOptions{})
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(done) == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop")
+	}
+	// each job runs once
+	for id, n := range done {
+		assert.Equal(t, 1, n, id)
+	}
+}
+
+func TestWorkerDrain(t *testing.T) {
+	db := dbtest.New(t, &jobutil.Job{})
+	ctx := context.Background()
+	w := jobutil.NewWorker(db, jobutil.WorkerConfig{Concurrency: 2})
+	runs := atomic.Int32{}
+	w.Handle("mail.send", func(context.Context, *jobutil.Job) error {
+		runs.Add(1)
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		_, err := jobutil.Enqueue(ctx, db, "mail.send", nil, jobutil.EnqueueOptions{})
+		require.NoError(t, err)
+	}
+	_, err := jobutil.Enqueue(ctx, db, "mail.send", nil, jobutil.EnqueueOptions{Delay: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, w.Drain(ctx))
+	assert.EqualValues(t, 5, runs.Load())
+}
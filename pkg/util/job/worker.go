@@ -0,0 +1,202 @@
+package jobutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	queueutil "runar-himmel/pkg/util/queue"
+
+	"gorm.io/gorm"
+)
+
+// errLeaseExpired is the last error of the jobs given up after the worker running their last attempt crashed
+const errLeaseExpired = "the lease of the last attempt expired without a result, e.g. the worker crashed"
+
+// Handler runs a job, the job is retried if it returns an error unless the error is Permanent.
+// The context is canceled at the end of the lease, see WorkerConfig.Lease.
+// Jobs may run more than once, e.g. when a worker crashes, so handlers should be idempotent.
+type Handler func(ctx context.Context, job *Job) error
+
+// WorkerConfig holds the worker configurations, zero values are replaced by the defaults
+type WorkerConfig struct {
+	// The queues to work on, DefaultQueue if empty
+	Queues []string
+	// Number of jobs run concurrently, default 4
+	Concurrency int
+	// How often the queues are polled when idle, default 1 second
+	PollInterval time.Duration
+	// How long a claimed job is reserved for its worker, default 5 minutes.
+	// It is also the timeout of the handlers, the job is run again afterward if the worker crashed.
+	Lease time.Duration
+	// The delay before the first retry, doubled for every further attempt up to MaxBackoff. Default 10 seconds & 1 hour.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Worker runs the jobs of the queues with the registered handlers.
+// Multiple workers may run concurrently, see queueutil.Claim.
+type Worker struct {
+	db       *gorm.DB
+	cfg      WorkerConfig
+	handlers map[string]Handler
+}
+
+// NewWorker creates a new worker running the jobs in db
+func NewWorker(db *gorm.DB, cfg WorkerConfig) *Worker {
+	if len(cfg.Queues) == 0 {
+		cfg.Queues = []string{DefaultQueue}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(time.Hour, cfg.MinBackoff)
+	}
+	return &Worker{db: db, cfg: cfg, handlers: map[string]Handler{}}
+}
+
+// Handle registers the handler of the job type, replacing the existing one if any
+func (w *Worker) Handle(typ string, h Handler) {
+	w.handlers[typ] = h
+}
+
+// Run runs the jobs with WorkerConfig.Concurrency goroutines until the context is done,
+// then waits for the running jobs. Errors are reported to onError if given, then retried.
+func (w *Worker) Run(ctx context.Context, onError func(error)) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queueutil.Poll(ctx, w.cfg.PollInterval, onError, w.WorkOnce)
+		}()
+	}
+	wg.Wait()
+}
+
+// Drain runs the due jobs with WorkerConfig.Concurrency goroutines until there are none left or ctx is done,
+// e.g. when invoked on schedule by Lambda. Returns the errors of the database if any.
+func (w *Worker) Drain(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+	errs := make([]error, w.cfg.Concurrency)
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				ok, err := w.WorkOnce(ctx)
+				if err != nil || !ok {
+					errs[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// WorkOnce claims a due job and runs it, returns whether there was one.
+// The result of the handler is recorded into the job, only the errors of the database are returned.
+func (w *Worker) WorkOnce(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	job, err := w.claim(ctx)
+	if err != nil || job == nil {
+		return false, err
+	}
+	if job.Status == StatusDead {
+		return true, nil
+	}
+
+	runCtx, cancel := context.WithDeadline(ctx, job.RunAt)
+	err = w.run(runCtx, job)
+	cancel()
+	return true, w.record(queueutil.Detach(ctx), job, err)
+}
+
+// claim reserves the earliest due job of the queues for the lease, including the running jobs of crashed workers.
+// The attempt is counted when claimed, so a job crashing every worker running it is given up after the maximum attempts:
+// the job is returned as dead instead of running it again.
+func (w *Worker) claim(ctx context.Context) (*Job, error) {
+	jobs, err := queueutil.Claim(ctx, w.db, "", func(tx *gorm.DB, now time.Time) *gorm.DB {
+		return tx.Where("status IN ? AND queue IN ? AND run_at <= ?", []string{StatusPending, StatusRunning}, w.cfg.Queues, now).
+			Order("run_at").Order("id").Limit(1)
+	}, func(tx *gorm.DB, now time.Time, jobs []Job) error {
+		job := &jobs[0]
+		if job.Attempts >= job.MaxAttempts {
+			job.Status, job.FinishedAt, job.LastError = StatusDead, &now, errLeaseExpired
+			return tx.Model(&Job{}).Where("id = ?", job.ID).
+				Updates(map[string]any{"status": job.Status, "finished_at": job.FinishedAt, "last_error": job.LastError}).Error
+		}
+		// in milliseconds, so the lease is stored as is by all databases to be matched when recording
+		job.Status, job.RunAt, job.Attempts = StatusRunning, now.Truncate(time.Millisecond).Add(w.cfg.Lease), job.Attempts+1
+		return tx.Model(&Job{}).Where("id = ?", job.ID).
+			Updates(map[string]any{"status": job.Status, "run_at": job.RunAt, "attempts": job.Attempts}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jobutil: cannot claim jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// run runs the handler of the job, panics are returned as errors
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	h, ok := w.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler of job type %q", job.Type))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return h(ctx, job)
+}
+
+// record saves the result of the attempt, already counted by claim
+func (w *Worker) record(ctx context.Context, job *Job, runErr error) error {
+	now := time.Now().UTC()
+	attempts := job.Attempts
+	updates := map[string]any{}
+	switch {
+	case runErr == nil:
+		updates["status"] = StatusSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case IsPermanent(runErr) || attempts >= job.MaxAttempts:
+		updates["status"] = StatusDead
+		updates["finished_at"] = now
+		updates["last_error"] = runErr.Error()
+	default:
+		updates["status"] = StatusPending
+		updates["run_at"] = now.Add(queueutil.Backoff(w.cfg.MinBackoff, w.cfg.MaxBackoff, attempts))
+		updates["last_error"] = runErr.Error()
+	}
+	// the job may be claimed again by another worker if the lease has expired
+	res := w.db.WithContext(ctx).Model(&Job{}).Where("id = ? AND status = ? AND run_at = ?", job.ID, StatusRunning, job.RunAt).Updates(updates)
+	if res.Error != nil {
+		return fmt.Errorf("jobutil: cannot update job %s: %w", job.ID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("jobutil: job %s has been claimed again after the lease", job.ID)
+	}
+	return nil
+}
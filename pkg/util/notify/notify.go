@@ -0,0 +1,159 @@
+package notifyutil
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	snsutil "runar-himmel/pkg/util/sns"
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// Platforms of the devices
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// Errors of the device registry
+var (
+	ErrInvalidPlatform  = errors.New("notifyutil: invalid platform")
+	ErrPlatformDisabled = errors.New("notifyutil: the platform is not configured")
+	ErrDeviceNotFound   = errors.New("notifyutil: device not found")
+	ErrUnknownTopic     = errors.New("notifyutil: unknown topic")
+)
+
+// Client sends the notifications through SNS, implemented by snsutil.Service
+type Client interface {
+	RegisterDevice(appArn, deviceToken string) (string, error)
+	DeregisterDevice(endpointArn string) error
+	Subscribe(topicArn, endpointArn string) (string, error)
+	Unsubscribe(subscriptionArn string) error
+	SendToIOS(target string, payload snsutil.APNSPayload) (string, error)
+	SendToAndroid(target string, payload snsutil.FCMPayload) (string, error)
+	SendToTopic(topic string, msg snsutil.Message) (string, error)
+}
+
+// Device represents a device of a user receiving the push notifications, registered as an SNS endpoint.
+// A device token belongs to a single user, the last one registering it.
+// swagger:model Device
+type Device struct {
+	ID       string `json:"id" gorm:"primaryKey;type:varchar(26)"`
+	UserID   string `json:"user_id" gorm:"type:varchar(26);not null;index:idx_devices_user"`
+	Platform string `json:"platform" gorm:"type:varchar(10);not null;uniqueIndex:uix_devices_token,priority:1"`
+	// The APNS or FCM token of the device
+	Token       string `json:"token" gorm:"type:varchar(255);not null;uniqueIndex:uix_devices_token,priority:2"`
+	EndpointARN string `json:"-" gorm:"type:varchar(255)"`
+	// The subscriptions of the topics, see Service.Subscribe
+	Subscriptions Subscriptions `json:"-" gorm:"type:text"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// TableName returns the table of the devices
+func (Device) TableName() string {
+	return "devices"
+}
+
+// BeforeCreate hook executed by gorm
+func (d *Device) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = ulidutil.NewString()
+	}
+	return nil
+}
+
+// UserTopic represents a topic subscribed by a user, all devices of the user are subscribed to it
+type UserTopic struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(26)"`
+	Topic     string    `json:"topic" gorm:"primaryKey;type:varchar(64)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table of the user topics
+func (UserTopic) TableName() string {
+	return "user_topics"
+}
+
+// Subscriptions maps the topic names to the SNS subscription ARNs, stored as JSON
+type Subscriptions map[string]string
+
+// Scan implements the sql.Scanner interface
+func (s *Subscriptions) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("notifyutil: cannot scan %T into Subscriptions", value)
+	}
+	if len(b) == 0 {
+		*s = nil
+		return nil
+	}
+	return json.Unmarshal(b, s)
+}
+
+// Value implements the driver.Valuer interface
+func (s Subscriptions) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// Payload represents a push notification, sent to both APNS & FCM
+type Payload struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	// Custom data delivered to the app
+	Data  map[string]any `json:"data,omitempty"`
+	Badge *int           `json:"badge,omitempty"`
+	// The sound played when displayed, `default` if omitted
+	Sound string `json:"sound,omitempty"`
+}
+
+// APNS returns the APNS payload of the notification, silent if there is no title nor body
+func (p Payload) APNS() snsutil.APNSPayload {
+	apns := snsutil.APNSPayload{Data: p.Data}
+	if p.Title != "" || p.Body != "" {
+		apns.Notification = &snsutil.APNSNotification{
+			Alert: &snsutil.APNSAlert{Title: p.Title, Body: p.Body},
+			Sound: p.sound(),
+			Badge: p.Badge,
+		}
+	}
+	return apns
+}
+
+// FCM returns the FCM payload of the notification, data only if there is no title nor body
+func (p Payload) FCM() snsutil.FCMPayload {
+	fcm := snsutil.FCMPayload{Data: p.Data}
+	if p.Title != "" || p.Body != "" {
+		fcm.Notification = &snsutil.FCMNotification{Title: p.Title, Body: p.Body, Sound: p.sound(), Badge: p.Badge}
+	}
+	return fcm
+}
+
+// Message returns the message of the notification for all platforms, e.g. for the topics
+func (p Payload) Message() snsutil.Message {
+	apns, fcm := p.APNS(), p.FCM()
+	return snsutil.Message{APNS: &apns, APNSSandbox: &apns, FCM: &fcm}
+}
+
+func (p Payload) sound() string {
+	if p.Sound == "" {
+		return "default"
+	}
+	return p.Sound
+}
@@ -0,0 +1,327 @@
+package notifyutil_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	notifyutil "runar-himmel/pkg/util/notify"
+	snsutil "runar-himmel/pkg/util/sns"
+)
+
+// fakeSNS records the calls instead of calling SNS
+type fakeSNS struct {
+	mu        sync.Mutex
+	n         int
+	endpoints map[string]string // endpoint => token
+	subs      map[string]string // subscription => endpoint
+	sent      []string          // endpoints or topics
+	ios       []snsutil.APNSPayload
+	android   []snsutil.FCMPayload
+	// The endpoints reported as disabled
+	disabled map[string]bool
+	// Returned by the sends if not nil
+	err error
+}
+
+func newFakeSNS() *fakeSNS {
+	return &fakeSNS{endpoints: map[string]string{}, subs: map[string]string{}, disabled: map[string]bool{}}
+}
+
+func (f *fakeSNS) RegisterDevice(appArn, deviceToken string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n++
+	arn := fmt.Sprintf("%s/endpoint-%d", appArn, f.n)
+	f.endpoints[arn] = deviceToken
+	return arn, nil
+}
+
+func (f *fakeSNS) DeregisterDevice(endpointArn string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.endpoints, endpointArn)
+	return nil
+}
+
+func (f *fakeSNS) Subscribe(topicArn, endpointArn string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n++
+	arn := fmt.Sprintf("%s:sub-%d", topicArn, f.n)
+	f.subs[arn] = endpointArn
+	return arn, nil
+}
+
+func (f *fakeSNS) Unsubscribe(subscriptionArn string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subs, subscriptionArn)
+	return nil
+}
+
+func (f *fakeSNS) send(target string) (string, error) {
+	if f.disabled[target] {
+		return "", awserr.New(sns.ErrCodeEndpointDisabledException, "Endpoint is disabled", nil)
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	f.sent = append(f.sent, target)
+	return "msg", nil
+}
+
+func (f *fakeSNS) SendToIOS(target string, payload snsutil.APNSPayload) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ios = append(f.ios, payload)
+	return f.send(target)
+}
+
+func (f *fakeSNS) SendToAndroid(target string, payload snsutil.FCMPayload) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.android = append(f.android, payload)
+	return f.send(target)
+}
+
+func (f *fakeSNS) SendToTopic(topic string, msg snsutil.Message) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.send(topic)
+}
+
+// subscribed returns the endpoints subscribed to the topic
+func (f *fakeSNS) subscribed(topicArn string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	endpoints := []string{}
+	for sub, endpoint := range f.subs {
+		if len(sub) > len(topicArn) && sub[:len(topicArn)+1] == topicArn+":" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+const (
+	iosApp     = "arn:aws:sns:us-east-1:1:app/APNS/himmel"
+	androidApp = "arn:aws:sns:us-east-1:1:app/GCM/himmel"
+	newsTopic  = "arn:aws:sns:us-east-1:1:news"
+	promoTopic = "arn:aws:sns:us-east-1:1:promo"
+)
+
+func newTestService(t *testing.T) (*notifyutil.Service, *fakeSNS, *gorm.DB) {
+	db := dbtest.New(t, &notifyutil.Device{}, &notifyutil.UserTopic{})
+	client := newFakeSNS()
+	svc := notifyutil.New(db, client, notifyutil.Config{
+		IOSAppARN:     iosApp,
+		AndroidAppARN: androidApp,
+		Topics:        map[string]string{"news": newsTopic, "promo": promoTopic},
+	})
+	return svc, client, db
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("new device", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		d, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		assert.NotEmpty(t, d.ID)
+		assert.Equal(t, "u1", d.UserID)
+		assert.Equal(t, "tok-1", client.endpoints[d.EndpointARN])
+
+		// registered again
+		again, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, again.ID)
+		assert.Len(t, client.endpoints, 1)
+
+		devices, err := svc.Devices(ctx, "u1")
+		require.NoError(t, err)
+		assert.Len(t, devices, 1)
+	})
+
+	t.Run("invalid platform", func(t *testing.T) {
+		svc, _, _ := newTestService(t)
+		_, err := svc.Register(ctx, "u1", "windows", "tok-1")
+		assert.ErrorIs(t, err, notifyutil.ErrInvalidPlatform)
+
+		svc = notifyutil.New(dbtest.New(t, &notifyutil.Device{}, &notifyutil.UserTopic{}), newFakeSNS(), notifyutil.Config{IOSAppARN: iosApp})
+		_, err = svc.Register(ctx, "u1", notifyutil.PlatformAndroid, "tok-1")
+		assert.ErrorIs(t, err, notifyutil.ErrPlatformDisabled)
+	})
+
+	t.Run("subscribed to user topics", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+		d, err := svc.Register(ctx, "u1", notifyutil.PlatformAndroid, "tok-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{d.EndpointARN}, client.subscribed(newsTopic))
+		assert.Empty(t, client.subscribed(promoTopic))
+	})
+
+	t.Run("moved to another user", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+		require.NoError(t, svc.Subscribe(ctx, "u2", "promo"))
+		d1, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+
+		d2, err := svc.Register(ctx, "u2", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		assert.Equal(t, d1.ID, d2.ID)
+		assert.Equal(t, d1.EndpointARN, d2.EndpointARN)
+		assert.Empty(t, client.subscribed(newsTopic))
+		assert.Equal(t, []string{d2.EndpointARN}, client.subscribed(promoTopic))
+
+		devices, err := svc.Devices(ctx, "u1")
+		require.NoError(t, err)
+		assert.Empty(t, devices)
+		devices, err = svc.Devices(ctx, "u2")
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Len(t, devices[0].Subscriptions, 1)
+	})
+}
+
+func TestUnregister(t *testing.T) {
+	ctx := context.Background()
+	svc, client, _ := newTestService(t)
+	require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+	d, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.Unregister(ctx, "u2", d.ID), notifyutil.ErrDeviceNotFound)
+	require.NoError(t, svc.Unregister(ctx, "u1", d.ID))
+	assert.Empty(t, client.endpoints)
+	assert.Empty(t, client.subs)
+	assert.ErrorIs(t, svc.Unregister(ctx, "u1", d.ID), notifyutil.ErrDeviceNotFound)
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	svc, client, db := newTestService(t)
+	ios, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+	require.NoError(t, err)
+	android, err := svc.Register(ctx, "u1", notifyutil.PlatformAndroid, "tok-2")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.Subscribe(ctx, "u1", "unknown"), notifyutil.ErrUnknownTopic)
+	assert.ErrorIs(t, svc.Unsubscribe(ctx, "u1", "unknown"), notifyutil.ErrUnknownTopic)
+
+	require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+	// subscribed once
+	require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+	assert.ElementsMatch(t, []string{ios.EndpointARN, android.EndpointARN}, client.subscribed(newsTopic))
+	topics, err := svc.Topics(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"news"}, topics)
+	assert.Equal(t, []string{"news", "promo"}, svc.AvailableTopics())
+
+	stored := &notifyutil.Device{}
+	require.NoError(t, db.Take(stored, "id = ?", ios.ID).Error)
+	assert.Contains(t, stored.Subscriptions, "news")
+
+	require.NoError(t, svc.Unsubscribe(ctx, "u1", "news"))
+	assert.Empty(t, client.subscribed(newsTopic))
+	topics, err = svc.Topics(ctx, "u1")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+	require.NoError(t, db.Take(stored, "id = ?", ios.ID).Error)
+	assert.Empty(t, stored.Subscriptions)
+
+	require.NoError(t, svc.SendToTopic(ctx, "promo", notifyutil.Payload{Title: "Sale"}))
+	assert.Equal(t, []string{promoTopic}, client.sent)
+	assert.ErrorIs(t, svc.SendToTopic(ctx, "unknown", notifyutil.Payload{}), notifyutil.ErrUnknownTopic)
+}
+
+func TestSendToUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fan out", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		ios, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		android, err := svc.Register(ctx, "u1", notifyutil.PlatformAndroid, "tok-2")
+		require.NoError(t, err)
+		_, err = svc.Register(ctx, "u2", notifyutil.PlatformIOS, "tok-3")
+		require.NoError(t, err)
+
+		badge := 3
+		n, err := svc.SendToUser(ctx, "u1", notifyutil.Payload{Title: "Hi", Body: "Odin", Badge: &badge, Data: map[string]any{"k": "v"}})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{ios.EndpointARN, android.EndpointARN}, client.sent)
+
+		require.Len(t, client.ios, 1)
+		assert.Equal(t, "Hi", client.ios[0].Notification.Alert.Title)
+		assert.Equal(t, "default", client.ios[0].Notification.Sound)
+		assert.Equal(t, &badge, client.ios[0].Notification.Badge)
+		require.Len(t, client.android, 1)
+		assert.Equal(t, "Odin", client.android[0].Notification.Body)
+		assert.Equal(t, map[string]any{"k": "v"}, client.android[0].Data)
+
+		n, err = svc.SendToUser(ctx, "u3", notifyutil.Payload{Title: "Hi"})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		_, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		_, err = svc.SendToUser(ctx, "u1", notifyutil.Payload{Data: map[string]any{"sync": true}})
+		require.NoError(t, err)
+		require.Len(t, client.ios, 1)
+		assert.Nil(t, client.ios[0].Notification)
+	})
+
+	t.Run("disabled endpoint pruned", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		require.NoError(t, svc.Subscribe(ctx, "u1", "news"))
+		ios, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		android, err := svc.Register(ctx, "u1", notifyutil.PlatformAndroid, "tok-2")
+		require.NoError(t, err)
+		client.disabled[ios.EndpointARN] = true
+
+		n, err := svc.SendToUser(ctx, "u1", notifyutil.Payload{Title: "Hi"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{android.EndpointARN}, client.sent)
+
+		devices, err := svc.Devices(ctx, "u1")
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, android.ID, devices[0].ID)
+		assert.NotContains(t, client.endpoints, ios.EndpointARN)
+		assert.Equal(t, []string{android.EndpointARN}, client.subscribed(newsTopic))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		svc, client, _ := newTestService(t)
+		_, err := svc.Register(ctx, "u1", notifyutil.PlatformIOS, "tok-1")
+		require.NoError(t, err)
+		client.err = errors.New("throttled")
+
+		n, err := svc.SendToUser(ctx, "u1", notifyutil.Payload{Title: "Hi"})
+		assert.ErrorIs(t, err, client.err)
+		assert.Zero(t, n)
+		// not pruned
+		devices, err := svc.Devices(ctx, "u1")
+		require.NoError(t, err)
+		assert.Len(t, devices, 1)
+	})
+}
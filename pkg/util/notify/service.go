@@ -0,0 +1,284 @@
+package notifyutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	repoutil "runar-himmel/pkg/util/repo"
+	snsutil "runar-himmel/pkg/util/sns"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Config holds the configurations of the notification service
+type Config struct {
+	// The SNS platform applications of the devices, the platform is disabled if empty
+	IOSAppARN     string
+	AndroidAppARN string
+	// The topics the users may subscribe to, mapping the names to the SNS topic ARNs
+	Topics map[string]string
+}
+
+// Service manages the devices of the users and sends them the push notifications
+type Service struct {
+	db     *gorm.DB
+	client Client
+	cfg    Config
+}
+
+// New creates a new notification service storing the devices in db
+func New(db *gorm.DB, client Client, cfg Config) *Service {
+	return &Service{db: db, client: client, cfg: cfg}
+}
+
+// Register registers the device of the user, or returns it if already registered.
+// The device is moved to the user if it was registered by another one, e.g. after switching the accounts.
+// The device is subscribed to the topics of the user.
+func (s *Service) Register(ctx context.Context, userID, platform, token string) (*Device, error) {
+	appARN, err := s.appARN(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Device{}
+	err = s.conn(ctx).Take(d, "platform = ? AND token = ?", platform, token).Error
+	switch {
+	case err == nil && d.UserID == userID:
+		return d, nil
+	case err == nil:
+		// the topics of the previous user
+		if err := s.unsubscribeAll(d); err != nil {
+			return nil, err
+		}
+		d.UserID = userID
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		endpointARN, err := s.client.RegisterDevice(appARN, token)
+		if err != nil {
+			return nil, fmt.Errorf("notifyutil: cannot register the device: %w", err)
+		}
+		d = &Device{UserID: userID, Platform: platform, Token: token, EndpointARN: endpointARN}
+	default:
+		return nil, err
+	}
+
+	topics, err := s.Topics(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.subscribe(d, topics...); err != nil {
+		return nil, err
+	}
+	if err := s.conn(ctx).Save(d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Unregister deregisters a device of the user
+func (s *Service) Unregister(ctx context.Context, userID, id string) error {
+	d := &Device{}
+	if err := s.conn(ctx).Take(d, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+	return s.remove(ctx, d)
+}
+
+// Devices returns the devices of the user, the oldest first
+func (s *Service) Devices(ctx context.Context, userID string) ([]Device, error) {
+	devices := []Device{}
+	if err := s.conn(ctx).Where("user_id = ?", userID).Order("created_at").Order("id").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// SendToUser sends the notification to all devices of the user, returns the number of devices it is sent to.
+// The devices whose endpoint is disabled, e.g. the app was uninstalled, are unregistered.
+func (s *Service) SendToUser(ctx context.Context, userID string, p Payload) (int, error) {
+	devices, err := s.Devices(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	errs := []error{}
+	for i := range devices {
+		d := &devices[i]
+		var err error
+		switch d.Platform {
+		case PlatformIOS:
+			_, err = s.client.SendToIOS(d.EndpointARN, p.APNS())
+		case PlatformAndroid:
+			_, err = s.client.SendToAndroid(d.EndpointARN, p.FCM())
+		default:
+			err = ErrInvalidPlatform
+		}
+		switch {
+		case err == nil:
+			sent++
+		case snsutil.IsEndpointDisabled(err):
+			if err := s.remove(ctx, d); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("notifyutil: cannot send to device %s: %w", d.ID, err))
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Topics returns the names of the topics subscribed by the user
+func (s *Service) Topics(ctx context.Context, userID string) ([]string, error) {
+	topics := []string{}
+	if err := s.conn(ctx).Model(&UserTopic{}).Where("user_id = ?", userID).Order("topic").Pluck("topic", &topics).Error; err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// AvailableTopics returns the names of the topics the users may subscribe to
+func (s *Service) AvailableTopics() []string {
+	topics := lo.Keys(s.cfg.Topics)
+	slices.Sort(topics)
+	return topics
+}
+
+// Subscribe subscribes the user to the topic, including all devices of the user
+func (s *Service) Subscribe(ctx context.Context, userID, topic string) error {
+	if _, ok := s.cfg.Topics[topic]; !ok {
+		return ErrUnknownTopic
+	}
+	if err := s.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&UserTopic{UserID: userID, Topic: topic}).Error; err != nil {
+		return err
+	}
+	return s.eachDevice(ctx, userID, func(d *Device) error {
+		return s.subscribe(d, topic)
+	})
+}
+
+// Unsubscribe unsubscribes the user from the topic, including all devices of the user
+func (s *Service) Unsubscribe(ctx context.Context, userID, topic string) error {
+	if _, ok := s.cfg.Topics[topic]; !ok {
+		return ErrUnknownTopic
+	}
+	if err := s.conn(ctx).Delete(&UserTopic{}, "user_id = ? AND topic = ?", userID, topic).Error; err != nil {
+		return err
+	}
+	return s.eachDevice(ctx, userID, func(d *Device) error {
+		return s.unsubscribe(d, topic)
+	})
+}
+
+// SendToTopic sends the notification to all devices subscribed to the topic
+func (s *Service) SendToTopic(ctx context.Context, topic string, p Payload) error {
+	arn, ok := s.cfg.Topics[topic]
+	if !ok {
+		return ErrUnknownTopic
+	}
+	if _, err := s.client.SendToTopic(arn, p.Message()); err != nil {
+		return fmt.Errorf("notifyutil: cannot send to topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// conn returns the db session, in the transaction carried by ctx if any
+func (s *Service) conn(ctx context.Context) *gorm.DB {
+	return repoutil.Conn(ctx, s.db)
+}
+
+func (s *Service) appARN(platform string) (string, error) {
+	var arn string
+	switch platform {
+	case PlatformIOS:
+		arn = s.cfg.IOSAppARN
+	case PlatformAndroid:
+		arn = s.cfg.AndroidAppARN
+	default:
+		return "", ErrInvalidPlatform
+	}
+	if arn == "" {
+		return "", ErrPlatformDisabled
+	}
+	return arn, nil
+}
+
+// eachDevice runs fn with the devices of the user, then saves their subscriptions.
+// The subscriptions made before an error are saved as well.
+func (s *Service) eachDevice(ctx context.Context, userID string, fn func(d *Device) error) error {
+	devices, err := s.Devices(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range devices {
+		d := &devices[i]
+		err := fn(d)
+		if err := s.conn(ctx).Model(d).Update("subscriptions", d.Subscriptions).Error; err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribe subscribes the device to the topics it is not subscribed to yet, unknown topics are skipped
+func (s *Service) subscribe(d *Device, topics ...string) error {
+	for _, topic := range topics {
+		arn, ok := s.cfg.Topics[topic]
+		if _, subscribed := d.Subscriptions[topic]; !ok || subscribed {
+			continue
+		}
+		subARN, err := s.client.Subscribe(arn, d.EndpointARN)
+		if err != nil {
+			return fmt.Errorf("notifyutil: cannot subscribe device %s to topic %s: %w", d.ID, topic, err)
+		}
+		if d.Subscriptions == nil {
+			d.Subscriptions = Subscriptions{}
+		}
+		d.Subscriptions[topic] = subARN
+	}
+	return nil
+}
+
+// unsubscribe unsubscribes the device from the topic if subscribed
+func (s *Service) unsubscribe(d *Device, topic string) error {
+	subARN, ok := d.Subscriptions[topic]
+	if !ok {
+		return nil
+	}
+	if err := s.client.Unsubscribe(subARN); err != nil {
+		return fmt.Errorf("notifyutil: cannot unsubscribe device %s from topic %s: %w", d.ID, topic, err)
+	}
+	delete(d.Subscriptions, topic)
+	return nil
+}
+
+func (s *Service) unsubscribeAll(d *Device) error {
+	for topic := range d.Subscriptions {
+		if err := s.unsubscribe(d, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes the device, then deregisters its endpoint & subscriptions.
+// The device is deleted anyway, the SNS resources left are harmless.
+func (s *Service) remove(ctx context.Context, d *Device) error {
+	if err := s.conn(ctx).Delete(&Device{}, "id = ?", d.ID).Error; err != nil {
+		return err
+	}
+	err := s.unsubscribeAll(d)
+	if e := s.client.DeregisterDevice(d.EndpointARN); e != nil {
+		err = errors.Join(err, fmt.Errorf("notifyutil: cannot deregister device %s: %w", d.ID, e))
+	}
+	return err
+}
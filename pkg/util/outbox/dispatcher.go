@@ -0,0 +1,153 @@
+package outboxutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	queueutil "runar-himmel/pkg/util/queue"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// DispatcherConfig holds the dispatcher configurations, zero values are replaced by the defaults
+type DispatcherConfig struct {
+	// Maximum number of events claimed per batch, default 100
+	BatchSize int
+	// How often the outbox is polled when idle, default 1 second
+	PollInterval time.Duration
+	// Number of attempts before an event is given up as failed, default 10
+	MaxAttempts int
+	// The delay before the first retry, doubled for every further attempt up to MaxBackoff. Default 1 second & 1 hour.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// How long the claimed events are reserved for the dispatcher, default 1 minute.
+	// Events of a crashed dispatcher are delivered again afterward, so it must be longer than delivering a batch.
+	Lease time.Duration
+}
+
+// Dispatcher delivers the pending events of the outbox to the sinks, at-least-once.
+// Failed deliveries are retried with exponential backoff, only to the sinks which have not received the event.
+// The events are claimed in the order of emission, but retries & concurrent dispatchers deliver them out of order,
+// so the sinks must not rely on the order, e.g. compare OccurredAt of the messages having the same Key.
+// Multiple dispatchers may run concurrently, the events are reserved for DispatcherConfig.Lease, see queueutil.Claim.
+type Dispatcher struct {
+	db    *gorm.DB
+	cfg   DispatcherConfig
+	sinks []Sink
+}
+
+// NewDispatcher creates a new dispatcher delivering the events of the outbox in db to the sinks
+func NewDispatcher(db *gorm.DB, cfg DispatcherConfig, sinks ...Sink) *Dispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(time.Hour, cfg.MinBackoff)
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	return &Dispatcher{db: db, cfg: cfg, sinks: sinks}
+}
+
+// Run dispatches the events until the context is done. Errors are reported to onError if given, then retried.
+func (d *Dispatcher) Run(ctx context.Context, onError func(error)) {
+	queueutil.Poll(ctx, d.cfg.PollInterval, onError, func(ctx context.Context) (bool, error) {
+		n, err := d.DispatchOnce(ctx)
+		return n >= d.cfg.BatchSize, err
+	})
+}
+
+// DispatchOnce claims a batch of the due events and delivers them, returns the number of claimed events.
+// Delivery failures are recorded into the events for retrying, only the errors of the outbox itself are returned.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	events, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range events {
+		// the rest are delivered again after the lease
+		if err := ctx.Err(); err != nil {
+			return len(events), err
+		}
+		if err := d.deliver(ctx, &events[i]); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// claim reads the due events and reserves them for the lease, so other dispatchers skip them
+func (d *Dispatcher) claim(ctx context.Context) ([]Event, error) {
+	events, err := queueutil.Claim(ctx, d.db, "", func(tx *gorm.DB, now time.Time) *gorm.DB {
+		return tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).Order("id").Limit(d.cfg.BatchSize)
+	}, func(tx *gorm.DB, now time.Time, events []Event) error {
+		ids := make([]string, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return tx.Model(&Event{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(d.cfg.Lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outboxutil: cannot claim events: %w", err)
+	}
+	return events, nil
+}
+
+// deliver delivers the event to the sinks which have not received it yet, then records the result
+func (d *Dispatcher) deliver(ctx context.Context, e *Event) error {
+	delivered := splitNames(e.DeliveredTo)
+	errs := []error{}
+	for _, s := range d.sinks {
+		if lo.Contains(delivered, s.Name()) {
+			continue
+		}
+		if err := s.Deliver(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		delivered = append(delivered, s.Name())
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]any{
+		"attempts":     e.Attempts + 1,
+		"delivered_to": strings.Join(delivered, ","),
+	}
+	if err := errors.Join(errs...); err != nil {
+		updates["last_error"] = err.Error()
+		if e.Attempts+1 >= d.cfg.MaxAttempts {
+			updates["status"] = StatusFailed
+		} else {
+			updates["next_attempt_at"] = now.Add(queueutil.Backoff(d.cfg.MinBackoff, d.cfg.MaxBackoff, e.Attempts+1))
+		}
+	} else {
+		updates["status"] = StatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	}
+	if err := d.db.WithContext(queueutil.Detach(ctx)).Model(&Event{}).Where("id = ?", e.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("outboxutil: cannot update event %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+func splitNames(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,143 @@
+package outboxutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dbutil "runar-himmel/pkg/util/db"
+	repoutil "runar-himmel/pkg/util/repo"
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of the events
+const (
+	// Waiting to be delivered, or retried after NextAttemptAt
+	StatusPending = "pending"
+	// Delivered to all sinks
+	StatusDelivered = "delivered"
+	// Given up after the maximum attempts, see DispatcherConfig.MaxAttempts
+	StatusFailed = "failed"
+)
+
+// Event represents a domain event waiting in the outbox to be delivered to the sinks
+type Event struct {
+	ID string `json:"id" gorm:"primaryKey;type:varchar(26)"`
+	// The event name, e.g. `user.registered`
+	Type string `json:"type" gorm:"type:varchar(64)"`
+	// ID of the entity the event is about, e.g. the user ID
+	Key     string  `json:"key,omitempty" gorm:"type:varchar(64)"`
+	Payload Payload `json:"payload" gorm:"type:text"`
+	// The request emitting the event, see dbutil.WithRequestID
+	RequestID string    `json:"request_id,omitempty" gorm:"type:varchar(64)"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Delivery state, maintained by Dispatcher
+	Status        string     `json:"status" gorm:"type:varchar(10);not null;default:pending;index:idx_outbox_pending,priority:1"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_outbox_pending,priority:2"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:text"`
+	DeliveredTo   string     `json:"delivered_to,omitempty" gorm:"type:varchar(255)"` // names of the sinks delivered so far, separated by comma
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// TableName returns the table of the outbox
+func (Event) TableName() string {
+	return "outbox"
+}
+
+// BeforeCreate hook executed by gorm
+func (e *Event) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = ulidutil.NewString()
+	}
+	if e.Status == "" {
+		e.Status = StatusPending
+	}
+	if e.NextAttemptAt.IsZero() {
+		e.NextAttemptAt = time.Now().UTC()
+	}
+	return nil
+}
+
+// Message is the event as delivered to the sinks, without the delivery state
+type Message struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Key        string          `json:"key,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	RequestID  string          `json:"request_id,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// Message returns the message of the event to be delivered
+func (e *Event) Message() Message {
+	return Message{
+		ID:         e.ID,
+		Type:       e.Type,
+		Key:        e.Key,
+		Payload:    json.RawMessage(e.Payload),
+		RequestID:  e.RequestID,
+		OccurredAt: e.CreatedAt,
+	}
+}
+
+// Emit writes an event into the outbox, in the transaction carried by ctx if any, see repoutil.WithTx.
+// Emitting in the same transaction as the changes makes sure the event is delivered if and only if they are committed.
+// The payload is encoded as JSON.
+func Emit(ctx context.Context, db *gorm.DB, typ, key string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outboxutil: cannot encode the payload of %s: %w", typ, err)
+	}
+	return repoutil.Conn(ctx, db).Create(&Event{
+		Type:      typ,
+		Key:       key,
+		Payload:   b,
+		RequestID: dbutil.RequestIDFromContext(ctx),
+	}).Error
+}
+
+// Payload holds the JSON encoded payload of the event, stored as text
+type Payload []byte
+
+// MarshalJSON returns the payload as is, null if empty
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+// UnmarshalJSON keeps a copy of the JSON data
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// Scan implements the sql.Scanner interface
+func (p *Payload) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*p = nil
+	case []byte:
+		*p = append(Payload{}, value...)
+	case string:
+		*p = Payload(value)
+	default:
+		return fmt.Errorf("outboxutil: cannot scan %T into Payload", value)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (p Payload) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return string(p), nil
+}
@@ -0,0 +1,237 @@
+package outboxutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dbutil "runar-himmel/pkg/util/db"
+	"runar-himmel/pkg/util/db/dbtest"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type parcel struct {
+	ID string `gorm:"primaryKey"`
+}
+
+type memSink struct {
+	name string
+	mu   sync.Mutex
+	err  error
+	got  []string
+}
+
+func (s *memSink) Name() string { return s.name }
+
+func (s *memSink) Deliver(_ context.Context, e *outboxutil.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.got = append(s.got, e.Type)
+	return nil
+}
+
+func TestEmit(t *testing.T) {
+	db := dbtest.New(t, &parcel{}, &outboxutil.Event{})
+	ctx := dbutil.WithRequestID(context.Background(), "req-1")
+
+	require.NoError(t, outboxutil.Emit(ctx, db, "parcel.sent", "1", map[string]any{"to": "Asgard"}))
+	events := dbtest.Find[outboxutil.Event](t, db)
+	require.Len(t, events, 1)
+	assert.Equal(t, "parcel.sent", events[0].Type)
+	assert.Equal(t, "1", events[0].Key)
+	assert.JSONEq(t, `{"to":"Asgard"}`, string(events[0].Payload))
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, outboxutil.StatusPending, events[0].Status)
+
+	// the events are rolled back along with the changes of the transaction carried by ctx
+	errRollback := errors.New("rollback")
+	err := repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		if err := repoutil.NewRepo[parcel](db).Create(ctx, &parcel{ID: "2"}); err != nil {
+			return err
+		}
+		if err := outboxutil.Emit(ctx, db, "parcel.sent", "2", nil); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	assert.Len(t, dbtest.Find[outboxutil.Event](t, db), 1)
+
+	require.NoError(t, repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		return outboxutil.Emit(ctx, db, "parcel.sent", "3", nil)
+	}))
+	assert.Len(t, dbtest.Find[outboxutil.Event](t, db), 2)
+
+	// payloads which cannot be encoded
+	assert.Error(t, outboxutil.Emit(ctx, db, "parcel.sent", "4", func() {}))
+}
+
+func TestDispatcher(t *testing.T) {
+	db := dbtest.New(t, &parcel{}, &outboxutil.Event{})
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, outboxutil.Emit(ctx, db, fmt.Sprintf("parcel.%d", i), "", i))
+	}
+
+	ok, flaky := &memSink{name: "ok"}, &memSink{name: "flaky", err: errors.New("down")}
+	d := outboxutil.NewDispatcher(db, outboxutil.DispatcherConfig{BatchSize: 2, MaxAttempts: 3}, ok, flaky)
+
+	// in the order of emission, by batches
+	n, err := d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"parcel.1", "parcel.2", "parcel.3"}, ok.got)
+
+	// failed deliveries are retried after the backoff
+	events := dbtest.Find[outboxutil.Event](t, db)
+	assert.Equal(t, outboxutil.StatusPending, events[0].Status)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.Equal(t, "ok", events[0].DeliveredTo)
+	assert.Equal(t, "flaky: down", events[0].LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Second), events[0].NextAttemptAt, 500*time.Millisecond)
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// only to the sinks which have not received them
+	dbtest.MakeDue(t, db, &outboxutil.Event{}, "next_attempt_at", "status = ?", outboxutil.StatusPending)
+	flaky.err = nil
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, ok.got, 3)
+	assert.Equal(t, []string{"parcel.1", "parcel.2"}, flaky.got)
+	events = dbtest.Find[outboxutil.Event](t, db)
+	assert.Equal(t, outboxutil.StatusDelivered, events[0].Status)
+	assert.Equal(t, 2, events[0].Attempts)
+	assert.Equal(t, "ok,flaky", events[0].DeliveredTo)
+	assert.Empty(t, events[0].LastError)
+	assert.NotNil(t, events[0].DeliveredAt)
+
+	// given up after the maximum attempts
+	flaky.err = errors.New("down again")
+	dbtest.MakeDue(t, db, &outboxutil.Event{}, "next_attempt_at", "status = ?", outboxutil.StatusPending)
+	_, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	events = dbtest.Find[outboxutil.Event](t, db)
+	assert.Equal(t, outboxutil.StatusPending, events[2].Status)
+	dbtest.MakeDue(t, db, &outboxutil.Event{}, "next_attempt_at", "status = ?", outboxutil.StatusPending)
+	_, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	events = dbtest.Find[outboxutil.Event](t, db)
+	assert.Equal(t, outboxutil.StatusFailed, events[2].Status)
+	assert.Equal(t, 3, events[2].Attempts)
+	assert.Equal(t, "flaky: down again", events[2].LastError)
+	dbtest.MakeDue(t, db, &outboxutil.Event{}, "next_attempt_at", "status = ?", outboxutil.StatusPending)
+	n, err = d.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestDispatcherRun(t *testing.T) {
+	db := dbtest.New(t, &parcel{}, &outboxutil.Event{})
+	sink := &memSink{name: "mem"}
+	d := outboxutil.NewDispatcher(db, outboxutil.DispatcherConfig{PollInterval: 10 * time.Millisecond}, sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	require.NoError(t, outboxutil.Emit(context.Background(), db, "parcel.sent", "1", nil))
+	assert.Eventually(t, func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return len(sink.got) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher did not stop")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		status = http.StatusNoContent
+		header http.Header
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	db := dbtest.New(t, &parcel{}, &outboxutil.Event{})
+	require.NoError(t, outboxutil.Emit(context.Background(), db, "parcel.sent", "1", map[string]string{"to": "Midgard"}))
+	e := &dbtest.Find[outboxutil.Event](t, db)[0]
+
+	sink := outboxutil.NewWebhookSink(outboxutil.WebhookConfig{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer x"}}})
+	require.NoError(t, sink.Deliver(context.Background(), e))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer x", header.Get("Authorization"))
+	assert.Equal(t, e.ID, header.Get(outboxutil.HeaderEventID))
+	assert.Equal(t, "parcel.sent", header.Get(outboxutil.HeaderEventType))
+	msg := outboxutil.Message{}
+	require.NoError(t, json.Unmarshal(body, &msg))
+	assert.Equal(t, e.ID, msg.ID)
+	assert.Equal(t, "1", msg.Key)
+	assert.JSONEq(t, `{"to":"Midgard"}`, string(msg.Payload))
+
+	status = http.StatusBadGateway
+	assert.EqualError(t, sink.Deliver(context.Background(), e), "webhook responded 502 Bad Gateway")
+}
+
+type fakePublisher struct {
+	topic, message string
+	attributes     map[string]string
+}
+
+func (p *fakePublisher) Publish(topic, message string, attributes map[string]string) (string, error) {
+	p.topic, p.message, p.attributes = topic, message, attributes
+	return "msg-1", nil
+}
+
+type lines []string
+
+func (l *lines) Printf(format string, args ...any) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+func TestSNSAndLogSinks(t *testing.T) {
+	e := &outboxutil.Event{ID: "01", Type: "parcel.sent", Payload: outboxutil.Payload(`{"to":"Vanaheim"}`)}
+
+	p := &fakePublisher{}
+	require.NoError(t, outboxutil.NewSNSSink(p, "arn:topic").Deliver(context.Background(), e))
+	assert.Equal(t, "arn:topic", p.topic)
+	assert.Equal(t, map[string]string{"event_type": "parcel.sent"}, p.attributes)
+	assert.Contains(t, p.message, `"payload":{"to":"Vanaheim"}`)
+
+	l := &lines{}
+	require.NoError(t, outboxutil.NewLogSink(l).Deliver(context.Background(), e))
+	require.Len(t, *l, 1)
+	assert.Contains(t, (*l)[0], `outbox: parcel.sent {"id":"01"`)
+}
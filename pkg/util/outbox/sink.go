@@ -0,0 +1,143 @@
+package outboxutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Sink delivers the events to another system. Deliveries are at-least-once, so the sinks may receive duplicates,
+// which the receivers should drop by the event ID.
+type Sink interface {
+	// Name identifies the sink in the delivery state of the events, it must be unique & stable
+	Name() string
+	// Deliver delivers the event, the event is retried later if it fails
+	Deliver(ctx context.Context, e *Event) error
+}
+
+// Writer writes the log lines, e.g. log.Logger
+type Writer interface {
+	Printf(format string, args ...any)
+}
+
+// NewLogSink returns a sink writing the events to w, for development & debugging
+func NewLogSink(w Writer) Sink {
+	return &logSink{w: w}
+}
+
+type logSink struct {
+	w Writer
+}
+
+func (s *logSink) Name() string {
+	return "log"
+}
+
+func (s *logSink) Deliver(_ context.Context, e *Event) error {
+	b, err := json.Marshal(e.Message())
+	if err != nil {
+		return err
+	}
+	s.w.Printf("outbox: %s %s", e.Type, b)
+	return nil
+}
+
+// Headers of the webhook requests
+const (
+	HeaderEventID   = "X-Event-ID"
+	HeaderEventType = "X-Event-Type"
+)
+
+// WebhookConfig holds the webhook sink configurations
+type WebhookConfig struct {
+	URL string
+	// Extra headers of the requests, e.g. for authorization
+	Header http.Header
+	// The timeout of each request, 10 seconds if zero
+	Timeout time.Duration
+	// The client sending the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// NewWebhookSink returns a sink posting the events as JSON to the URL, see Message.
+// Responses other than 2xx are failures.
+func NewWebhookSink(cfg WebhookConfig) Sink {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &webhookSink{cfg: cfg}
+}
+
+type webhookSink struct {
+	cfg WebhookConfig
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Deliver(ctx context.Context, e *Event) error {
+	b, err := json.Marshal(e.Message())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, e.ID)
+	req.Header.Set(HeaderEventType, e.Type)
+
+	res, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
+
+// Publisher publishes messages to SNS topics, e.g. snsutil.Service
+type Publisher interface {
+	Publish(topic, message string, attributes map[string]string) (string, error)
+}
+
+// NewSNSSink returns a sink publishing the events as JSON to the SNS topic, see Message.
+// The event type is set as the `event_type` message attribute for subscription filter policies.
+func NewSNSSink(p Publisher, topic string) Sink {
+	return &snsSink{p: p, topic: topic}
+}
+
+type snsSink struct {
+	p     Publisher
+	topic string
+}
+
+func (s *snsSink) Name() string {
+	return "sns"
+}
+
+func (s *snsSink) Deliver(_ context.Context, e *Event) error {
+	b, err := json.Marshal(e.Message())
+	if err != nil {
+		return err
+	}
+	_, err = s.p.Publish(s.topic, string(b), map[string]string{"event_type": e.Type})
+	return err
+}
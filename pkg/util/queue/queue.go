@@ -0,0 +1,68 @@
+package queueutil
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Backoff returns the delay before retrying after the given number of failed attempts,
+// minDelay doubled for every further attempt up to maxDelay
+func Backoff(minDelay, maxDelay time.Duration, attempts int) time.Duration {
+	backoff := minDelay
+	for i := 1; i < attempts && backoff < maxDelay; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxDelay)
+}
+
+// Claim reads the due records using find, then reserves them using reserve in the same transaction,
+// so multiple consumers may poll the same table concurrently.
+// The rows are locked using `SELECT ... FOR UPDATE SKIP LOCKED` on MySQL & PostgreSQL, only the rows of lockTable
+// if given, so the consumers skip the rows claimed by each other. SQLite locks the whole database instead.
+// Both functions are given the same current time, e.g. reserve may push the due time of the records to now + lease.
+func Claim[T any](ctx context.Context, db *gorm.DB, lockTable string, find func(tx *gorm.DB, now time.Time) *gorm.DB, reserve func(tx *gorm.DB, now time.Time, recs []T) error) ([]T, error) {
+	recs := []T{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		q := find(tx, now)
+		if tx.Dialector.Name() != "sqlite" {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: lockTable}, Options: "SKIP LOCKED"})
+		}
+		if err := q.Find(&recs).Error; err != nil || len(recs) == 0 {
+			return err
+		}
+		return reserve(tx, now, recs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+// Poll calls once until ctx is done, waiting for the interval in between unless once reports there is more to do,
+// e.g. a full batch was claimed. Errors are reported to onError if given, then retried after the interval.
+func Poll(ctx context.Context, interval time.Duration, onError func(error), once func(ctx context.Context) (bool, error)) {
+	for {
+		more, err := once(ctx)
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		if err == nil && more {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Detach returns a copy of ctx which is not canceled along with it, for recording the results of the work done.
+// Otherwise, shutting down between doing & recording the work would do it again, e.g. sending the same request twice.
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
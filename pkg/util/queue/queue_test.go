@@ -0,0 +1,91 @@
+package queueutil_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	queueutil "runar-himmel/pkg/util/queue"
+)
+
+type task struct {
+	ID    string `gorm:"primaryKey"`
+	DueAt time.Time
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, queueutil.Backoff(time.Second, 10*time.Second, 1))
+	assert.Equal(t, 2*time.Second, queueutil.Backoff(time.Second, 10*time.Second, 2))
+	assert.Equal(t, 8*time.Second, queueutil.Backoff(time.Second, 10*time.Second, 4))
+	assert.Equal(t, 10*time.Second, queueutil.Backoff(time.Second, 10*time.Second, 5))
+	assert.Equal(t, 10*time.Second, queueutil.Backoff(time.Second, 10*time.Second, 100))
+	assert.Equal(t, 6*time.Hour, queueutil.Backoff(30*time.Second, 6*time.Hour, 20))
+}
+
+func TestClaim(t *testing.T) {
+	db := dbtest.New(t, &task{})
+	past := time.Now().UTC().Add(-time.Minute)
+	require.NoError(t, db.Create([]task{{ID: "1", DueAt: past}, {ID: "2", DueAt: past}, {ID: "3", DueAt: past.Add(time.Hour)}}).Error)
+
+	claim := func() ([]task, error) {
+		return queueutil.Claim(context.Background(), db, "", func(tx *gorm.DB, now time.Time) *gorm.DB {
+			return tx.Where("due_at <= ?", now).Order("id")
+		}, func(tx *gorm.DB, now time.Time, tasks []task) error {
+			ids := []string{}
+			for _, t := range tasks {
+				ids = append(ids, t.ID)
+			}
+			return tx.Model(&task{}).Where("id IN ?", ids).Update("due_at", now.Add(time.Minute)).Error
+		})
+	}
+
+	// reserved until the lease is over
+	tasks, err := claim()
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+	tasks, err = claim()
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+
+	// rolled back if the reservation fails
+	_, err = queueutil.Claim(context.Background(), db, "", func(tx *gorm.DB, _ time.Time) *gorm.DB {
+		return tx
+	}, func(tx *gorm.DB, _ time.Time, _ []task) error {
+		require.NoError(t, tx.Delete(&task{}, "id = ?", "3").Error)
+		return errors.New("reserve")
+	})
+	assert.Error(t, err)
+	var count int64
+	require.NoError(t, db.Model(&task{}).Count(&count).Error)
+	assert.EqualValues(t, 3, count)
+}
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls, errs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		queueutil.Poll(ctx, time.Hour, func(error) { errs.Add(1) }, func(context.Context) (bool, error) {
+			// more twice without waiting, then an error which is waited for
+			switch calls.Add(1) {
+			case 1, 2:
+				return true, nil
+			default:
+				return true, errors.New("boom")
+			}
+		})
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return errs.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.EqualValues(t, 3, calls.Load())
+}
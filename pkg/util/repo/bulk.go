@@ -0,0 +1,475 @@
+package repoutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Supported formats of Import & Export
+const (
+	// Comma separated values with a header row of the JSON names of the fields
+	FormatCSV = "csv"
+	// Newline delimited JSON, one object per line
+	FormatNDJSON = "ndjson"
+)
+
+// DefaultBulkBatchSize is the default number of records per batch of Import & Export
+const DefaultBulkBatchSize = 500
+
+// ErrUnsupportedFormat is returned when the format is neither FormatCSV nor FormatNDJSON
+var ErrUnsupportedFormat = errors.New("repoutil: unsupported format, expecting csv or ndjson")
+
+// ErrInvalidHeader is returned when the CSV header is missing or has unknown columns
+var ErrInvalidHeader = errors.New("repoutil: invalid CSV header")
+
+// errDryRun rolls back dry-run imports
+var errDryRun = errors.New("repoutil: dry run")
+
+// Validator validates the imported rows, e.g. server.CustomValidator
+type Validator interface {
+	Validate(i any) error
+}
+
+// ImportOptions holds the options of Import
+type ImportOptions struct {
+	// FormatCSV or FormatNDJSON
+	Format string
+	// Number of records created per batch, DefaultBulkBatchSize if zero
+	BatchSize int
+	// Whether to roll back all changes, reporting what would have been imported
+	DryRun bool
+	// Validates each decoded row if given
+	Validator Validator
+	// Called with each created record, a pointer of the repo type, in the transaction creating it, e.g. to emit events.
+	// Errors fail the record like the creation does.
+	AfterCreate func(ctx context.Context, rec any) error
+}
+
+// RowError represents the error of an imported row
+type RowError struct {
+	// The row number, starting from 1. The CSV header is not counted.
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult represents the result of Import
+type ImportResult struct {
+	// Number of rows read
+	Total int `json:"total"`
+	// Number of records created, or would be created for dry-run
+	Imported int        `json:"imported"`
+	DryRun   bool       `json:"dry_run"`
+	Errors   []RowError `json:"errors"`
+}
+
+// Import reads the rows of type R from r, converts them into records and creates them in batches.
+// Invalid rows and rows failed to be created are reported in the result, the other rows are still imported.
+// Each batch runs in a transaction, failed batches are retried row by row to find the offending ones.
+// Errors of the reader itself, e.g. unknown CSV columns, abort the import.
+func Import[R, T any](ctx context.Context, d *Repo[T], r io.Reader, opts ImportOptions, convert func(ctx context.Context, row *R) (*T, error)) (*ImportResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBulkBatchSize
+	}
+	dec, err := newRowDecoder[R](r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Errors: []RowError{}}
+	run := func(ctx context.Context) error {
+		rows := make([]int, 0, opts.BatchSize)
+		batch := make([]T, 0, opts.BatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			imported, rowErrs, err := d.importBatch(ctx, rows, batch, opts.AfterCreate)
+			if err != nil {
+				return err
+			}
+			result.Imported += imported
+			result.Errors = append(result.Errors, rowErrs...)
+			rows, batch = rows[:0], batch[:0]
+			return nil
+		}
+
+		for {
+			row := new(R)
+			err := dec.next(row)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			result.Total++
+			var rowErr *rowDecodeError
+			if errors.As(err, &rowErr) {
+				result.Errors = append(result.Errors, RowError{Row: result.Total, Error: rowErr.Error()})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if opts.Validator != nil {
+				if err := opts.Validator.Validate(row); err != nil {
+					result.Errors = append(result.Errors, RowError{Row: result.Total, Error: err.Error()})
+					continue
+				}
+			}
+			rec, err := convert(ctx, row)
+			if err != nil {
+				result.Errors = append(result.Errors, RowError{Row: result.Total, Error: err.Error()})
+				continue
+			}
+
+			rows, batch = append(rows, result.Total), append(batch, *rec)
+			if len(batch) >= opts.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	}
+
+	if !opts.DryRun {
+		if err := run(ctx); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// dry-run imports everything in a transaction to be rolled back, so conflicts between batches are also reported
+	if err := Transaction(ctx, d.GDB, func(ctx context.Context) error {
+		if err := run(ctx); err != nil {
+			return err
+		}
+		return errDryRun
+	}); !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importBatch creates the records in a transaction, falling back to one by one if the batch fails
+func (d *Repo[T]) importBatch(ctx context.Context, rows []int, batch []T, afterCreate func(context.Context, any) error) (int, []RowError, error) {
+	imported, rowErrs := 0, []RowError{}
+	create := func(ctx context.Context, recs []T) error {
+		if err := d.CreateInBatches(ctx, recs, len(recs)); err != nil || afterCreate == nil {
+			return err
+		}
+		for i := range recs {
+			if err := afterCreate(ctx, &recs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := Transaction(ctx, d.GDB, func(ctx context.Context) error {
+		// nested, so the outer transaction is still usable if the batch fails
+		if err := Transaction(ctx, d.GDB, func(ctx context.Context) error {
+			return create(ctx, batch)
+		}); err == nil {
+			imported = len(batch)
+		} else {
+			for i := range batch {
+				if err := Transaction(ctx, d.GDB, func(ctx context.Context) error {
+					return create(ctx, batch[i:i+1])
+				}); err != nil {
+					rowErrs = append(rowErrs, RowError{Row: rows[i], Error: err.Error()})
+					continue
+				}
+				imported++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return imported, rowErrs, nil
+}
+
+// Export writes the records matching the filter & search query of the condition to w in the given format, returns the number of records.
+// The records are read in batches using ReadAllByCursor, in the order of the condition; paging fields are ignored.
+func (d *Repo[T]) Export(ctx context.Context, w io.Writer, format string, lqc *ListQueryCondition) (int, error) {
+	enc, err := newRowEncoder[T](w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	cond := &ListQueryCondition{PerPage: DefaultBulkBatchSize}
+	if lqc != nil {
+		cond.Sort, cond.Search, cond.Filter = lqc.Sort, lqc.Search, lqc.Filter
+	}
+	total := 0
+	for {
+		result, err := d.ReadAllByCursor(ctx, cond)
+		if err != nil {
+			return total, err
+		}
+		for i := range result.Items {
+			if err := enc.write(&result.Items[i]); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		cond.Cursor = result.NextCursor
+	}
+	return total, enc.flush()
+}
+
+// rowDecodeError is the error of decoding a single row, the decoder can continue with the next row
+type rowDecodeError struct {
+	err error
+}
+
+func (e *rowDecodeError) Error() string {
+	return e.err.Error()
+}
+
+type rowDecoder interface {
+	// next decodes the next row into dst, returns io.EOF if there is no more row
+	next(dst any) error
+}
+
+type rowEncoder interface {
+	write(src any) error
+	flush() error
+}
+
+func newRowDecoder[R any](r io.Reader, format string) (rowDecoder, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: missing header", ErrInvalidHeader)
+			}
+			return nil, err
+		}
+		fields := jsonFieldsOf(reflect.TypeOf(new(R)).Elem())
+		columns := make([]*jsonField, len(header))
+		for i, name := range header {
+			name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+			f, ok := fields.byName[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidHeader, name)
+			}
+			columns[i] = f
+		}
+		return &csvDecoder{r: cr, columns: columns}, nil
+	case FormatNDJSON:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		return &ndjsonDecoder{s: s}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func newRowEncoder[T any](w io.Writer, format string) (rowEncoder, error) {
+	switch format {
+	case FormatCSV:
+		fields := jsonFieldsOf(reflect.TypeOf(new(T)).Elem())
+		cw := csv.NewWriter(w)
+		header := make([]string, 0, len(fields.list))
+		for _, f := range fields.list {
+			header = append(header, f.name)
+		}
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw, header: header}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns []*jsonField
+}
+
+// next converts the cells into a JSON object, so the row is decoded with the same rules as NDJSON
+func (d *csvDecoder) next(dst any) error {
+	record, err := d.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &rowDecodeError{err}
+		}
+		return err
+	}
+	if len(record) != len(d.columns) {
+		return &rowDecodeError{fmt.Errorf("expecting %d columns, got %d", len(d.columns), len(record))}
+	}
+
+	obj := make(map[string]json.RawMessage, len(record))
+	for i, cell := range record {
+		if cell == "" {
+			continue
+		}
+		f := d.columns[i]
+		if f.quoted {
+			data, _ := json.Marshal(cell)
+			obj[f.name] = data
+		} else {
+			obj[f.name] = json.RawMessage(cell)
+		}
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return &rowDecodeError{err}
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return &rowDecodeError{err}
+	}
+	return nil
+}
+
+type ndjsonDecoder struct {
+	s *bufio.Scanner
+}
+
+func (d *ndjsonDecoder) next(dst any) error {
+	for d.s.Scan() {
+		line := strings.TrimSpace(d.s.Text())
+		if line == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), dst); err != nil {
+			return &rowDecodeError{err}
+		}
+		return nil
+	}
+	if err := d.s.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+}
+
+// write converts the record into JSON first, so the cells have the same values as NDJSON
+func (e *csvEncoder) write(src any) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	record := make([]string, len(e.header))
+	for i, name := range e.header {
+		v, ok := obj[name]
+		if !ok || string(v) == "null" {
+			continue
+		}
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			record[i] = s
+		} else {
+			record[i] = string(v)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) write(src any) error {
+	return e.enc.Encode(src)
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
+
+// jsonField is a field of a struct as encoded by encoding/json
+type jsonField struct {
+	name  string
+	depth int
+	// Whether the CSV cells are JSON strings, e.g. strings & times, rather than raw JSON values such as numbers
+	quoted bool
+}
+
+type jsonFields struct {
+	list   []*jsonField
+	byName map[string]*jsonField
+}
+
+var jsonFieldsCache sync.Map
+
+// jsonFieldsOf returns the fields of the struct type in the order of encoding/json, embedded structs are flattened
+func jsonFieldsOf(typ reflect.Type) *jsonFields {
+	if cached, ok := jsonFieldsCache.Load(typ); ok {
+		return cached.(*jsonFields)
+	}
+
+	fields := &jsonFields{byName: map[string]*jsonField{}}
+	var walk func(t reflect.Type, depth int)
+	walk = func(t reflect.Type, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, depth+1)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			quoted := ft.Kind() == reflect.String || ft.Kind() == reflect.Struct
+			// the shallower field wins, same as encoding/json
+			if f, ok := fields.byName[name]; ok {
+				if depth < f.depth {
+					f.depth, f.quoted = depth, quoted
+				}
+				continue
+			}
+			f := &jsonField{name: name, depth: depth, quoted: quoted}
+			fields.list = append(fields.list, f)
+			fields.byName[name] = f
+		}
+	}
+	walk(typ, 0)
+
+	jsonFieldsCache.Store(typ, fields)
+	return fields
+}
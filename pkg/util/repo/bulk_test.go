@@ -0,0 +1,196 @@
+package repoutil_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+	searchutil "runar-himmel/pkg/util/search"
+)
+
+type contact struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	Email  string `json:"email" gorm:"uniqueIndex"`
+	Name   string `json:"name"`
+	Age    int    `json:"age"`
+	Secret string `json:"-"`
+}
+
+type contactRow struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Age   *int   `json:"age"`
+}
+
+type validatorFunc func(i any) error
+
+func (f validatorFunc) Validate(i any) error {
+	return f(i)
+}
+
+func requireEmail(i any) error {
+	if !strings.Contains(i.(*contactRow).Email, "@") {
+		return errors.New("invalid email")
+	}
+	return nil
+}
+
+func toContact(_ context.Context, row *contactRow) (*contact, error) {
+	c := &contact{Email: row.Email, Name: row.Name, Secret: "imported"}
+	if row.Age != nil {
+		if *row.Age < 0 {
+			return nil, errors.New("invalid age")
+		}
+		c.Age = *row.Age
+	}
+	return c, nil
+}
+
+func contactEmails(t *testing.T, r *repoutil.Repo[contact]) []string {
+	var list []contact
+	require.NoError(t, r.ReadAll(context.Background(), &list))
+	emails := []string{}
+	for _, c := range list {
+		emails = append(emails, c.Email)
+	}
+	return emails
+}
+
+func TestImport(t *testing.T) {
+	db := dbtest.New(t, &contact{})
+	r := repoutil.NewRepo[contact](db)
+	ctx := context.Background()
+	require.NoError(t, r.Create(ctx, &contact{Email: "odin@asgard"}))
+
+	csvData := "\ufeffemail,name,age\n" +
+		"thor@asgard,Thor,1500\n" +
+		"loki,Loki,\n" + // invalid email
+		"odin@asgard,Odin,\n" + // duplicated with the existing one
+		"frigg@asgard,\"Frigg, Queen\",abc\n" + // invalid age
+		"baldr@asgard,Baldr,-1\n" + // rejected by convert
+		"heimdall@asgard,Heimdall\n" + // missing column
+		"thor@asgard,Thor again,\n" + // duplicated in the same batch
+		"tyr@asgard,Tyr,42\n"
+	opts := repoutil.ImportOptions{Format: repoutil.FormatCSV, BatchSize: 3, Validator: validatorFunc(requireEmail)}
+
+	// dry-run reports the same but changes nothing
+	opts.DryRun = true
+	dry, err := repoutil.Import(ctx, r, strings.NewReader(csvData), opts, toContact)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"odin@asgard"}, contactEmails(t, r))
+
+	opts.DryRun = false
+	result, err := repoutil.Import(ctx, r, strings.NewReader(csvData), opts, toContact)
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.Total)
+	assert.Equal(t, 2, result.Imported)
+	rows := []int{}
+	for _, e := range result.Errors {
+		rows = append(rows, e.Row)
+	}
+	assert.ElementsMatch(t, []int{2, 3, 4, 5, 6, 7}, rows)
+	assert.Equal(t, []string{"odin@asgard", "thor@asgard", "tyr@asgard"}, contactEmails(t, r))
+
+	dry.DryRun = false
+	assert.Equal(t, result, dry)
+
+	// ndjson
+	ndjson := `{"email":"freyr@vanaheim","name":"Freyr","age":30}` + "\n\n" +
+		`{"email":"freyja@vanaheim",` + "\n" +
+		`{"email":"njord@vanaheim","unknown":true}` + "\n"
+	result, err = repoutil.Import(ctx, r, strings.NewReader(ndjson), repoutil.ImportOptions{Format: repoutil.FormatNDJSON}, toContact)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, result.Errors[0].Row)
+
+	// after create hooks run in the transaction of each record, failing it along with the creation
+	created := []string{}
+	hookOpts := repoutil.ImportOptions{Format: repoutil.FormatNDJSON, AfterCreate: func(ctx context.Context, rec any) error {
+		c := rec.(*contact)
+		if c.Name == "Hel" {
+			return errors.New("not welcome")
+		}
+		_, inTx := repoutil.TxFromContext(ctx)
+		assert.True(t, inTx)
+		assert.NotZero(t, c.ID)
+		created = append(created, c.Email)
+		return nil
+	}}
+	ndjson = `{"email":"bragi@asgard","name":"Bragi"}` + "\n" + `{"email":"hel@helheim","name":"Hel"}` + "\n"
+	result, err = repoutil.Import(ctx, r, strings.NewReader(ndjson), hookOpts, toContact)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, "not welcome", result.Errors[0].Error)
+	// called again when the failed batch is retried row by row
+	assert.Equal(t, []string{"bragi@asgard", "bragi@asgard"}, created)
+	assert.NotContains(t, contactEmails(t, r), "hel@helheim")
+
+	// invalid input
+	_, err = repoutil.Import(ctx, r, strings.NewReader("email,password\n"), opts, toContact)
+	assert.ErrorIs(t, err, repoutil.ErrInvalidHeader)
+	_, err = repoutil.Import(ctx, r, strings.NewReader(""), opts, toContact)
+	assert.ErrorIs(t, err, repoutil.ErrInvalidHeader)
+	_, err = repoutil.Import(ctx, r, strings.NewReader(""), repoutil.ImportOptions{Format: "xml"}, toContact)
+	assert.ErrorIs(t, err, repoutil.ErrUnsupportedFormat)
+}
+
+func TestExport(t *testing.T) {
+	db := dbtest.New(t, &contact{})
+	r := repoutil.NewRepo[contact](db)
+	ctx := context.Background()
+	require.NoError(t, r.CreateInBatches(ctx, []contact{
+		{Email: "odin@asgard", Name: "Odin", Age: 5000, Secret: "x"},
+		{Email: "thor@asgard", Name: "Thor, Son of Odin", Age: 1500},
+		{Email: "freyr@vanaheim", Name: "Freyr"},
+	}, 10))
+
+	buf := &bytes.Buffer{}
+	lqc := &repoutil.ListQueryCondition{Sort: "-age", Filter: []any{"email LIKE ?", "%@asgard"}, Page: 2, PerPage: 1}
+	n, err := r.Export(ctx, buf, repoutil.FormatCSV, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "id,email,name,age\n1,odin@asgard,Odin,5000\n2,thor@asgard,\"Thor, Son of Odin\",1500\n", buf.String())
+
+	buf.Reset()
+	n, err = r.Export(ctx, buf, repoutil.FormatNDJSON, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, `{"id":3,"email":"freyr@vanaheim","name":"Freyr","age":0}`, lines[2])
+
+	// exported files can be imported again
+	buf.Reset()
+	_, err = r.Export(ctx, buf, repoutil.FormatCSV, nil)
+	require.NoError(t, err)
+	other := repoutil.NewRepo[contact](dbtest.New(t, &contact{}))
+	result, err := repoutil.Import(ctx, other, buf, repoutil.ImportOptions{Format: repoutil.FormatCSV}, func(_ context.Context, c *contact) (*contact, error) {
+		return c, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Imported)
+	assert.Empty(t, result.Errors)
+
+	_, err = r.Export(ctx, buf, "xml", nil)
+	assert.ErrorIs(t, err, repoutil.ErrUnsupportedFormat)
+
+	// the search query is applied as well
+	lqc = &repoutil.ListQueryCondition{Search: "odin"}
+	_, err = r.Export(ctx, buf, repoutil.FormatCSV, lqc)
+	assert.ErrorIs(t, err, repoutil.ErrSearchNotSupported)
+
+	r.Searcher = searchutil.New(db, searchutil.Index{Name: "ftx_contacts", Table: "contacts", Columns: []string{"name"}})
+	buf.Reset()
+	n, err = r.Export(ctx, buf, repoutil.FormatCSV, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "id,email,name,age\n1,odin@asgard,Odin,5000\n2,thor@asgard,\"Thor, Son of Odin\",1500\n", buf.String())
+}
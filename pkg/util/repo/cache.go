@@ -0,0 +1,282 @@
+package repoutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"time"
+
+	cacheutil "runar-himmel/pkg/util/cache"
+
+	"gorm.io/gorm/schema"
+)
+
+// NewCachedRepo creates new read-through cache decorator of the given repo, the entries expire after the given TTL.
+// Nil store disables the cache, all calls go to the repo as is.
+func NewCachedRepo[T any](repo *Repo[T], store cacheutil.Store, ttl time.Duration) *CachedRepo[T] {
+	return &CachedRepo[T]{Repo: repo, Store: store, TTL: ttl}
+}
+
+// CachedRepo decorates Repo with a read-through cache of ReadByID and ReadBy.
+// Update, UpdateMany, UpdateWithVersion, Upsert and Delete invalidate the entries of the affected records,
+// after the commit when run in a transaction started by RunTx or Transaction.
+// Reads in transactions bypass the cache, so uncommitted changes are never cached.
+//
+// The records are encoded with encoding/gob, so fields hidden from JSON (e.g. password hashes) are kept.
+// Writes that bypass the decorator, e.g. raw queries via GDB, must invalidate the entries with Invalidate.
+type CachedRepo[T any] struct {
+	*Repo[T]
+	Store cacheutil.Store
+	// Time to live of the entries, zero means no expiration
+	TTL time.Duration
+}
+
+// ReadByID gets a record by primary key, from the cache if possible
+func (d *CachedRepo[T]) ReadByID(ctx context.Context, output *T, id string) error {
+	if !d.cacheable(ctx) {
+		return d.Repo.ReadByID(ctx, output, id)
+	}
+
+	key, err := d.key(id)
+	if err != nil {
+		return err
+	}
+	if rec, ok := d.get(ctx, key); ok && d.inTenant(ctx, rec) {
+		*output = *rec
+		return nil
+	}
+
+	if err := d.Repo.ReadByID(ctx, output, id); err != nil {
+		return err
+	}
+	d.set(ctx, key, output)
+	return nil
+}
+
+// ReadBy gets a record by a unique column, e.g. `email`, from the cache if possible.
+// The cache maps the value to the primary key, then loads the record using ReadByID.
+func (d *CachedRepo[T]) ReadBy(ctx context.Context, output *T, column string, value any) error {
+	if !d.cacheable(ctx) {
+		return d.Repo.Read(ctx, output, d.quoteCol(column)+" = ?", value)
+	}
+
+	s, err := d.parseSchema()
+	if err != nil {
+		return err
+	}
+	field := s.LookUpField(column)
+	if field == nil {
+		return fmt.Errorf("repoutil: column %q not found in %s", column, s.Name)
+	}
+	key := d.prefix(s) + field.DBName + ":" + fmt.Sprint(value)
+
+	if id, ok, err := d.Store.Get(ctx, key); err == nil && ok {
+		// the column may have changed since the mapping was cached
+		if err := d.ReadByID(ctx, output, string(id)); err == nil && d.fieldEquals(ctx, field, output, value) {
+			return nil
+		}
+		_ = d.Store.Delete(ctx, key)
+		*output = *new(T)
+	}
+
+	if err := d.Repo.Read(ctx, output, d.quoteCol(field.DBName)+" = ?", value); err != nil {
+		return err
+	}
+	if id := d.primaryKey(ctx, s, output); id != "" {
+		_ = d.Store.Set(ctx, key, []byte(id), d.TTL)
+		if idKey, err := d.key(id); err == nil {
+			d.set(ctx, idKey, output)
+		}
+	}
+	return nil
+}
+
+// Update updates records by conditions, then invalidates their entries
+func (d *CachedRepo[T]) Update(ctx context.Context, updates any, conds ...any) error {
+	return d.invalidateAfter(ctx, conds, func() error {
+		return d.Repo.Update(ctx, updates, conds...)
+	})
+}
+
+// UpdateMany updates records by conditions, then invalidates their entries
+func (d *CachedRepo[T]) UpdateMany(ctx context.Context, updates any, conds ...any) (int64, error) {
+	var affected int64
+	err := d.invalidateAfter(ctx, conds, func() (err error) {
+		affected, err = d.Repo.UpdateMany(ctx, updates, conds...)
+		return
+	})
+	return affected, err
+}
+
+// Upsert creates or updates the record, then invalidates its entry, see Repo.Upsert
+func (d *CachedRepo[T]) Upsert(ctx context.Context, input *T, conflictColumns []string, updateColumns ...string) error {
+	if err := d.Repo.Upsert(ctx, input, conflictColumns, updateColumns...); err != nil {
+		return err
+	}
+	if d.Store == nil {
+		return nil
+	}
+	s, err := d.parseSchema()
+	if err != nil {
+		return err
+	}
+	return d.invalidate(ctx, d.primaryKey(ctx, s, input))
+}
+
+// UpdateWithVersion updates a record by conditions and version, then invalidates its entry, see Repo.UpdateWithVersion
+func (d *CachedRepo[T]) UpdateWithVersion(ctx context.Context, version int64, updates map[string]any, conds ...any) error {
+	return d.invalidateAfter(ctx, conds, func() error {
+		return d.Repo.UpdateWithVersion(ctx, version, updates, conds...)
+	})
+}
+
+// Delete deletes records by conditions, then invalidates their entries
+func (d *CachedRepo[T]) Delete(ctx context.Context, conds ...any) error {
+	return d.invalidateAfter(ctx, conds, func() error {
+		return d.Repo.Delete(ctx, conds...)
+	})
+}
+
+// Invalidate removes the entries of the given primary keys
+func (d *CachedRepo[T]) Invalidate(ctx context.Context, ids ...string) error {
+	if d.Store == nil || len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key, err := d.key(id)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	return d.Store.Delete(ctx, keys...)
+}
+
+// invalidateAfter runs the write then invalidates the entries of the records matching the conditions.
+// The records are looked up before the write, as the write may change the matching columns.
+func (d *CachedRepo[T]) invalidateAfter(ctx context.Context, conds []any, write func() error) error {
+	if d.Store == nil {
+		return write()
+	}
+
+	var ids []string
+	db := d.scoped(ctx).Model(new(T))
+	if where := parseConds(conds); len(where) > 0 {
+		db = db.Where(where[0], where[1:]...)
+	}
+	if err := db.Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	if err := write(); err != nil {
+		return err
+	}
+	return d.invalidate(ctx, ids...)
+}
+
+// invalidate removes the entries of the written records. In a transaction, they are removed once it is committed,
+// otherwise concurrent reads could cache the previous version of the records until then.
+func (d *CachedRepo[T]) invalidate(ctx context.Context, ids ...string) error {
+	db := d.DB(ctx)
+	if !InTx(db) {
+		return d.Invalidate(ctx, ids...)
+	}
+	// the keys are checked now, as the errors cannot be returned after the commit
+	for _, id := range ids {
+		if _, err := d.key(id); err != nil {
+			return err
+		}
+	}
+	AfterCommit(db, func() {
+		_ = d.Invalidate(context.WithoutCancel(ctx), ids...)
+	})
+	return nil
+}
+
+// cacheable checks whether the reads of the given context may use the cache
+func (d *CachedRepo[T]) cacheable(ctx context.Context) bool {
+	if d.Store == nil || InTx(d.GDB) {
+		return false
+	}
+	if _, ok := TxFromContext(ctx); ok {
+		return false
+	}
+	// let the repo fail with ErrMissingTenant
+	if _, ok := TenantFromContext(ctx); d.TenantColumn != "" && !ok && !skipTenant(ctx) {
+		return false
+	}
+	return true
+}
+
+// inTenant checks whether the cached record belongs to the tenant of the context
+func (d *CachedRepo[T]) inTenant(ctx context.Context, rec *T) bool {
+	tenantID, ok := TenantFromContext(ctx)
+	if d.TenantColumn == "" || !ok {
+		return true
+	}
+	s, err := d.parseSchema()
+	if err != nil {
+		return false
+	}
+	field := s.LookUpField(d.TenantColumn)
+	return field != nil && d.fieldEquals(ctx, field, rec, tenantID)
+}
+
+// get returns the cached record, failures are treated as cache misses
+func (d *CachedRepo[T]) get(ctx context.Context, key string) (*T, bool) {
+	data, ok, err := d.Store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	rec := new(T)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(rec); err != nil {
+		return nil, false
+	}
+	return rec, true
+}
+
+// set caches the record, failures are ignored as the cache is best effort
+func (d *CachedRepo[T]) set(ctx context.Context, key string, rec *T) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(rec); err != nil {
+		return
+	}
+	_ = d.Store.Set(ctx, key, buf.Bytes(), d.TTL)
+}
+
+func (d *CachedRepo[T]) key(id string) (string, error) {
+	s, err := d.parseSchema()
+	if err != nil {
+		return "", err
+	}
+	return d.prefix(s) + "id:" + id, nil
+}
+
+func (d *CachedRepo[T]) prefix(s *schema.Schema) string {
+	return "repo:" + s.Table + ":"
+}
+
+func (d *CachedRepo[T]) primaryKey(ctx context.Context, s *schema.Schema, rec *T) string {
+	if s.PrioritizedPrimaryField == nil {
+		return ""
+	}
+	v, zero := s.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(rec).Elem())
+	if zero {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (d *CachedRepo[T]) fieldEquals(ctx context.Context, field *schema.Field, rec *T, value any) bool {
+	v, _ := field.ValueOf(ctx, reflect.ValueOf(rec).Elem())
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return false
+		}
+		v = rv.Elem().Interface()
+	}
+	return fmt.Sprint(v) == fmt.Sprint(value)
+}
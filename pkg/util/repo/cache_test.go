@@ -0,0 +1,173 @@
+package repoutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	cacheutil "runar-himmel/pkg/util/cache"
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type profile struct {
+	ID       string `gorm:"primaryKey"`
+	TenantID string
+	Email    string `gorm:"uniqueIndex"`
+	Password string `json:"-"`
+}
+
+func TestCachedRepo(t *testing.T) {
+	db := dbtest.New(t, &profile{})
+	store := cacheutil.NewLRU(100)
+	r := repoutil.NewCachedRepo(repoutil.NewRepo[profile](db), store, time.Minute)
+	ctx := context.Background()
+	require.NoError(t, r.Create(ctx, &profile{ID: "1", Email: "odin@asgard", Password: "secret"}))
+
+	// changes behind the repo are not seen once cached, including the fields hidden from JSON
+	rec := &profile{}
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	require.NoError(t, db.Model(&profile{}).Where("id = ?", "1").Update("password", "changed").Error)
+	rec = &profile{}
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "secret", rec.Password)
+
+	// unless invalidated
+	require.NoError(t, r.Invalidate(ctx, "1"))
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "changed", rec.Password)
+
+	// writes through the repo invalidate the entries
+	require.NoError(t, r.Update(ctx, map[string]any{"password": "updated"}, "email = ?", "odin@asgard"))
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "updated", rec.Password)
+
+	// reads by unique column
+	rec = &profile{}
+	require.NoError(t, r.ReadBy(ctx, rec, "email", "odin@asgard"))
+	assert.Equal(t, "1", rec.ID)
+	require.NoError(t, r.Update(ctx, map[string]any{"email": "allfather@asgard"}, "1"))
+	assert.ErrorIs(t, r.ReadBy(ctx, &profile{}, "email", "odin@asgard"), gorm.ErrRecordNotFound)
+	rec = &profile{}
+	require.NoError(t, r.ReadBy(ctx, rec, "Email", "allfather@asgard"))
+	assert.Equal(t, "1", rec.ID)
+	assert.Error(t, r.ReadBy(ctx, rec, "missing", "x"))
+
+	// bulk updates & upserts invalidate too
+	n, err := r.UpdateMany(ctx, map[string]any{"password": "bulk"}, map[string]any{"id__in": []string{"1"}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	rec = &profile{}
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "bulk", rec.Password)
+	require.NoError(t, r.Upsert(ctx, &profile{ID: "9", Email: "allfather@asgard", Password: "upserted"}, []string{"email"}, "password"))
+	rec = &profile{}
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "upserted", rec.Password)
+
+	require.NoError(t, r.Delete(ctx, "1"))
+	assert.ErrorIs(t, r.ReadByID(ctx, &profile{}, "1"), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, r.ReadBy(ctx, &profile{}, "email", "allfather@asgard"), gorm.ErrRecordNotFound)
+
+	// transactions bypass the cache
+	require.NoError(t, r.Create(ctx, &profile{ID: "2", Email: "thor@asgard"}))
+	require.NoError(t, r.ReadByID(ctx, &profile{}, "2"))
+	require.NoError(t, repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		tx, _ := repoutil.TxFromContext(ctx)
+		if err := tx.Model(&profile{}).Where("id = ?", "2").Update("password", "in tx").Error; err != nil {
+			return err
+		}
+		rec := &profile{}
+		require.NoError(t, r.ReadByID(ctx, rec, "2"))
+		assert.Equal(t, "in tx", rec.Password)
+		return nil
+	}))
+
+	// nil store disables the cache
+	uncached := repoutil.NewCachedRepo(repoutil.NewRepo[profile](db), nil, 0)
+	rec = &profile{}
+	require.NoError(t, uncached.ReadByID(ctx, rec, "2"))
+	assert.Equal(t, "in tx", rec.Password)
+	require.NoError(t, uncached.ReadBy(ctx, &profile{}, "email", "thor@asgard"))
+	require.NoError(t, uncached.Update(ctx, map[string]any{"password": "x"}, "2"))
+}
+
+func TestCachedRepoTenant(t *testing.T) {
+	db := dbtest.New(t, &profile{})
+	r := repoutil.NewCachedRepo(repoutil.NewTenantRepo[profile](db, "tenant_id"), cacheutil.NewLRU(100), time.Minute)
+	ctxA := repoutil.WithTenant(context.Background(), "a")
+	ctxB := repoutil.WithTenant(context.Background(), "b")
+	require.NoError(t, r.Create(ctxA, &profile{ID: "1", Email: "odin@asgard"}))
+
+	require.NoError(t, r.ReadByID(ctxA, &profile{}, "1"))
+	// the cached record is not leaked to other tenants
+	rec := &profile{}
+	assert.ErrorIs(t, r.ReadByID(ctxB, rec, "1"), gorm.ErrRecordNotFound)
+	assert.Empty(t, rec.Email)
+	assert.ErrorIs(t, r.ReadByID(context.Background(), rec, "1"), repoutil.ErrMissingTenant)
+	require.NoError(t, r.ReadByID(repoutil.WithoutTenant(context.Background()), rec, "1"))
+	assert.Equal(t, "odin@asgard", rec.Email)
+}
+
+func TestCachedRepoTransaction(t *testing.T) {
+	db := dbtest.New(t, &profile{})
+	store := cacheutil.NewLRU(100)
+	r := repoutil.NewCachedRepo(repoutil.NewRepo[profile](db), store, time.Minute)
+	ctx := context.Background()
+	require.NoError(t, r.Create(ctx, &profile{ID: "1", Email: "odin@asgard", Password: "secret"}))
+
+	readPassword := func() string {
+		rec := &profile{}
+		require.NoError(t, r.ReadByID(ctx, rec, "1"))
+		return rec.Password
+	}
+
+	// a read between the write and the commit caches the committed record, which is invalidated by the commit
+	require.NoError(t, repoutil.Transaction(ctx, db, func(txCtx context.Context) error {
+		if err := r.Update(txCtx, map[string]any{"password": "blocked"}, "1"); err != nil {
+			return err
+		}
+		assert.Equal(t, "secret", readPassword())
+		return nil
+	}))
+	assert.Equal(t, "blocked", readPassword())
+
+	// nested transactions are invalidated along with the outermost one
+	require.NoError(t, repoutil.Transaction(ctx, db, func(txCtx context.Context) error {
+		if err := repoutil.Transaction(txCtx, db, func(txCtx context.Context) error {
+			_, err := r.UpdateMany(txCtx, map[string]any{"password": "nested"}, "1")
+			return err
+		}); err != nil {
+			return err
+		}
+		assert.Equal(t, "blocked", readPassword())
+		return nil
+	}))
+	assert.Equal(t, "nested", readPassword())
+
+	// rolled back, the cached record is still valid
+	assert.Error(t, repoutil.Transaction(ctx, db, func(txCtx context.Context) error {
+		if err := r.Update(txCtx, map[string]any{"password": "rolled back"}, "1"); err != nil {
+			return err
+		}
+		assert.Equal(t, "nested", readPassword())
+		return errors.New("rollback")
+	}))
+	assert.Equal(t, "nested", readPassword())
+
+	// repos bound to the transaction, e.g. by repo.Service.Transaction
+	require.NoError(t, repoutil.RunTx(ctx, db, func(tx *gorm.DB) error {
+		txRepo := repoutil.NewCachedRepo(repoutil.NewRepo[profile](tx), store, time.Minute)
+		if err := txRepo.Upsert(ctx, &profile{ID: "9", Email: "odin@asgard", Password: "upserted"}, []string{"email"}, "password"); err != nil {
+			return err
+		}
+		assert.Equal(t, "nested", readPassword())
+		return nil
+	}))
+	assert.Equal(t, "upserted", readPassword())
+}
@@ -2,64 +2,84 @@ package repoutil
 
 import (
 	"context"
+	"errors"
 
 	"gorm.io/gorm"
 )
 
+// ErrSearchNotSupported is returned when listing with a search query by a repo without Searcher
+var ErrSearchNotSupported = errors.New("repoutil: search is not supported")
+
+// Searcher filters & ranks the records by full-text search queries, see searchutil.New
+type Searcher interface {
+	// Search filters db by the query, ordered by relevance if rank is true
+	Search(db *gorm.DB, q string, rank bool) *gorm.DB
+}
+
 // NewRepo creates new Repo instance
 func NewRepo[T any](db *gorm.DB) *Repo[T] {
-	return &Repo[T]{db}
+	return &Repo[T]{GDB: db}
+}
+
+// NewTenantRepo creates new Repo instance which scopes all queries by the given tenant column, see WithTenant
+func NewTenantRepo[T any](db *gorm.DB, tenantColumn string) *Repo[T] {
+	return &Repo[T]{GDB: db, TenantColumn: tenantColumn}
 }
 
 // Repo represents the client for common usages
 type Repo[T any] struct {
 	GDB *gorm.DB
+	// The column holding tenant ID, e.g. `organization_id`. Empty means the table is shared by all tenants.
+	TenantColumn string
+	// The full-text search of the table, for ListCondition.Search. Nil means search is not supported.
+	Searcher Searcher
 }
 
 // Create creates a new record
 func (d *Repo[T]) Create(ctx context.Context, input *T) error {
-	return d.GDB.WithContext(ctx).Create(input).Error
+	if err := d.setTenant(ctx, input); err != nil {
+		return err
+	}
+	return d.DB(ctx).Create(input).Error
 }
 
 // CreateInBatches creates multiple records in batches
 func (d *Repo[T]) CreateInBatches(ctx context.Context, input []T, batchSize int) error {
-	return d.GDB.WithContext(ctx).CreateInBatches(input, batchSize).Error
+	if err := d.setTenant(ctx, input); err != nil {
+		return err
+	}
+	return d.DB(ctx).CreateInBatches(input, batchSize).Error
 }
 
 // Read get a record by primary key
 func (d *Repo[T]) Read(ctx context.Context, output *T, conds ...any) error {
-	return d.GDB.WithContext(ctx).First(output, parseConds(conds)...).Error
+	return d.scoped(ctx).First(output, parseConds(conds)...).Error
 }
 
 // ReadByID gets a record by primary key
 func (d *Repo[T]) ReadByID(ctx context.Context, output *T, id string) error {
-	return d.GDB.WithContext(ctx).Take(output, `id = ?`, id).Error
+	return d.scoped(ctx).Take(output, `id = ?`, id).Error
 }
 
 // ReadAll gets all records that match given conditions
 func (d *Repo[T]) ReadAll(ctx context.Context, output *[]T, conds ...any) error {
-	return d.GDB.WithContext(ctx).Find(output, parseConds(conds)...).Error
+	return d.scoped(ctx).Find(output, parseConds(conds)...).Error
 }
 
 // Update updates a record by conditions
 func (d *Repo[T]) Update(ctx context.Context, updates any, conds ...any) error {
-	db := d.GDB.WithContext(ctx).Model(new(T))
-	conds = parseConds(conds)
-	if len(conds) > 0 {
-		db = db.Where(conds[0], conds[1:]...)
-	}
-	db = db.Omit("id").Updates(updates)
-	return db.Error
+	_, err := d.UpdateMany(ctx, updates, conds...)
+	return err
 }
 
 // Delete deletes a record by conditions
 func (d *Repo[T]) Delete(ctx context.Context, conds ...any) error {
-	return d.GDB.WithContext(ctx).Delete(new(T), parseConds(conds)...).Error
+	return d.scoped(ctx).Delete(new(T), parseConds(conds)...).Error
 }
 
 // Count counts records that match given conditions
 func (d *Repo[T]) Count(ctx context.Context, count *int64, conds ...any) error {
-	db := d.GDB.WithContext(ctx).Model(new(T))
+	db := d.scoped(ctx).Model(new(T))
 	if len(conds) > 0 {
 		conds = parseConds(conds)
 		db = db.Where(conds[0], conds[1:]...)
@@ -76,33 +96,43 @@ func (d *Repo[T]) Exist(ctx context.Context, conds ...any) (bool, error) {
 	return count > 0, nil
 }
 
-// ReadAllByCondition retrieves a list of entities based on the provided query conditions.
-func (d *Repo[T]) ReadAllByCondition(ctx context.Context, output []*T, count *int64, lqc *ListQueryCondition) error {
-	db := d.GDB.WithContext(ctx)
-
-	if lqc != nil {
-		// Parse and apply filter conditions
-		lqc.Filter = parseConds(lqc.Filter)
-		if len(lqc.Filter) > 0 {
-			db = d.GDB.Where(lqc.Filter[0], lqc.Filter[1:]...)
-		}
+// ReadAllByCondition retrieves a page of records based on the provided query conditions.
+// Nil condition returns all records. Search results are ordered by relevance if no sorting is given. The count and fetch queries run in the same session of the given context.
+func (d *Repo[T]) ReadAllByCondition(ctx context.Context, lqc *ListQueryCondition) (*ListResult[T], error) {
+	if lqc == nil {
+		lqc = &ListQueryCondition{}
+	}
+	result := &ListResult[T]{Items: []T{}, Page: lqc.Page, PerPage: lqc.PerPage}
+	if result.PerPage > 0 && result.Page < 1 {
+		result.Page = 1
+	}
 
-		// Apply pagination and sorting
-		db = withPaging(db, lqc.Page, lqc.PerPage)
-		db = withSorting(db, lqc.Sort, d.quoteCol)
+	db := d.scoped(ctx).Model(new(T))
+	if filter := parseConds(lqc.Filter); len(filter) > 0 {
+		db = db.Where(filter[0], filter[1:]...)
+	}
+	// ranked by relevance unless sorted explicitly, the ordering is dropped by the count query
+	db, err := d.withSearch(db, lqc.Search, lqc.Sort == "")
+	if err != nil {
+		return nil, err
+	}
+	// reusable for both count & fetch queries
+	db = db.Session(&gorm.Session{})
 
-		// Retrieve data
-		if err := db.Find(&output).Error; err != nil {
-			return err
-		}
+	fetch := withSorting(withPaging(db, result.Page, result.PerPage), lqc.Sort, d.quoteCol)
+	if err := fetch.Find(&result.Items).Error; err != nil {
+		return nil, err
 	}
 
-	// Count total records if requested
-	if lqc != nil && lqc.Count {
-		if err := db.Limit(-1).Offset(-1).Count(count).Error; err != nil {
-			return err
+	switch {
+	case result.PerPage <= 0:
+		// not paginated, all records are fetched
+		result.Total = int64(len(result.Items))
+	case lqc.Count:
+		if err := db.Count(&result.Total).Error; err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	return result, nil
 }
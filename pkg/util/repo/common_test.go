@@ -0,0 +1,165 @@
+package repoutil_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+	searchutil "runar-himmel/pkg/util/search"
+)
+
+func TestReadAllByCondition(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	r := repoutil.NewRepo[note](db)
+	ctx := context.Background()
+
+	notes := []note{}
+	for i := 1; i <= 5; i++ {
+		notes = append(notes, note{ID: fmt.Sprint(i), TenantID: []string{"a", "b"}[i%2], Content: fmt.Sprintf("note %d", i)})
+	}
+	require.NoError(t, r.CreateInBatches(ctx, notes, 10))
+
+	// nil condition returns everything
+	res, err := r.ReadAllByCondition(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 5)
+	assert.EqualValues(t, 5, res.Total)
+	assert.Zero(t, res.Page)
+	assert.Zero(t, res.PerPage)
+
+	// paging, sorting and counting
+	lqc := &repoutil.ListQueryCondition{Page: 2, PerPage: 2, Sort: "-id", Count: true}
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "2"}, ids(res.Items))
+	assert.EqualValues(t, 5, res.Total)
+	assert.Equal(t, 2, res.Page)
+	assert.Equal(t, 2, res.PerPage)
+
+	// the last page
+	lqc.Page = 3
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(res.Items))
+
+	// filtering applies to both fetching and counting
+	lqc = &repoutil.ListQueryCondition{PerPage: 1, Sort: "+id", Count: true, Filter: []any{map[string]any{"tenant_id": "b"}}}
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(res.Items))
+	assert.EqualValues(t, 3, res.Total)
+	assert.Equal(t, 1, res.Page)
+
+	// the condition is not modified, so it can be reused
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, res.Total)
+
+	// no counting unless requested
+	lqc.Count = false
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 1)
+	assert.Zero(t, res.Total)
+
+	// empty page is an empty list
+	res, err = r.ReadAllByCondition(ctx, &repoutil.ListQueryCondition{Page: 10, PerPage: 10})
+	require.NoError(t, err)
+	assert.NotNil(t, res.Items)
+	assert.Empty(t, res.Items)
+}
+
+func TestReadAllByConditionSearch(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	r := repoutil.NewRepo[note](db)
+	ctx := context.Background()
+
+	require.NoError(t, r.CreateInBatches(ctx, []note{
+		{ID: "1", TenantID: "a", Content: "feed the ravens"},
+		{ID: "2", TenantID: "b", Content: "ravens of odin"},
+		{ID: "3", TenantID: "a", Content: "brave ravens"},
+		{ID: "4", TenantID: "a", Content: "mead hall"},
+	}, 10))
+
+	_, err := r.ReadAllByCondition(ctx, &repoutil.ListQueryCondition{Search: "ravens"})
+	require.ErrorIs(t, err, repoutil.ErrSearchNotSupported)
+
+	r.Searcher = searchutil.New(db, searchutil.Index{Name: "ftx_notes", Table: "notes", Columns: []string{"content"}})
+
+	// ranked by relevance without sorting, counted with the filter
+	lqc := &repoutil.ListQueryCondition{PerPage: 2, Count: true, Search: "raven", Filter: []any{map[string]any{"tenant_id": "a"}}}
+	res, err := r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 2)
+	assert.EqualValues(t, 2, res.Total)
+
+	res, err = r.ReadAllByCondition(ctx, &repoutil.ListQueryCondition{Search: "RAVENS", Count: true})
+	require.NoError(t, err)
+	assert.Equal(t, "2", res.Items[0].ID, "the most relevant first")
+	assert.EqualValues(t, 3, res.Total)
+
+	// the sorting is respected
+	res, err = r.ReadAllByCondition(ctx, &repoutil.ListQueryCondition{Search: "ravens", Sort: "-id"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "2", "1"}, ids(res.Items))
+
+	// cursor pagination filters without ranking
+	res, err = r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 2, Search: "ravens", Count: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(res.Items))
+	assert.EqualValues(t, 3, res.Total)
+	res, err = r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 2, Search: "ravens", Cursor: res.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(res.Items))
+}
+
+func TestReadAllByConditionContext(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	r := repoutil.NewTenantRepo[note](db, "tenant_id")
+	require.NoError(t, r.CreateInBatches(repoutil.WithoutTenant(context.Background()), []note{{ID: "1", TenantID: "a"}, {ID: "2", TenantID: "b"}}, 10))
+
+	// the tenant scope applies to both fetching and counting
+	res, err := r.ReadAllByCondition(repoutil.WithTenant(context.Background(), "a"), &repoutil.ListQueryCondition{PerPage: 10, Count: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(res.Items))
+	assert.EqualValues(t, 1, res.Total)
+
+	_, err = r.ReadAllByCondition(context.Background(), nil)
+	assert.ErrorIs(t, err, repoutil.ErrMissingTenant)
+
+	ctx, cancel := context.WithCancel(repoutil.WithoutTenant(context.Background()))
+	cancel()
+	_, err = r.ReadAllByCondition(ctx, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func ids(list []note) []string {
+	l := make([]string, 0, len(list))
+	for _, n := range list {
+		l = append(l, n.ID)
+	}
+	return l
+}
+
+func TestAddFilter(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	r := repoutil.NewRepo[note](db)
+	ctx := context.Background()
+	require.NoError(t, r.CreateInBatches(ctx, []note{{ID: "1", TenantID: "a"}, {ID: "2", TenantID: "a"}, {ID: "3", TenantID: "b"}}, 10))
+
+	lqc := &repoutil.ListQueryCondition{Filter: []any{map[string]any{"tenant_id": "a"}}}
+	lqc.AddFilter("id <> ?", "1").AddFilter(nil)
+	res, err := r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids(res.Items))
+
+	lqc = (&repoutil.ListQueryCondition{}).AddFilter(map[string]any{"tenant_id": "b"})
+	res, err = r.ReadAllByCondition(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(res.Items))
+}
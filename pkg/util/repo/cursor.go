@@ -0,0 +1,226 @@
+package repoutil
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned when the cursor is malformed or does not match the sorting
+var ErrInvalidCursor = errors.New("repoutil: invalid cursor")
+
+// cursor is the decoded form of the opaque cursor
+type cursor struct {
+	// The sorting param the cursor was generated for
+	Sort string `json:"s"`
+	// The values of the sort keys, followed by the primary key
+	Values []json.RawMessage `json:"v"`
+	// Whether to fetch the records before the cursor
+	Prev bool `json:"p,omitempty"`
+}
+
+// sortKey is a column used for keyset pagination
+type sortKey struct {
+	field *schema.Field
+	desc  bool
+}
+
+// ReadAllByCursor retrieves a page of records after (or before) the given cursor, a.k.a keyset pagination.
+// Unlike ReadAllByCondition, the pages are stable when records are inserted between page loads.
+// The sort columns must not be nullable; the primary key is always appended as the tie-breaker,
+// in the direction of the last sort column.
+// Search results are not ranked by relevance but sorted the same way.
+// `Page` is ignored, `Cursor` is the NextCursor or PrevCursor of the previous result, empty for the first page.
+func (d *Repo[T]) ReadAllByCursor(ctx context.Context, lqc *ListQueryCondition) (*ListResult[T], error) {
+	if lqc == nil {
+		lqc = &ListQueryCondition{}
+	}
+	if lqc.PerPage <= 0 {
+		return d.ReadAllByCondition(ctx, &ListQueryCondition{Sort: lqc.Sort, Search: lqc.Search, Filter: lqc.Filter})
+	}
+
+	keys, err := d.sortKeys(lqc.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var cur *cursor
+	var curValues []any
+	if lqc.Cursor != "" {
+		if cur, err = decodeCursor(lqc.Cursor); err != nil || cur.Sort != lqc.Sort {
+			return nil, ErrInvalidCursor
+		}
+		if curValues, err = cur.values(keys); err != nil {
+			return nil, err
+		}
+	}
+	prev := cur != nil && cur.Prev
+
+	db := d.scoped(ctx).Model(new(T))
+	if filter := parseConds(lqc.Filter); len(filter) > 0 {
+		db = db.Where(filter[0], filter[1:]...)
+	}
+	// not ranked, the pages follow the sort keys
+	if db, err = d.withSearch(db, lqc.Search, false); err != nil {
+		return nil, err
+	}
+	db = db.Session(&gorm.Session{})
+
+	fetch := db
+	if curValues != nil {
+		cond, vars := d.keysetCond(keys, curValues, prev)
+		fetch = fetch.Where(cond, vars...)
+	}
+	orders := make([]string, 0, len(keys))
+	for _, k := range keys {
+		// fetching backwards reverses the order, then the items are reversed back
+		if k.desc != prev {
+			orders = append(orders, d.quoteCol(k.field.DBName)+" DESC")
+		} else {
+			orders = append(orders, d.quoteCol(k.field.DBName)+" ASC")
+		}
+	}
+
+	result := &ListResult[T]{Items: []T{}, PerPage: lqc.PerPage}
+	if err := fetch.Order(strings.Join(orders, ", ")).Limit(lqc.PerPage + 1).Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(result.Items) > lqc.PerPage
+	if hasMore {
+		result.Items = result.Items[:lqc.PerPage]
+	}
+	if prev {
+		for i, j := 0, len(result.Items)-1; i < j; i, j = i+1, j-1 {
+			result.Items[i], result.Items[j] = result.Items[j], result.Items[i]
+		}
+	}
+
+	if n := len(result.Items); n > 0 {
+		// more records after this page: either fetched forwards and found more, or came back from a later page
+		if prev || hasMore {
+			if result.NextCursor, err = encodeCursor(ctx, keys, lqc.Sort, &result.Items[n-1], false); err != nil {
+				return nil, err
+			}
+		}
+		if (prev && hasMore) || (!prev && cur != nil) {
+			if result.PrevCursor, err = encodeCursor(ctx, keys, lqc.Sort, &result.Items[0], true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if lqc.Count {
+		if err := db.Count(&result.Total).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// sortKeys returns the sort keys of the sorting param, followed by the primary key.
+// Only the columns of the model are allowed.
+func (d *Repo[T]) sortKeys(sort string) ([]sortKey, error) {
+	stmt := &gorm.Statement{DB: d.GDB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("repoutil: %s has no primary key for cursor pagination", stmt.Schema.Name)
+	}
+
+	keys := []sortKey{}
+	for _, v := range parseSortParam(sort) {
+		field := stmt.Schema.LookUpField(v[0])
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("repoutil: unknown sort column %q", v[0])
+		}
+		if field == pk {
+			// the primary key must be the last one, nothing after it matters
+			return append(keys, sortKey{field: pk, desc: v[1] == "DESC"}), nil
+		}
+		keys = append(keys, sortKey{field: field, desc: v[1] == "DESC"})
+	}
+	// the tie-breaker follows the direction of the last sort key, e.g. newest first for ULIDs
+	return append(keys, sortKey{field: pk, desc: len(keys) > 0 && keys[len(keys)-1].desc}), nil
+}
+
+// keysetCond builds the condition to fetch the records after (or before) the given key values:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func (d *Repo[T]) keysetCond(keys []sortKey, values []any, prev bool) (string, []any) {
+	ors := make([]string, 0, len(keys))
+	vars := []any{}
+	for i, k := range keys {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, d.quoteCol(keys[j].field.DBName)+" = ?")
+			vars = append(vars, values[j])
+		}
+		op := " > ?"
+		if k.desc != prev {
+			op = " < ?"
+		}
+		ands = append(ands, d.quoteCol(k.field.DBName)+op)
+		vars = append(vars, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", vars
+}
+
+// encodeCursor encodes the sort key values of the given record into an opaque cursor
+func encodeCursor(ctx context.Context, keys []sortKey, sort string, rec any, prev bool) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(rec))
+	cur := cursor{Sort: sort, Prev: prev}
+	for _, k := range keys {
+		v, _ := k.field.ValueOf(ctx, rv)
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		cur.Values = append(cur.Values, raw)
+	}
+
+	data, err := json.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes the opaque cursor
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cur := &cursor{}
+	if err := json.Unmarshal(data, cur); err != nil {
+		return nil, err
+	}
+	return cur, nil
+}
+
+// values decodes the key values into the types of the sort keys, so they are compared properly, e.g. time.Time
+func (cur *cursor) values(keys []sortKey) ([]any, error) {
+	if len(cur.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, 0, len(keys))
+	for i, k := range keys {
+		ptr := reflect.New(k.field.FieldType)
+		if err := json.Unmarshal(cur.Values[i], ptr.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, ptr.Elem().Interface())
+	}
+	return values, nil
+}
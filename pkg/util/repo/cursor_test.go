@@ -0,0 +1,141 @@
+package repoutil_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type post struct {
+	ID        string `gorm:"primaryKey"`
+	Author    string
+	CreatedAt time.Time
+}
+
+func TestReadAllByCursor(t *testing.T) {
+	db := dbtest.New(t, &post{})
+	r := repoutil.NewRepo[post](db)
+	ctx := context.Background()
+
+	// 7 posts, created in pairs to have duplicated sort keys
+	now := time.Now().UTC().Truncate(time.Second)
+	posts := []post{}
+	for i := 1; i <= 7; i++ {
+		posts = append(posts, post{ID: fmt.Sprintf("%02d", i), Author: []string{"a", "b"}[i%2], CreatedAt: now.Add(time.Duration(i/2) * time.Minute)})
+	}
+	require.NoError(t, r.CreateInBatches(ctx, posts, 10))
+
+	// forwards, newest first: 07 06 | 05 04 | 03 02 | 01
+	lqc := &repoutil.ListQueryCondition{PerPage: 2, Sort: "-created_at", Count: true}
+	pages := [][]string{}
+	var last *repoutil.ListResult[post]
+	for {
+		res, err := r.ReadAllByCursor(ctx, lqc)
+		require.NoError(t, err)
+		assert.EqualValues(t, 7, res.Total)
+		pages = append(pages, postIDs(res.Items))
+		assert.Equal(t, len(pages) > 1, res.PrevCursor != "")
+		last = res
+		if res.NextCursor == "" {
+			break
+		}
+		lqc.Cursor = res.NextCursor
+	}
+	assert.Equal(t, [][]string{{"07", "06"}, {"05", "04"}, {"03", "02"}, {"01"}}, pages)
+
+	// backwards from the last page
+	lqc.Cursor = last.PrevCursor
+	res, err := r.ReadAllByCursor(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"03", "02"}, postIDs(res.Items))
+	assert.NotEmpty(t, res.NextCursor)
+
+	lqc.Cursor = res.PrevCursor
+	res, err = r.ReadAllByCursor(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"05", "04"}, postIDs(res.Items))
+
+	lqc.Cursor = res.PrevCursor
+	res, err = r.ReadAllByCursor(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"07", "06"}, postIDs(res.Items))
+	assert.Empty(t, res.PrevCursor)
+
+	// new records do not shift the next page
+	lqc.Cursor = res.NextCursor
+	require.NoError(t, r.Create(ctx, &post{ID: "08", Author: "a", CreatedAt: now.Add(time.Hour)}))
+	res, err = r.ReadAllByCursor(ctx, lqc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"05", "04"}, postIDs(res.Items))
+}
+
+func TestReadAllByCursorMultiColumns(t *testing.T) {
+	db := dbtest.New(t, &post{})
+	r := repoutil.NewRepo[post](db)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	require.NoError(t, r.CreateInBatches(ctx, []post{
+		{ID: "1", Author: "b", CreatedAt: now},
+		{ID: "2", Author: "a", CreatedAt: now},
+		{ID: "3", Author: "b", CreatedAt: now.Add(time.Minute)},
+		{ID: "4", Author: "a", CreatedAt: now.Add(time.Minute)},
+		{ID: "5", Author: "a", CreatedAt: now},
+	}, 10))
+
+	lqc := &repoutil.ListQueryCondition{PerPage: 2, Sort: "+author,-created_at", Filter: []any{map[string]any{"id__notexact": "5"}}}
+	ids := []string{}
+	for {
+		res, err := r.ReadAllByCursor(ctx, lqc)
+		require.NoError(t, err)
+		ids = append(ids, postIDs(res.Items)...)
+		if res.NextCursor == "" {
+			break
+		}
+		lqc.Cursor = res.NextCursor
+	}
+	assert.Equal(t, []string{"4", "2", "3", "1"}, ids)
+
+	// default sorting by primary key
+	res, err := r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, postIDs(res.Items))
+	res, err = r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 3, Cursor: res.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4", "5"}, postIDs(res.Items))
+	assert.Empty(t, res.NextCursor)
+}
+
+func TestReadAllByCursorInvalid(t *testing.T) {
+	db := dbtest.New(t, &post{})
+	r := repoutil.NewRepo[post](db)
+	ctx := context.Background()
+	require.NoError(t, r.CreateInBatches(ctx, []post{{ID: "1"}, {ID: "2"}}, 10))
+
+	_, err := r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 1, Sort: "-unknown"})
+	assert.Error(t, err)
+
+	_, err = r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 1, Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, repoutil.ErrInvalidCursor)
+
+	// the cursor is bound to the sorting
+	res, err := r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 1, Sort: "-id"})
+	require.NoError(t, err)
+	_, err = r.ReadAllByCursor(ctx, &repoutil.ListQueryCondition{PerPage: 1, Sort: "+id", Cursor: res.NextCursor})
+	assert.ErrorIs(t, err, repoutil.ErrInvalidCursor)
+}
+
+func postIDs(list []post) []string {
+	l := make([]string, 0, len(list))
+	for _, p := range list {
+		l = append(l, p.ID)
+	}
+	return l
+}
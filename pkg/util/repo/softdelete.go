@@ -0,0 +1,230 @@
+package repoutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNotSoftDeletable is returned when restoring or purging a model without DeletedAt field
+var ErrNotSoftDeletable = errors.New("repoutil: model does not support soft delete")
+
+// DeletedAt enables soft delete for the model having it, like gorm.DeletedAt.
+// Unlike gorm.DeletedAt, it is stored as unix milliseconds and zero means not deleted,
+// so the column can be NOT NULL and a part of unique indexes, e.g. (email, deleted_at)
+// allows reusing the email of deleted records.
+type DeletedAt int64
+
+// Time returns the deletion time, zero if not deleted
+func (n DeletedAt) Time() time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(n))
+}
+
+// Deleted checks whether the record is soft deleted
+func (n DeletedAt) Deleted() bool {
+	return n != 0
+}
+
+// Scan implements the sql.Scanner interface
+func (n *DeletedAt) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*n = 0
+	case int64:
+		*n = DeletedAt(v)
+	case []byte:
+		var i int64
+		if _, err := fmt.Sscan(string(v), &i); err != nil {
+			return err
+		}
+		*n = DeletedAt(i)
+	default:
+		return fmt.Errorf("repoutil: cannot scan %T into DeletedAt", value)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (n DeletedAt) Value() (driver.Value, error) {
+	return int64(n), nil
+}
+
+// MarshalJSON returns the deletion time, or null if not deleted
+func (n DeletedAt) MarshalJSON() ([]byte, error) {
+	if n == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Time())
+}
+
+// UnmarshalJSON parses the deletion time or null
+func (n *DeletedAt) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*n = 0
+		return nil
+	}
+	var t time.Time
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	*n = DeletedAt(t.UnixMilli())
+	return nil
+}
+
+// QueryClauses filters out the soft deleted records from queries
+func (DeletedAt) QueryClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteQueryClause{field: f}}
+}
+
+// UpdateClauses prevents updating the soft deleted records
+func (DeletedAt) UpdateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteUpdateClause{field: f}}
+}
+
+// DeleteClauses turns deleting into updating the deletion time
+func (DeletedAt) DeleteClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{softDeleteDeleteClause{field: f}}
+}
+
+type softDeleteQueryClause struct {
+	field *schema.Field
+}
+
+func (sd softDeleteQueryClause) Name() string               { return "" }
+func (sd softDeleteQueryClause) Build(clause.Builder)       {}
+func (sd softDeleteQueryClause) MergeClause(*clause.Clause) {}
+
+func (sd softDeleteQueryClause) ModifyStatement(stmt *gorm.Statement) {
+	if _, ok := stmt.Clauses["soft_delete_enabled"]; ok || stmt.Unscoped {
+		return
+	}
+
+	// wrap the existing OR conditions, so the soft delete condition applies to all of them, same as gorm.DeletedAt
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) >= 1 {
+			for _, expr := range where.Exprs {
+				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
+					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
+					c.Expression = where
+					stmt.Clauses["WHERE"] = c
+					break
+				}
+			}
+		}
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: sd.field.DBName}, Value: 0},
+	}})
+	stmt.Clauses["soft_delete_enabled"] = clause.Clause{}
+}
+
+type softDeleteUpdateClause struct {
+	field *schema.Field
+}
+
+func (sd softDeleteUpdateClause) Name() string               { return "" }
+func (sd softDeleteUpdateClause) Build(clause.Builder)       {}
+func (sd softDeleteUpdateClause) MergeClause(*clause.Clause) {}
+
+func (sd softDeleteUpdateClause) ModifyStatement(stmt *gorm.Statement) {
+	if stmt.SQL.Len() == 0 && !stmt.Unscoped {
+		softDeleteQueryClause(sd).ModifyStatement(stmt)
+	}
+}
+
+type softDeleteDeleteClause struct {
+	field *schema.Field
+}
+
+func (sd softDeleteDeleteClause) Name() string               { return "" }
+func (sd softDeleteDeleteClause) Build(clause.Builder)       {}
+func (sd softDeleteDeleteClause) MergeClause(*clause.Clause) {}
+
+func (sd softDeleteDeleteClause) ModifyStatement(stmt *gorm.Statement) {
+	if stmt.SQL.Len() > 0 || stmt.Unscoped {
+		return
+	}
+
+	deletedAt := DeletedAt(stmt.DB.NowFunc().UnixMilli())
+	stmt.AddClause(clause.Set{{Column: clause.Column{Name: sd.field.DBName}, Value: deletedAt}})
+	stmt.SetColumn(sd.field.DBName, deletedAt, true)
+
+	// delete by the primary keys of the given record(s), same as gorm.DeletedAt
+	if stmt.Schema != nil {
+		_, queryValues := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+		if len(values) > 0 {
+			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+		}
+
+		if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
+			_, queryValues = schema.GetIdentityFieldValuesMap(stmt.Context, reflect.ValueOf(stmt.Model), stmt.Schema.PrimaryFields)
+			column, values = schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+			if len(values) > 0 {
+				stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+			}
+		}
+	}
+
+	softDeleteQueryClause(sd).ModifyStatement(stmt)
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.Build(stmt.DB.Callback().Update().Clauses...)
+}
+
+// ReadWithDeleted gets a record by conditions, including the soft deleted ones
+func (d *Repo[T]) ReadWithDeleted(ctx context.Context, output *T, conds ...any) error {
+	return d.scoped(ctx).Unscoped().First(output, parseConds(conds)...).Error
+}
+
+// Restore restores the soft deleted records that match given conditions
+func (d *Repo[T]) Restore(ctx context.Context, conds ...any) error {
+	col, err := d.deletedAtColumn()
+	if err != nil {
+		return err
+	}
+
+	db := d.scoped(ctx).Unscoped().Model(new(T)).Where(d.quoteCol(col) + " <> 0")
+	if conds = parseConds(conds); len(conds) > 0 {
+		db = db.Where(conds[0], conds[1:]...)
+	}
+	return db.Update(col, DeletedAt(0)).Error
+}
+
+// Purge permanently deletes the records soft deleted before the given time, returns the number of deleted records
+func (d *Repo[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	col, err := d.deletedAtColumn()
+	if err != nil {
+		return 0, err
+	}
+
+	db := d.scoped(ctx).Unscoped().
+		Where(d.quoteCol(col)+" <> 0 AND "+d.quoteCol(col)+" < ?", before.UnixMilli()).
+		Delete(new(T))
+	return db.RowsAffected, db.Error
+}
+
+// deletedAtColumn returns the column of the DeletedAt field
+func (d *Repo[T]) deletedAtColumn() (string, error) {
+	stmt := &gorm.Statement{DB: d.GDB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	for _, f := range stmt.Schema.Fields {
+		if f.FieldType == reflect.TypeOf(DeletedAt(0)) && f.DBName != "" {
+			return f.DBName, nil
+		}
+	}
+	return "", ErrNotSoftDeletable
+}
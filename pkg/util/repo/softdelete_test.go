@@ -0,0 +1,88 @@
+package repoutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type account struct {
+	ID        string             `gorm:"primaryKey"`
+	Email     string             `gorm:"uniqueIndex:uix_accounts_email,priority:1"`
+	DeletedAt repoutil.DeletedAt `gorm:"not null;default:0;uniqueIndex:uix_accounts_email,priority:2"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	db := dbtest.New(t, &account{})
+	r := repoutil.NewRepo[account](db)
+	ctx := context.Background()
+
+	require.NoError(t, r.CreateInBatches(ctx, []account{{ID: "1", Email: "odin@asgard"}, {ID: "2", Email: "thor@asgard"}}, 10))
+	require.NoError(t, r.Delete(ctx, "1"))
+
+	// soft deleted records are hidden
+	assert.ErrorIs(t, r.ReadByID(ctx, &account{}, "1"), gorm.ErrRecordNotFound)
+	assertCount(t, r, 1)
+	existed, err := r.Exist(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, existed)
+	require.NoError(t, r.Update(ctx, map[string]any{"email": "hacked"}, "1"))
+
+	rec := &account{}
+	require.NoError(t, r.ReadWithDeleted(ctx, rec, "1"))
+	assert.Equal(t, "odin@asgard", rec.Email)
+	assert.True(t, rec.DeletedAt.Deleted())
+	assert.WithinDuration(t, time.Now(), rec.DeletedAt.Time(), time.Minute)
+
+	// the email of deleted records can be reused, but not the active ones
+	require.NoError(t, r.Create(ctx, &account{ID: "3", Email: "odin@asgard"}))
+	assert.Error(t, r.Create(ctx, &account{ID: "4", Email: "thor@asgard"}))
+
+	// restoring conflicts with the reused email
+	assert.Error(t, r.Restore(ctx, "1"))
+	require.NoError(t, r.Delete(ctx, "3"))
+	require.NoError(t, r.Restore(ctx, "1"))
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.False(t, rec.DeletedAt.Deleted())
+	assertCount(t, r, 2)
+
+	// purge the records deleted before the given time only
+	n, err := r.Purge(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = r.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	assert.ErrorIs(t, r.ReadWithDeleted(ctx, rec, "3"), gorm.ErrRecordNotFound)
+	assertCount(t, r, 2)
+
+	// models without DeletedAt
+	notes := repoutil.NewRepo[note](dbtest.New(t, &note{}))
+	assert.ErrorIs(t, notes.Restore(ctx, "1"), repoutil.ErrNotSoftDeletable)
+	_, err = notes.Purge(ctx, time.Now())
+	assert.ErrorIs(t, err, repoutil.ErrNotSoftDeletable)
+}
+
+func TestDeletedAtJSON(t *testing.T) {
+	data, err := json.Marshal(repoutil.DeletedAt(0))
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	data, err = json.Marshal(repoutil.DeletedAt(now.UnixMilli()))
+	require.NoError(t, err)
+
+	var d repoutil.DeletedAt
+	require.NoError(t, json.Unmarshal(data, &d))
+	assert.True(t, now.Equal(d.Time()))
+	require.NoError(t, json.Unmarshal([]byte("null"), &d))
+	assert.False(t, d.Deleted())
+}
@@ -0,0 +1,95 @@
+package repoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// ErrMissingTenant is returned when querying a tenant scoped table without tenant in the context
+var ErrMissingTenant = errors.New("repoutil: tenant is required for this query")
+
+type tenantCtxKey struct{}
+
+type tenantCtx struct {
+	id   string
+	skip bool
+}
+
+// WithTenant returns a copy of ctx which scopes all tenant aware queries to the given tenant ID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantCtx{id: tenantID})
+}
+
+// WithoutTenant returns a copy of ctx which explicitly allows tenant aware queries to cross tenants.
+// Use with care, e.g. for system jobs or looking up the memberships of a user.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantCtx{skip: true})
+}
+
+// TenantFromContext returns the tenant ID carried by ctx, if any
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tc, ok := ctx.Value(tenantCtxKey{}).(tenantCtx)
+	if !ok || tc.skip || tc.id == "" {
+		return "", false
+	}
+	return tc.id, true
+}
+
+func skipTenant(ctx context.Context) bool {
+	tc, ok := ctx.Value(tenantCtxKey{}).(tenantCtx)
+	return ok && tc.skip
+}
+
+// scoped returns the db session for the given context, filtered by the tenant column if the repo is tenant aware.
+// The query fails with ErrMissingTenant when the context carries neither a tenant nor the WithoutTenant flag.
+func (d *Repo[T]) scoped(ctx context.Context) *gorm.DB {
+	db := d.DB(ctx)
+	if d.TenantColumn == "" || skipTenant(ctx) {
+		return db
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return db
+	}
+	return db.Where(d.quoteCol(d.TenantColumn)+" = ?", tenantID)
+}
+
+// setTenant fills the tenant column of the given record(s) with the tenant from the context
+func (d *Repo[T]) setTenant(ctx context.Context, value any) error {
+	if d.TenantColumn == "" || skipTenant(ctx) {
+		return nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrMissingTenant
+	}
+
+	stmt := &gorm.Statement{DB: d.GDB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(d.TenantColumn)
+	if field == nil {
+		return fmt.Errorf("repoutil: tenant column %q not found in %s", d.TenantColumn, stmt.Schema.Name)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(ctx, reflect.Indirect(rv.Index(i)), tenantID); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return field.Set(ctx, rv, tenantID)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repoutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type note struct {
+	ID       string `gorm:"primaryKey"`
+	TenantID string
+	Content  string
+}
+
+func TestTenantRepo(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	r := repoutil.NewTenantRepo[note](db, "tenant_id")
+	ctxA := repoutil.WithTenant(context.Background(), "a")
+	ctxB := repoutil.WithTenant(context.Background(), "b")
+
+	// tenant is required
+	assert.ErrorIs(t, r.Create(context.Background(), &note{ID: "0"}), repoutil.ErrMissingTenant)
+	assert.ErrorIs(t, r.ReadAll(context.Background(), &[]note{}), repoutil.ErrMissingTenant)
+
+	// tenant column is filled automatically, even if the input says otherwise
+	n := &note{ID: "1", TenantID: "b", Content: "from a"}
+	assert.NoError(t, r.Create(ctxA, n))
+	assert.Equal(t, "a", n.TenantID)
+	assert.NoError(t, r.CreateInBatches(ctxB, []note{{ID: "2"}, {ID: "3"}}, 10))
+
+	var list []note
+	assert.NoError(t, r.ReadAll(ctxA, &list))
+	assert.Len(t, list, 1)
+	assert.NoError(t, r.ReadAll(ctxB, &list))
+	assert.Len(t, list, 2)
+	assert.Equal(t, "b", list[0].TenantID)
+
+	// cannot read, update or delete records of other tenants
+	assert.ErrorIs(t, r.ReadByID(ctxB, &note{}, "1"), gorm.ErrRecordNotFound)
+	existed, err := r.Exist(ctxB, "1")
+	assert.NoError(t, err)
+	assert.False(t, existed)
+
+	assert.NoError(t, r.Update(ctxB, map[string]any{"content": "hacked"}, "1"))
+	assert.NoError(t, r.Delete(ctxB, "1"))
+	rec := &note{}
+	assert.NoError(t, r.ReadByID(ctxA, rec, "1"))
+	assert.Equal(t, "from a", rec.Content)
+
+	// crossing tenants must be explicit
+	var count int64
+	assert.NoError(t, r.Count(repoutil.WithoutTenant(context.Background()), &count))
+	assert.EqualValues(t, 3, count)
+
+	// shared tables are not affected
+	shared := repoutil.NewRepo[note](db)
+	assert.NoError(t, shared.Count(context.Background(), &count))
+	assert.EqualValues(t, 3, count)
+}
@@ -0,0 +1,66 @@
+package repoutil
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type timeoutCtxKey struct{}
+
+// timeoutCancelKey holds the cancel func of the query context in the statement settings
+const timeoutCancelKey = "repoutil:timeout_cancel"
+
+// WithTimeout returns a copy of ctx which overrides the default deadline of each query, see UseTimeout.
+// Zero or negative duration disables the deadline, e.g. for long running reports.
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutCtxKey{}, timeout)
+}
+
+// UseTimeout sets a deadline on each query of db, the given default or the one of the query context if any, see WithTimeout.
+// The deadline applies to each statement separately, not to the whole transaction.
+// Row & Rows queries are not affected since the rows are scanned after the statement returns.
+func UseTimeout(db *gorm.DB, timeout time.Duration) error {
+	before := func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		d := timeout
+		if v, ok := ctx.Value(timeoutCtxKey{}).(time.Duration); ok {
+			d = v
+		}
+		if d <= 0 {
+			return
+		}
+		// the original context is restored afterward, the statement may be reused by chained calls
+		qctx, cancel := context.WithTimeout(ctx, d)
+		tx.Statement.Context = qctx
+		tx.InstanceSet(timeoutCancelKey, func() {
+			cancel()
+			tx.Statement.Context = ctx
+		})
+	}
+	after := func(tx *gorm.DB) {
+		if v, ok := tx.InstanceGet(timeoutCancelKey); ok {
+			v.(func())()
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:begin_transaction").Register("repoutil:timeout_before_create", before),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("repoutil:timeout_after_create", after),
+		cb.Query().Before("gorm:query").Register("repoutil:timeout_before_query", before),
+		cb.Query().After("gorm:after_query").Register("repoutil:timeout_after_query", after),
+		cb.Update().Before("gorm:begin_transaction").Register("repoutil:timeout_before_update", before),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("repoutil:timeout_after_update", after),
+		cb.Delete().Before("gorm:begin_transaction").Register("repoutil:timeout_before_delete", before),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("repoutil:timeout_after_delete", after),
+		cb.Raw().Before("gorm:raw").Register("repoutil:timeout_before_raw", before),
+		cb.Raw().After("gorm:raw").Register("repoutil:timeout_after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
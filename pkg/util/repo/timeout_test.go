@@ -0,0 +1,66 @@
+package repoutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+// slowCond takes seconds to evaluate in SQLite, nothing matches
+const slowCond = "EXISTS (WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c LIMIT 100000000) SELECT 1 FROM c WHERE x < 0)"
+
+func TestUseTimeout(t *testing.T) {
+	db := dbtest.New(t, &note{})
+	require.NoError(t, repoutil.UseTimeout(db, 50*time.Millisecond))
+	r := repoutil.NewRepo[note](db)
+	ctx := context.Background()
+	require.NoError(t, r.Create(ctx, &note{ID: "1", TenantID: "a", Content: "a"}))
+
+	// the default deadline
+	var count int64
+	start := time.Now()
+	err := r.Count(ctx, &count, slowCond+" OR id = ?", "1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// overridden per call
+	err = r.Count(repoutil.WithTimeout(ctx, 10*time.Millisecond), &count, slowCond+" OR id = ?", "1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the deadline applies to each query, not the whole context
+	fast := repoutil.WithTimeout(ctx, 200*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, r.Count(fast, &count))
+		assert.EqualValues(t, 1, count)
+	}
+
+	// the statement of chained calls is reusable after the deadline is released
+	q := r.DB(ctx).Model(&note{}).Where("tenant_id = ?", "a")
+	require.NoError(t, q.Count(&count).Error)
+	rec := note{}
+	require.NoError(t, q.Take(&rec).Error)
+	assert.Equal(t, "1", rec.ID)
+
+	// writes & raw queries as well
+	require.NoError(t, r.Update(ctx, map[string]any{"content": "b"}, "id = ?", "1"))
+	require.Error(t, db.WithContext(repoutil.WithTimeout(ctx, 10*time.Millisecond)).Exec("DELETE FROM notes WHERE "+slowCond).Error)
+	require.NoError(t, r.Delete(ctx, "id = ?", "1"))
+}
+
+func TestContextCancellation(t *testing.T) {
+	db := dbtest.New(t, &profile{})
+	r := repoutil.NewCachedRepo(repoutil.NewRepo[profile](db), nil, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, r.Create(ctx, &profile{ID: "1", Email: "odin@asgard.sky"}))
+	cancel()
+
+	assert.ErrorIs(t, r.ReadBy(ctx, &profile{}, "email", "odin@asgard.sky"), context.Canceled)
+	assert.ErrorIs(t, r.Update(ctx, map[string]any{"email": "thor@asgard.sky"}, "id = ?", "1"), context.Canceled)
+}
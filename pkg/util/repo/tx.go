@@ -0,0 +1,105 @@
+package repoutil
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type txCtxKey struct{}
+
+// WithTx returns a copy of ctx which carries the given transaction.
+// All Repo methods called with the returned context run in the transaction.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txCtxKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txCtxKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// Transaction runs fn in a transaction, the context passed to fn carries the transaction, see WithTx.
+// If ctx already carries a transaction, a nested one is created using savepoints,
+// so only the changes of fn are rolled back when it returns an error.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		db = tx
+	}
+	return RunTx(ctx, db, func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	}, opts...)
+}
+
+// commitHooks holds the functions to run once a transaction is committed, by the connection of the transaction
+var commitHooks sync.Map
+
+type hooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// RunTx runs fn in a transaction of db like gorm.DB.Transaction, nested ones use savepoints.
+// Once the outermost transaction is committed, the functions registered by AfterCommit are run.
+func RunTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	var h *hooks
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		conn := tx.Statement.ConnPool
+		if _, ok := commitHooks.Load(conn); ok {
+			// nested, the hooks run along with the outermost transaction
+			return fn(tx)
+		}
+		h = &hooks{}
+		commitHooks.Store(conn, h)
+		defer commitHooks.Delete(conn)
+		return fn(tx)
+	}, opts...)
+	if err != nil || h == nil {
+		return err
+	}
+	for _, fn := range h.fns {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit runs fn once the transaction of db is committed, or right away if db is not a transaction.
+// The hooks are dropped if the transaction is rolled back.
+// Transactions not started by RunTx or Transaction cannot be tracked, fn is run right away as well.
+func AfterCommit(db *gorm.DB, fn func()) {
+	if InTx(db) {
+		if v, ok := commitHooks.Load(db.Statement.ConnPool); ok {
+			h := v.(*hooks)
+			h.mu.Lock()
+			h.fns = append(h.fns, fn)
+			h.mu.Unlock()
+			return
+		}
+	}
+	fn()
+}
+
+// DB returns the db session for the given context, which is the transaction carried by ctx if any, see WithTx.
+// A repo already bound to a transaction keeps using it.
+// Use it instead of GDB in custom queries to take part in the transaction.
+func (d *Repo[T]) DB(ctx context.Context) *gorm.DB {
+	return Conn(ctx, d.GDB)
+}
+
+// Conn returns the session of db for the given context, which is the transaction carried by ctx if any,
+// unless db is already a transaction. See Repo.DB, for the packages using gorm.DB directly.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok && !InTx(db) {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// InTx checks whether the given db session is a transaction
+func InTx(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
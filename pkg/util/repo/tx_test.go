@@ -0,0 +1,77 @@
+package repoutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+func TestTransaction(t *testing.T) {
+	db := dbtest.New(t, &note{}, &post{})
+	notes := repoutil.NewRepo[note](db)
+	posts := repoutil.NewRepo[post](db)
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	// repos pick up the transaction from the context
+	err := repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		_, ok := repoutil.TxFromContext(ctx)
+		assert.True(t, ok)
+		require.NoError(t, notes.Create(ctx, &note{ID: "1"}))
+		require.NoError(t, posts.Create(ctx, &post{ID: "1"}))
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	assertCount(t, notes, 0)
+	assertCount(t, posts, 0)
+
+	// nested transactions roll back to their savepoints only
+	err = repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		require.NoError(t, notes.Create(ctx, &note{ID: "1"}))
+
+		err := repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, posts.Create(ctx, &post{ID: "1"}))
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+
+		return repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+			return posts.Create(ctx, &post{ID: "2"})
+		})
+	})
+	require.NoError(t, err)
+	assertCount(t, notes, 1)
+	res, err := posts.ReadAllByCondition(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, postIDs(res.Items))
+
+	// a repo bound to a transaction keeps using it, even if the context carries another one
+	err = repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		tx, _ := repoutil.TxFromContext(ctx)
+		err := tx.Transaction(func(sp *gorm.DB) error {
+			assert.True(t, repoutil.InTx(sp))
+			require.NoError(t, repoutil.NewRepo[note](sp).Create(ctx, &note{ID: "2"}))
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+		return notes.Create(ctx, &note{ID: "3"})
+	})
+	require.NoError(t, err)
+	assertCount(t, notes, 2)
+	assert.ErrorIs(t, notes.ReadByID(ctx, &note{}, "2"), gorm.ErrRecordNotFound)
+	assert.False(t, repoutil.InTx(db))
+}
+
+func assertCount[T any](t *testing.T, r *repoutil.Repo[T], want int64) {
+	t.Helper()
+	var count int64
+	require.NoError(t, r.Count(context.Background(), &count))
+	assert.Equal(t, want, count)
+}
@@ -8,13 +8,32 @@ type ListCondition[T any] struct {
 	PerPage int
 	// Field names for sorting, separated by comma.
 	// The direction is specified by `+` (ASC) and `-` (DESC) prefix, eg: `+name,-age`
-	// WARNING: SQL Injection vulnerability! User input must be validated before sending to database
+	// WARNING: SQL Injection vulnerability! User input must be validated before sending to database, see httputil.ReqListQuery
 	Sort string
-	// Whether to count the total records. If not, the returning `count` number will always be zero.
+	// Whether to count the total records. If not, the returning total will be zero for paginated queries.
 	Count bool
+	// Full-text search query, see Repo.Searcher
+	Search string
+	// Opaque cursor of the page to fetch, see Repo.ReadAllByCursor
+	Cursor string
 	// Custom filter type
 	Filter T
 }
 
 // ListQueryCondition represents a generic type for listing and filtering data
 type ListQueryCondition ListCondition[[]any]
+
+// ListResult represents a page of records returned by listing
+type ListResult[T any] struct {
+	// The records of the current page
+	Items []T
+	// Total number of records that match the filter, see ListCondition.Count
+	Total int64
+	// Current page number, starts from 1. Zero means not paginated.
+	Page int
+	// Number of records per page. Zero means not paginated.
+	PerPage int
+	// Cursors of the next & previous pages, empty if there is none. Only for cursor pagination.
+	NextCursor string
+	PrevCursor string
+}
@@ -0,0 +1,191 @@
+package repoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrUpsertOtherTenant is returned when the record conflicting with the upserted one belongs to another tenant,
+// e.g. on MySQL which ignores the conflict columns. Nothing is changed.
+var ErrUpsertOtherTenant = errors.New("repoutil: the conflicting record belongs to another tenant")
+
+// Upsert creates the record, or updates the existing one having the same values of the conflict columns, e.g. `email`.
+// Only the given update columns are updated, all columns except the primary key & creation time if omitted.
+// The version of versioned models is never overwritten by the input, it is increased on update instead.
+// The input is reloaded afterward, so it holds the primary key of the existing record if updated.
+//
+// The conflict columns must match a whole unique index, PostgreSQL & SQLite fail otherwise.
+// They are completed with the tenant column of tenant aware repos, and the DeletedAt column of soft deletable models,
+// e.g. `email` of a tenant aware & soft deletable model requires the unique index (email, tenant_id, deleted_at).
+// MySQL ignores the conflict columns and checks all unique indexes instead,
+// the upsert is rolled back with ErrUpsertOtherTenant if the conflicting record belongs to another tenant.
+func (d *Repo[T]) Upsert(ctx context.Context, input *T, conflictColumns []string, updateColumns ...string) error {
+	if len(conflictColumns) == 0 {
+		return fmt.Errorf("repoutil: conflict columns are required for upsert")
+	}
+	s, err := d.parseSchema()
+	if err != nil {
+		return err
+	}
+	conflictFields, err := lookUpFields(s, conflictColumns)
+	if err != nil {
+		return err
+	}
+	if conflictFields, err = d.completeConflict(s, conflictFields); err != nil {
+		return err
+	}
+	if err := d.setTenant(ctx, input); err != nil {
+		return err
+	}
+
+	onConflict := clause.OnConflict{}
+	for _, f := range conflictFields {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: f.DBName})
+	}
+	versionCol, _ := d.versionColumn()
+	if versionCol != "" {
+		onConflict.DoUpdates = clause.Set{{
+			Column: clause.Column{Name: versionCol},
+			Value:  gorm.Expr("? + 1", clause.Column{Table: clause.CurrentTable, Name: versionCol}),
+		}}
+	}
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		updateFields, err := lookUpFields(s, updateColumns)
+		if err != nil {
+			return err
+		}
+		cols := make([]string, 0, len(updateFields))
+		for _, f := range updateFields {
+			// same as Update, the primary key is never changed
+			if !f.PrimaryKey && f.DBName != versionCol {
+				cols = append(cols, f.DBName)
+			}
+		}
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.AssignmentColumns(cols)...)
+	}
+
+	upsert := func(ctx context.Context) error {
+		db := d.DB(ctx)
+		if versionCol != "" {
+			// excluded from UpdateAll, a new record starts from the default version
+			db = db.Omit(versionCol)
+		}
+		if err := db.Clauses(onConflict).Create(input).Error; err != nil {
+			return err
+		}
+
+		// the primary key of the input is not the existing one if updated, e.g. generated by hooks
+		db = d.scoped(ctx)
+		rv := reflect.ValueOf(input).Elem()
+		for _, f := range conflictFields {
+			v, _ := f.ValueOf(ctx, rv)
+			db = db.Where(d.quoteCol(f.DBName)+" = ?", v)
+		}
+		rec := new(T)
+		if err := db.Take(rec).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && d.TenantColumn != "" {
+				return ErrUpsertOtherTenant
+			}
+			return err
+		}
+		*input = *rec
+		return nil
+	}
+	if d.TenantColumn == "" || skipTenant(ctx) {
+		return upsert(ctx)
+	}
+	// nested, so the record of another tenant is restored
+	return Transaction(WithTx(ctx, d.DB(ctx)), d.GDB, upsert)
+}
+
+// completeConflict appends the tenant & DeletedAt columns to the conflict columns of Upsert if missing
+func (d *Repo[T]) completeConflict(s *schema.Schema, fields []*schema.Field) ([]*schema.Field, error) {
+	var cols []string
+	if d.TenantColumn != "" {
+		cols = append(cols, d.TenantColumn)
+	}
+	if col, err := d.deletedAtColumn(); err == nil {
+		cols = append(cols, col)
+	}
+	extra, err := lookUpFields(s, cols)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range extra {
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// UpdateMany updates the records matching the conditions, returns the number of affected records.
+// Conditions are required unless the repo is tenant aware, see gorm.ErrMissingWhereClause.
+func (d *Repo[T]) UpdateMany(ctx context.Context, updates any, conds ...any) (int64, error) {
+	db := d.scoped(ctx).Model(new(T))
+	conds = parseConds(conds)
+	if len(conds) > 0 {
+		db = db.Where(conds[0], conds[1:]...)
+	}
+	db = db.Omit("id").Updates(updates)
+	return db.RowsAffected, db.Error
+}
+
+// FindOrCreate reads the first record matching the conditions into output, or creates output if there is none.
+// Returns whether the record is created. If another request creates the record at the same time,
+// e.g. failing a unique index, the record is read again.
+func (d *Repo[T]) FindOrCreate(ctx context.Context, output *T, conds ...any) (bool, error) {
+	existing := new(T)
+	err := d.Read(ctx, existing, conds...)
+	if err == nil {
+		*output = *existing
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	// nested, so a failed insert does not break the transaction of ctx if any
+	createErr := Transaction(ctx, d.GDB, func(ctx context.Context) error {
+		return d.Create(ctx, output)
+	})
+	if createErr == nil {
+		return true, nil
+	}
+	existing = new(T)
+	if err := d.Read(ctx, existing, conds...); err != nil {
+		return false, createErr
+	}
+	*output = *existing
+	return false, nil
+}
+
+func (d *Repo[T]) parseSchema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: d.GDB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// lookUpFields returns the fields of the given column or field names, only the columns of the model are allowed
+func lookUpFields(s *schema.Schema, columns []string) ([]*schema.Field, error) {
+	fields := make([]*schema.Field, 0, len(columns))
+	for _, col := range columns {
+		f := s.LookUpField(col)
+		if f == nil || f.DBName == "" {
+			return nil, fmt.Errorf("repoutil: unknown column %q of %s", col, s.Name)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
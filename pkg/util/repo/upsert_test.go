@@ -0,0 +1,183 @@
+package repoutil_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+var employeeSeq atomic.Int64
+
+type employee struct {
+	ID        string `gorm:"primaryKey"`
+	TenantID  string
+	Email     string `gorm:"uniqueIndex"`
+	Name      string
+	Title     string
+	CreatedAt time.Time
+}
+
+func (e *employee) BeforeCreate(*gorm.DB) error {
+	if e.ID == "" {
+		e.ID = fmt.Sprint("e", employeeSeq.Add(1))
+	}
+	return nil
+}
+
+func TestUpsert(t *testing.T) {
+	db := dbtest.New(t, &employee{})
+	r := repoutil.NewRepo[employee](db)
+	ctx := context.Background()
+
+	e := &employee{Email: "odin@asgard", Name: "Odin", Title: "King"}
+	require.NoError(t, r.Upsert(ctx, e, []string{"email"}))
+	id, createdAt := e.ID, e.CreatedAt
+
+	// all columns except the primary key & creation time
+	e = &employee{Email: "odin@asgard", Name: "Odin Allfather", Title: "Allfather", CreatedAt: time.Now().Add(time.Hour)}
+	require.NoError(t, r.Upsert(ctx, e, []string{"email"}))
+	assert.Equal(t, id, e.ID)
+	assert.Equal(t, "Allfather", e.Title)
+	assert.WithinDuration(t, createdAt, e.CreatedAt, time.Millisecond)
+
+	// only the given columns, by field or column names
+	e = &employee{ID: "ignored", Email: "odin@asgard", Name: "Wotan", Title: "Wanderer"}
+	require.NoError(t, r.Upsert(ctx, e, []string{"Email"}, "name", "id"))
+	assert.Equal(t, id, e.ID)
+	assert.Equal(t, "Wotan", e.Name)
+	assert.Equal(t, "Allfather", e.Title)
+
+	var count int64
+	require.NoError(t, r.Count(ctx, &count))
+	assert.EqualValues(t, 1, count)
+
+	assert.Error(t, r.Upsert(ctx, &employee{}, nil))
+	assert.Error(t, r.Upsert(ctx, &employee{}, []string{"missing"}))
+	assert.Error(t, r.Upsert(ctx, &employee{Email: "x"}, []string{"email"}, "missing"))
+}
+
+type member struct {
+	ID        string `gorm:"primaryKey"`
+	TenantID  string `gorm:"uniqueIndex:uix_members_email,priority:2"`
+	Email     string `gorm:"uniqueIndex:uix_members_email,priority:1"`
+	Name      string
+	DeletedAt repoutil.DeletedAt `gorm:"not null;default:0;uniqueIndex:uix_members_email,priority:3"`
+}
+
+func TestUpsertTenant(t *testing.T) {
+	db := dbtest.New(t, &member{}, &employee{})
+	r := repoutil.NewTenantRepo[member](db, "tenant_id")
+	ctxA := repoutil.WithTenant(context.Background(), "asgard")
+	ctxB := repoutil.WithTenant(context.Background(), "midgard")
+
+	// the tenant & deleted_at columns complete the unique index
+	require.NoError(t, r.Upsert(ctxA, &member{ID: "1", Email: "odin@asgard", Name: "Odin"}, []string{"email"}))
+	m := &member{ID: "2", Email: "odin@asgard", Name: "Wotan"}
+	require.NoError(t, r.Upsert(ctxA, m, []string{"email"}))
+	assert.Equal(t, "1", m.ID)
+	assert.Equal(t, "Wotan", m.Name)
+
+	// same email in another tenant
+	m = &member{ID: "3", Email: "odin@asgard", Name: "Odin of Midgard"}
+	require.NoError(t, r.Upsert(ctxB, m, []string{"email"}))
+	assert.Equal(t, "3", m.ID)
+	assert.Equal(t, "midgard", m.TenantID)
+
+	// a deleted record is not updated
+	require.NoError(t, r.Delete(ctxA, "1"))
+	m = &member{ID: "4", Email: "odin@asgard", Name: "Odin reborn"}
+	require.NoError(t, r.Upsert(ctxA, m, []string{"email"}))
+	assert.Equal(t, "4", m.ID)
+	deleted := &member{}
+	require.NoError(t, r.ReadWithDeleted(ctxA, deleted, "1"))
+	assert.Equal(t, "Wotan", deleted.Name)
+
+	// the email is unique across tenants, which cannot be the conflict target
+	employees := repoutil.NewTenantRepo[employee](db, "tenant_id")
+	require.NoError(t, employees.Create(ctxA, &employee{ID: "e0", Email: "thor@asgard", Name: "Thor"}))
+	assert.Error(t, employees.Upsert(ctxB, &employee{Email: "thor@asgard", Name: "hacked"}, []string{"email"}))
+	e := &employee{}
+	require.NoError(t, employees.ReadByID(ctxA, e, "e0"))
+	assert.Equal(t, "Thor", e.Name)
+
+	// the transaction is still usable after the failure
+	require.NoError(t, repoutil.Transaction(ctxB, db, func(ctx context.Context) error {
+		assert.Error(t, employees.Upsert(ctx, &employee{Email: "thor@asgard", Name: "hacked"}, []string{"email"}))
+		return r.Upsert(ctx, &member{ID: "5", Email: "thor@asgard"}, []string{"email"})
+	}))
+	assert.NoError(t, r.ReadByID(ctxB, &member{}, "5"))
+}
+
+func TestUpdateMany(t *testing.T) {
+	db := dbtest.New(t, &employee{})
+	r := repoutil.NewRepo[employee](db)
+	ctx := context.Background()
+	require.NoError(t, r.CreateInBatches(ctx, []employee{
+		{Email: "odin@asgard", Title: "god"},
+		{Email: "thor@asgard", Title: "god"},
+		{Email: "frigg@asgard", Title: "goddess"},
+	}, 10))
+
+	n, err := r.UpdateMany(ctx, map[string]any{"title": "aesir", "id": "hacked"}, map[string]any{"title__in": []string{"god"}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	assert.False(t, assertExist(t, r, "id = ?", "hacked"))
+	assert.True(t, assertExist(t, r, "email = ? AND title = ?", "thor@asgard", "aesir"))
+
+	n, err = r.UpdateMany(ctx, map[string]any{"title": "vanir"}, "email = ?", "freyr@vanaheim")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// global updates are not allowed
+	_, err = r.UpdateMany(ctx, map[string]any{"title": "jotunn"})
+	assert.ErrorIs(t, err, gorm.ErrMissingWhereClause)
+}
+
+func TestFindOrCreate(t *testing.T) {
+	db := dbtest.New(t, &employee{})
+	r := repoutil.NewTenantRepo[employee](db, "tenant_id")
+	ctx := repoutil.WithTenant(context.Background(), "asgard")
+
+	e := &employee{Email: "odin@asgard", Name: "Odin"}
+	created, err := r.FindOrCreate(ctx, e, "email = ?", "odin@asgard")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "asgard", e.TenantID)
+
+	found := &employee{Email: "odin@asgard", Name: "Wotan"}
+	created, err = r.FindOrCreate(ctx, found, map[string]any{"email": "odin@asgard"})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, e.ID, found.ID)
+	assert.Equal(t, "Odin", found.Name)
+
+	// the unique index fails in another tenant, which is not readable
+	_, err = r.FindOrCreate(repoutil.WithTenant(context.Background(), "midgard"), &employee{Email: "odin@asgard"}, "email = ?", "odin@asgard")
+	assert.Error(t, err)
+
+	// the transaction is still usable after the failure
+	require.NoError(t, repoutil.Transaction(ctx, db, func(ctx context.Context) error {
+		_, err := r.FindOrCreate(repoutil.WithTenant(ctx, "midgard"), &employee{Email: "odin@asgard"}, "email = ?", "odin@asgard")
+		assert.Error(t, err)
+		_, err = r.FindOrCreate(ctx, &employee{Email: "thor@asgard"}, "email = ?", "thor@asgard")
+		return err
+	}))
+	var count int64
+	require.NoError(t, r.Count(ctx, &count))
+	assert.EqualValues(t, 2, count)
+}
+
+func assertExist(t *testing.T, r *repoutil.Repo[employee], conds ...any) bool {
+	existed, err := r.Exist(context.Background(), conds...)
+	require.NoError(t, err)
+	return existed
+}
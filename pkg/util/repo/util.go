@@ -1,6 +1,7 @@
 package repoutil
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/imdatngo/gowhere"
@@ -13,9 +14,31 @@ func (r *Repo[T]) quoteCol(name string) string {
 	return b.String()
 }
 
-// AddFilter adds filter condition
+// withSearch filters db by the full-text search query if given
+func (d *Repo[T]) withSearch(db *gorm.DB, q string, rank bool) (*gorm.DB, error) {
+	if q == "" {
+		return db, nil
+	}
+	if d.Searcher == nil {
+		return nil, ErrSearchNotSupported
+	}
+	return d.Searcher.Search(db, q, rank), nil
+}
+
+// AddFilter adds filter condition, combined with the existing one using AND
 func (lqc *ListQueryCondition) AddFilter(conds ...any) *ListQueryCondition {
-	lqc.Filter = append(lqc.Filter, conds)
+	conds = parseConds(conds)
+	if len(conds) == 0 {
+		return lqc
+	}
+	existing := parseConds(lqc.Filter)
+	if len(existing) == 0 {
+		lqc.Filter = conds
+		return lqc
+	}
+
+	sql := fmt.Sprintf("(%v) AND (%v)", existing[0], conds[0])
+	lqc.Filter = append(append([]any{sql}, existing[1:]...), conds[1:]...)
 	return lqc
 }
 
@@ -0,0 +1,76 @@
+package repoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotVersioned is returned when updating with version a model without Version field
+var ErrNotVersioned = errors.New("repoutil: model does not support optimistic locking")
+
+// Version enables optimistic locking for the model having it, see Repo.UpdateWithVersion.
+// It starts from 1 and is increased by every versioned update, so the model should not be updated by Repo.Update.
+type Version int64
+
+// VersionConflictError is returned when the record was changed by someone else since the given version
+type VersionConflictError struct {
+	// The version given by the caller
+	Version int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("repoutil: version conflict, the record has changed since version %d", e.Version)
+}
+
+// UpdateWithVersion updates a record by conditions only if its version still equals the given one,
+// then increases the version. It returns VersionConflictError if the version has moved,
+// or gorm.ErrRecordNotFound if no record matches the conditions.
+func (d *Repo[T]) UpdateWithVersion(ctx context.Context, version int64, updates map[string]any, conds ...any) error {
+	col, err := d.versionColumn()
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]any, len(updates)+1)
+	for k, v := range updates {
+		values[k] = v
+	}
+	values[col] = gorm.Expr(d.quoteCol(col) + " + 1")
+
+	db := d.scoped(ctx).Model(new(T))
+	if where := parseConds(conds); len(where) > 0 {
+		db = db.Where(where[0], where[1:]...)
+	}
+	db = db.Where(d.quoteCol(col)+" = ?", version).Omit("id").Updates(values)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected > 0 {
+		return nil
+	}
+
+	if existed, err := d.Exist(ctx, conds...); err != nil {
+		return err
+	} else if !existed {
+		return gorm.ErrRecordNotFound
+	}
+	return &VersionConflictError{Version: version}
+}
+
+// versionColumn returns the column of the Version field
+func (d *Repo[T]) versionColumn() (string, error) {
+	stmt := &gorm.Statement{DB: d.GDB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	for _, f := range stmt.Schema.Fields {
+		if f.FieldType == reflect.TypeOf(Version(0)) && f.DBName != "" {
+			return f.DBName, nil
+		}
+	}
+	return "", ErrNotVersioned
+}
@@ -0,0 +1,69 @@
+package repoutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	repoutil "runar-himmel/pkg/util/repo"
+)
+
+type doc struct {
+	ID      string `gorm:"primaryKey"`
+	Title   string
+	Version repoutil.Version `gorm:"not null;default:1"`
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	db := dbtest.New(t, &doc{}, &note{})
+	r := repoutil.NewRepo[doc](db)
+	ctx := context.Background()
+
+	rec := &doc{ID: "1", Title: "draft"}
+	require.NoError(t, r.Create(ctx, rec))
+	assert.EqualValues(t, 1, rec.Version)
+
+	// both editors loaded version 1, the second one must fail
+	require.NoError(t, r.UpdateWithVersion(ctx, 1, map[string]any{"title": "by alice"}, "1"))
+	err := r.UpdateWithVersion(ctx, 1, map[string]any{"title": "by bob"}, "1")
+	var conflictErr *repoutil.VersionConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	assert.EqualValues(t, 1, conflictErr.Version)
+
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.Equal(t, "by alice", rec.Title)
+	assert.EqualValues(t, 2, rec.Version)
+
+	// the version cannot be overwritten by the updates
+	require.NoError(t, r.UpdateWithVersion(ctx, 2, map[string]any{"title": "final", "version": 100}, "1"))
+	require.NoError(t, r.ReadByID(ctx, rec, "1"))
+	assert.EqualValues(t, 3, rec.Version)
+
+	assert.ErrorIs(t, r.UpdateWithVersion(ctx, 1, map[string]any{"title": "x"}, "2"), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repoutil.NewRepo[note](db).UpdateWithVersion(ctx, 1, map[string]any{"content": "x"}, "1"), repoutil.ErrNotVersioned)
+}
+
+func TestUpsertWithVersion(t *testing.T) {
+	db := dbtest.New(t, &doc{})
+	r := repoutil.NewRepo[doc](db)
+	ctx := context.Background()
+
+	rec := &doc{ID: "1", Title: "draft", Version: 100}
+	require.NoError(t, r.Upsert(ctx, rec, []string{"id"}))
+	assert.EqualValues(t, 1, rec.Version)
+
+	// the stale version of the input does not overwrite the increased one
+	rec = &doc{ID: "1", Title: "by alice", Version: 1}
+	require.NoError(t, r.Upsert(ctx, rec, []string{"id"}))
+	assert.Equal(t, "by alice", rec.Title)
+	assert.EqualValues(t, 2, rec.Version)
+
+	rec = &doc{ID: "1", Title: "by bob", Version: 1}
+	require.NoError(t, r.Upsert(ctx, rec, []string{"id"}, "title", "version"))
+	assert.Equal(t, "by bob", rec.Title)
+	assert.EqualValues(t, 3, rec.Version)
+}
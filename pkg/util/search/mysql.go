@@ -0,0 +1,41 @@
+package searchutil
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mysqlSearcher uses a FULLTEXT index in boolean mode, e.g. `+odin* +asg*`
+type mysqlSearcher struct {
+	idx Index
+}
+
+func (s *mysqlSearcher) Search(db *gorm.DB, q string, rank bool) *gorm.DB {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return db
+	}
+	for i, t := range terms {
+		terms[i] = "+" + t + "*"
+	}
+	query := strings.Join(terms, " ")
+
+	match := fmt.Sprintf("MATCH (%s) AGAINST (? IN BOOLEAN MODE)", strings.Join(quoteColumns(db, s.idx), ", "))
+	db = db.Where(match, query)
+	if rank {
+		db = db.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: match + " DESC", Vars: []any{query}}})
+	}
+	return db
+}
+
+func (s *mysqlSearcher) CreateIndex(db *gorm.DB) error {
+	return db.Exec(fmt.Sprintf("CREATE FULLTEXT INDEX %s ON %s (%s)",
+		quote(db, s.idx.Name), quote(db, s.idx.Table), strings.Join(quoteColumns(db, s.idx), ", "))).Error
+}
+
+func (s *mysqlSearcher) DropIndex(db *gorm.DB) error {
+	return db.Migrator().DropIndex(s.idx.Table, s.idx.Name)
+}
@@ -0,0 +1,50 @@
+package searchutil
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresSearcher uses a GIN index of the tsvector expression, e.g. `odin:* & asg:*`.
+// Punctuation is replaced by spaces before indexing, so emails & phones are split into words like SQLite.
+type postgresSearcher struct {
+	idx Index
+}
+
+// document returns the tsvector expression, which must be the same in queries & the index
+func (s *postgresSearcher) document(db *gorm.DB) string {
+	cols := quoteColumns(db, s.idx)
+	for i, c := range cols {
+		cols[i] = "coalesce(" + c + ", '')"
+	}
+	return fmt.Sprintf("to_tsvector('simple', regexp_replace(%s, '[^[:alnum:]]+', ' ', 'g'))", strings.Join(cols, " || ' ' || "))
+}
+
+func (s *postgresSearcher) Search(db *gorm.DB, q string, rank bool) *gorm.DB {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return db
+	}
+	for i, t := range terms {
+		terms[i] = t + ":*"
+	}
+	query := strings.Join(terms, " & ")
+
+	doc := s.document(db)
+	db = db.Where(doc+" @@ to_tsquery('simple', ?)", query)
+	if rank {
+		db = db.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "ts_rank(" + doc + ", to_tsquery('simple', ?)) DESC", Vars: []any{query}}})
+	}
+	return db
+}
+
+func (s *postgresSearcher) CreateIndex(db *gorm.DB) error {
+	return db.Exec(fmt.Sprintf("CREATE INDEX %s ON %s USING GIN ((%s))", quote(db, s.idx.Name), quote(db, s.idx.Table), s.document(db))).Error
+}
+
+func (s *postgresSearcher) DropIndex(db *gorm.DB) error {
+	return db.Migrator().DropIndex(s.idx.Table, s.idx.Name)
+}
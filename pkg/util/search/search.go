@@ -0,0 +1,72 @@
+package searchutil
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// MaxTerms is the maximum number of terms taken from a search query, the rest are ignored
+const MaxTerms = 10
+
+// Index describes the full-text index over the columns of a table
+type Index struct {
+	// Name of the index, e.g. `ftx_users`. The FTS5 table of SQLite has the same name.
+	Name    string
+	Table   string
+	Columns []string
+}
+
+// Searcher filters & ranks the records of a table by full-text search queries
+type Searcher interface {
+	// Search filters db by the query, all terms must match as prefixes of the words.
+	// The records are ordered by relevance, most relevant first, if rank is true. Other orderings must not be added afterward,
+	// they replace the ranking.
+	Search(db *gorm.DB, q string, rank bool) *gorm.DB
+	// CreateIndex creates the full-text index, for migrations
+	CreateIndex(db *gorm.DB) error
+	// DropIndex drops the full-text index, for migrations
+	DropIndex(db *gorm.DB) error
+}
+
+// New returns the searcher of the dialect of db: MySQL FULLTEXT, PostgreSQL tsvector or SQLite FTS5.
+// SQLite falls back to LIKE matching if the FTS5 table does not exist, e.g. FTS5 is not compiled in.
+func New(db *gorm.DB, idx Index) Searcher {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return &mysqlSearcher{idx: idx}
+	case "postgres":
+		return &postgresSearcher{idx: idx}
+	default:
+		return &sqliteSearcher{idx: idx}
+	}
+}
+
+// Terms splits the query into lowercase words of letters & digits, e.g. `Odin@asgard +62 812` => [odin asgard 62 812].
+// Terms are safe to be embedded in the search syntax of all dialects.
+func Terms(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > MaxTerms {
+		words = words[:MaxTerms]
+	}
+	return words
+}
+
+// quote quotes the identifier for the dialect of db
+func quote(db *gorm.DB, name string) string {
+	b := &strings.Builder{}
+	db.QuoteTo(b, name)
+	return b.String()
+}
+
+// quoteColumns returns the quoted columns of the index
+func quoteColumns(db *gorm.DB, idx Index) []string {
+	cols := make([]string, 0, len(idx.Columns))
+	for _, c := range idx.Columns {
+		cols = append(cols, quote(db, c))
+	}
+	return cols
+}
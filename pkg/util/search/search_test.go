@@ -0,0 +1,122 @@
+package searchutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	searchutil "runar-himmel/pkg/util/search"
+)
+
+type article struct {
+	ID    int
+	Title string
+	Body  string
+}
+
+var articleIndex = searchutil.Index{Name: "ftx_articles", Table: "articles", Columns: []string{"title", "body"}}
+
+// newArticleDB opens a test database holding the articles
+func newArticleDB(t *testing.T) *gorm.DB {
+	db := dbtest.New(t, &article{})
+	require.NoError(t, db.Create([]article{
+		{ID: 1, Title: "Feed the ravens", Body: "Huginn and Muninn fly over Midgard"},
+		{ID: 2, Title: "Ravens", Body: "Ravens of Odin"},
+		{ID: 3, Title: "Mead hall", Body: "Feast in Valhalla with Odin"},
+		{ID: 4, Title: "Bifrost", Body: "odin@asgard.sky"},
+	}).Error)
+	return db
+}
+
+func search(t *testing.T, db *gorm.DB, s searchutil.Searcher, q string, rank bool) []int {
+	ids := []int{}
+	db = s.Search(db.Model(&article{}), q, rank)
+	if !rank {
+		db = db.Order("id")
+	}
+	require.NoError(t, db.Pluck("id", &ids).Error)
+	return ids
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"odin", "asgard", "62", "812"}, searchutil.Terms(" Odin@asgard +62 812 "))
+	assert.Equal(t, []string{"åsa", "thor"}, searchutil.Terms(`"Åsa" -thor*`))
+	assert.Empty(t, searchutil.Terms(" -*' "))
+	assert.Len(t, searchutil.Terms(strings.Repeat("a ", 20)), searchutil.MaxTerms)
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	run := func(t *testing.T, db *gorm.DB, s searchutil.Searcher) {
+		assert.Equal(t, []int{1, 2}, search(t, db, s, "raven", false))
+		assert.Equal(t, []int{2, 3, 4}, search(t, db, s, "ODIN", false))
+		assert.Equal(t, []int{3}, search(t, db, s, "odin val", false), "all terms must match")
+		assert.Equal(t, []int{4}, search(t, db, s, "asgard.sky", false))
+		assert.Empty(t, search(t, db, s, "loki", false))
+		assert.Equal(t, []int{1, 2, 3, 4}, search(t, db, s, " * ", false), "no terms means no filter")
+
+		ranked := search(t, db, s, "ravens", true)
+		assert.ElementsMatch(t, []int{1, 2}, ranked)
+		assert.Equal(t, 2, ranked[0], "the most relevant first")
+	}
+
+	t.Run("like", func(t *testing.T) {
+		db := newArticleDB(t)
+		run(t, db, searchutil.New(db, articleIndex))
+	})
+
+	t.Run("fts5", func(t *testing.T) {
+		db := newArticleDB(t)
+		s := searchutil.New(db, articleIndex)
+		require.NoError(t, s.CreateIndex(db))
+		if !db.Migrator().HasTable(articleIndex.Name) {
+			t.Skip("FTS5 is not compiled in, run with -tags sqlite_fts5")
+		}
+		run(t, db, s)
+
+		// the index is kept in sync
+		require.NoError(t, db.Model(&article{ID: 4}).Update("body", "Rainbow bridge").Error)
+		require.NoError(t, db.Delete(&article{ID: 3}).Error)
+		require.NoError(t, db.Create(&article{ID: 5, Title: "Odin", Body: "Allfather"}).Error)
+		assert.Equal(t, []int{2, 5}, search(t, db, s, "odin", false))
+		assert.Equal(t, []int{4}, search(t, db, s, "rainbow", false))
+
+		require.NoError(t, s.DropIndex(db))
+		assert.False(t, db.Migrator().HasTable(articleIndex.Name))
+		assert.Equal(t, []int{2, 5}, search(t, db, s, "odin", false), "falls back to LIKE")
+	})
+}
+
+func TestSearchSQL(t *testing.T) {
+	cases := map[string]struct {
+		dialector gorm.Dialector
+		sql       string
+	}{
+		"mysql": {
+			dialector: mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}),
+			sql: "SELECT * FROM `articles` WHERE MATCH (`title`, `body`) AGAINST ('+odin* +asg*' IN BOOLEAN MODE) " +
+				"ORDER BY MATCH (`title`, `body`) AGAINST ('+odin* +asg*' IN BOOLEAN MODE) DESC",
+		},
+		"postgres": {
+			dialector: postgres.New(postgres.Config{DSN: "host=localhost user=user dbname=db"}),
+			sql: `SELECT * FROM "articles" WHERE to_tsvector('simple', regexp_replace(coalesce("title", '') || ' ' || coalesce("body", ''), '[^[:alnum:]]+', ' ', 'g')) @@ to_tsquery('simple', 'odin:* & asg:*') ` +
+				`ORDER BY ts_rank(to_tsvector('simple', regexp_replace(coalesce("title", '') || ' ' || coalesce("body", ''), '[^[:alnum:]]+', ' ', 'g')), to_tsquery('simple', 'odin:* & asg:*')) DESC`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, err := gorm.Open(c.dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+			require.NoError(t, err)
+
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return searchutil.New(tx, articleIndex).Search(tx.Model(&article{}), "Odin asg", true).Find(&[]article{})
+			})
+			assert.Equal(t, c.sql, sql)
+		})
+	}
+}
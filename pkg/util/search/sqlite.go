@@ -0,0 +1,128 @@
+package searchutil
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sqliteSearcher uses an external content FTS5 table kept in sync by triggers, e.g. `"odin"* AND "asg"*`.
+// It falls back to LIKE matching until the FTS5 table exists, so it works without FTS5 compiled in (the `sqlite_fts5` build tag).
+type sqliteSearcher struct {
+	idx Index
+	// whether the FTS5 table exists, only positive result is cached since it is created by migrations
+	ready atomic.Bool
+}
+
+func (s *sqliteSearcher) Search(db *gorm.DB, q string, rank bool) *gorm.DB {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return db
+	}
+	if !s.hasFTS(db) {
+		return s.searchLike(db, terms, rank)
+	}
+
+	for i, t := range terms {
+		terms[i] = `"` + t + `"*`
+	}
+	query := strings.Join(terms, " AND ")
+
+	fts, table := quote(db, s.idx.Name), quote(db, s.idx.Table)
+	db = db.Where(fmt.Sprintf("%s.rowid IN (SELECT rowid FROM %s WHERE %s MATCH ?)", table, fts, fts), query)
+	if rank {
+		// bm25 rank is negative, the lower the more relevant
+		sql := fmt.Sprintf("(SELECT rank FROM %s WHERE %s MATCH ? AND rowid = %s.rowid)", fts, fts, table)
+		db = db.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: sql, Vars: []any{query}}})
+	}
+	return db
+}
+
+// searchLike matches every term against any column, ranked by the number of columns starting with the terms
+func (s *sqliteSearcher) searchLike(db *gorm.DB, terms []string, rank bool) *gorm.DB {
+	cols := make([]string, 0, len(s.idx.Columns))
+	for _, c := range s.idx.Columns {
+		cols = append(cols, quote(db, s.idx.Table+"."+c))
+	}
+
+	ranks, rankVars := []string{}, []any{}
+	for _, t := range terms {
+		ors, vars := make([]string, 0, len(cols)), make([]any, 0, len(cols))
+		for _, c := range cols {
+			ors = append(ors, c+" LIKE ?")
+			vars = append(vars, "%"+t+"%")
+			ranks = append(ranks, "(CASE WHEN "+c+" LIKE ? THEN 1 ELSE 0 END)")
+			rankVars = append(rankVars, t+"%")
+		}
+		db = db.Where("("+strings.Join(ors, " OR ")+")", vars...)
+	}
+	if rank {
+		db = db.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "(" + strings.Join(ranks, " + ") + ") DESC", Vars: rankVars}})
+	}
+	return db
+}
+
+func (s *sqliteSearcher) hasFTS(db *gorm.DB) bool {
+	if s.ready.Load() {
+		return true
+	}
+	if db.Session(&gorm.Session{NewDB: true}).Migrator().HasTable(s.idx.Name) {
+		s.ready.Store(true)
+		return true
+	}
+	return false
+}
+
+// CreateIndex creates the FTS5 table & the triggers syncing it with the table, does nothing if FTS5 is not compiled in
+func (s *sqliteSearcher) CreateIndex(db *gorm.DB) error {
+	var enabled bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error; err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	fts, table := quote(db, s.idx.Name), quote(db, s.idx.Table)
+	cols := strings.Join(quoteColumns(db, s.idx), ", ")
+	newCols, oldCols := []string{}, []string{}
+	for _, c := range quoteColumns(db, s.idx) {
+		newCols = append(newCols, "new."+c)
+		oldCols = append(oldCols, "old."+c)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (rowid, %s) VALUES (new.rowid, %s);", fts, cols, strings.Join(newCols, ", "))
+	remove := fmt.Sprintf("INSERT INTO %s (%s, rowid, %s) VALUES ('delete', old.rowid, %s);", fts, fts, cols, strings.Join(oldCols, ", "))
+
+	stmts := []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content=%s, content_rowid='rowid')", fts, cols, quoteString(s.idx.Table)),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s END", quote(db, s.idx.Name+"_ai"), table, insert),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s BEGIN %s END", quote(db, s.idx.Name+"_ad"), table, remove),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END", quote(db, s.idx.Name+"_au"), table, remove, insert),
+		// indexes the existing records
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES ('rebuild')", fts, fts),
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSearcher) DropIndex(db *gorm.DB) error {
+	for _, suffix := range []string{"_ai", "_ad", "_au"} {
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + quote(db, s.idx.Name+suffix)).Error; err != nil {
+			return err
+		}
+	}
+	s.ready.Store(false)
+	return db.Exec("DROP TABLE IF EXISTS " + quote(db, s.idx.Name)).Error
+}
+
+// quoteString quotes the SQL string literal
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
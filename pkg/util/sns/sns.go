@@ -2,7 +2,9 @@ package snsutil
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"strings"
 )
@@ -114,6 +116,36 @@ func (s *Service) DeregisterDevice(endpointArn string) error {
 	return err
 }
 
+// Subscribe subscribes a device endpoint to a topic, returns the subscription ARN
+func (s *Service) Subscribe(topicArn, endpointArn string) (string, error) {
+	output, err := s.sns.Subscribe(&sns.SubscribeInput{
+		TopicArn:              aws.String(topicArn),
+		Protocol:              aws.String("application"),
+		Endpoint:              aws.String(endpointArn),
+		ReturnSubscriptionArn: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return *output.SubscriptionArn, nil
+}
+
+// Unsubscribe deletes a subscription of a topic
+func (s *Service) Unsubscribe(subscriptionArn string) error {
+	_, err := s.sns.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: aws.String(subscriptionArn)})
+	return err
+}
+
+// IsEndpointDisabled checks whether the error is returned because the device endpoint is disabled or deleted,
+// e.g. the app was uninstalled. Such endpoints should be deregistered.
+func IsEndpointDisabled(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	return aerr.Code() == sns.ErrCodeEndpointDisabledException || aerr.Code() == sns.ErrCodeNotFoundException
+}
+
 // SendToDevice sends push notification to a device. The "msg" should contains correct payload for FCM or APNS plarform, depends on the "target" OS
 func (s *Service) SendToDevice(target string, msg Message) (string, error) {
 	jsonMsg, err := json.Marshal(msg)
@@ -165,3 +197,22 @@ func (s *Service) SendToTopic(topic string, msg Message) (string, error) {
 	}
 	return *output.MessageId, nil
 }
+
+// Publish publishes a raw message to a topic, the attributes are sent as String message attributes
+func (s *Service) Publish(topic, message string, attributes map[string]string) (string, error) {
+	input := &sns.PublishInput{
+		Message:  aws.String(message),
+		TopicArn: aws.String(topic),
+	}
+	if len(attributes) > 0 {
+		input.MessageAttributes = make(map[string]*sns.MessageAttributeValue, len(attributes))
+		for k, v := range attributes {
+			input.MessageAttributes[k] = &sns.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+		}
+	}
+	output, err := s.sns.Publish(input)
+	if err != nil {
+		return "", err
+	}
+	return *output.MessageId, nil
+}
@@ -0,0 +1,238 @@
+package webhookutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	queueutil "runar-himmel/pkg/util/queue"
+
+	"gorm.io/gorm"
+)
+
+// MaxResponseBody is the maximum number of bytes of the responses kept in the deliveries
+const MaxResponseBody = 1024
+
+// DispatcherConfig holds the dispatcher configurations, zero values are replaced by the defaults
+type DispatcherConfig struct {
+	// Maximum number of deliveries claimed per batch, default 100
+	BatchSize int
+	// Maximum number of requests sent concurrently, default 10
+	Concurrency int
+	// How often the deliveries are polled when idle, default 1 second
+	PollInterval time.Duration
+	// The timeout of each request, default 10 seconds
+	Timeout time.Duration
+	// Number of attempts before a delivery is given up as failed, default 8
+	MaxAttempts int
+	// The delay before the first retry, doubled for every further attempt up to MaxBackoff. Default 30 seconds & 6 hours.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Number of consecutive failed attempts disabling the subscription, default 25
+	DisableAfter int
+	// How long the claimed deliveries are reserved for the dispatcher, default 2 minutes.
+	// It must be longer than sending a batch, see Timeout & Concurrency.
+	Lease time.Duration
+	// The client sending the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Dispatcher sends the pending deliveries to the subscriptions, see NewSink for queueing them.
+// Each request is signed with the secret of the subscription, see Sign. Responses other than 2xx are failures,
+// retried with exponential backoff. Multiple dispatchers may run concurrently, see queueutil.Claim.
+type Dispatcher struct {
+	db  *gorm.DB
+	cfg DispatcherConfig
+}
+
+// NewDispatcher creates a new dispatcher sending the deliveries in db
+func NewDispatcher(db *gorm.DB, cfg DispatcherConfig) *Dispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 10
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(6*time.Hour, cfg.MinBackoff)
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = 25
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 2 * time.Minute
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Dispatcher{db: db, cfg: cfg}
+}
+
+// Run sends the deliveries until the context is done. Errors are reported to onError if given, then retried.
+func (d *Dispatcher) Run(ctx context.Context, onError func(error)) {
+	queueutil.Poll(ctx, d.cfg.PollInterval, onError, func(ctx context.Context) (bool, error) {
+		n, err := d.DispatchOnce(ctx)
+		return n >= d.cfg.BatchSize, err
+	})
+}
+
+// DispatchOnce claims a batch of the due deliveries of the enabled subscriptions and sends them,
+// returns the number of claimed deliveries. The results are recorded into the deliveries,
+// only the errors of the database are returned.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.claim(ctx)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+	subIDs := make([]string, 0, len(deliveries))
+	for _, dl := range deliveries {
+		subIDs = append(subIDs, dl.SubscriptionID)
+	}
+	subs := []Subscription{}
+	if err := d.db.WithContext(ctx).Where("id IN ?", subIDs).Find(&subs).Error; err != nil {
+		return 0, fmt.Errorf("webhookutil: cannot read subscriptions: %w", err)
+	}
+	subByID := make(map[string]*Subscription, len(subs))
+	for i := range subs {
+		subByID[subs[i].ID] = &subs[i]
+	}
+
+	// send concurrently, then record the results one by one
+	results := make([]result, len(deliveries))
+	sem := make(chan struct{}, d.cfg.Concurrency)
+	wg := sync.WaitGroup{}
+	for i := range deliveries {
+		sub, ok := subByID[deliveries[i].SubscriptionID]
+		if !ok {
+			results[i] = result{err: fmt.Errorf("subscription %s not found", deliveries[i].SubscriptionID)}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = d.send(ctx, sub, &deliveries[i])
+		}(i)
+	}
+	wg.Wait()
+
+	ctx = queueutil.Detach(ctx)
+	for i := range deliveries {
+		if err := d.record(ctx, &deliveries[i], results[i]); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// claim reads the due deliveries and reserves them for the lease, so other dispatchers skip them
+func (d *Dispatcher) claim(ctx context.Context) ([]Delivery, error) {
+	// the subscriptions are not locked
+	deliveries, err := queueutil.Claim(ctx, d.db, Delivery{}.TableName(), func(tx *gorm.DB, now time.Time) *gorm.DB {
+		return tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Where("subscription_id IN (?)", tx.Model(&Subscription{}).Select("id").Where("enabled = ?", true)).
+			Order("id").Limit(d.cfg.BatchSize)
+	}, func(tx *gorm.DB, now time.Time, deliveries []Delivery) error {
+		ids := make([]string, 0, len(deliveries))
+		for _, dl := range deliveries {
+			ids = append(ids, dl.ID)
+		}
+		return tx.Model(&Delivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(d.cfg.Lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webhookutil: cannot claim deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+type result struct {
+	code     int
+	body     string
+	duration time.Duration
+	err      error
+}
+
+// send posts the payload of the delivery to the subscription
+func (d *Dispatcher) send(ctx context.Context, sub *Subscription, dl *Delivery) (res result) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	body := []byte(dl.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, dl.ID)
+	req.Header.Set(HeaderEventID, dl.EventID)
+	req.Header.Set(HeaderEventType, dl.EventType)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, time.Now(), body))
+
+	start := time.Now()
+	defer func() { res.duration = time.Since(start) }()
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	res = result{code: resp.StatusCode, body: string(b)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		res.err = fmt.Errorf("responded %s", resp.Status)
+	}
+	return res
+}
+
+// record saves the result of the attempt, then updates the failures of the subscription
+func (d *Dispatcher) record(ctx context.Context, dl *Delivery, res result) error {
+	now := time.Now().UTC()
+	updates := map[string]any{
+		"attempts":      dl.Attempts + 1,
+		"response_code": res.code,
+		"response_body": res.body,
+		"duration":      res.duration.Milliseconds(),
+		"error":         "",
+	}
+	switch {
+	case res.err == nil:
+		updates["status"] = StatusSucceeded
+		updates["delivered_at"] = now
+	case dl.Attempts+1 >= d.cfg.MaxAttempts:
+		updates["status"] = StatusFailed
+		updates["error"] = res.err.Error()
+	default:
+		updates["next_attempt_at"] = now.Add(queueutil.Backoff(d.cfg.MinBackoff, d.cfg.MaxBackoff, dl.Attempts+1))
+		updates["error"] = res.err.Error()
+	}
+
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Delivery{}).Where("id = ?", dl.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("webhookutil: cannot update delivery %s: %w", dl.ID, err)
+		}
+		subs := tx.Model(&Subscription{}).Where("id = ?", dl.SubscriptionID)
+		if res.err == nil {
+			return subs.Where("failures > 0").Update("failures", 0).Error
+		}
+		if err := subs.Update("failures", gorm.Expr("failures + 1")).Error; err != nil {
+			return fmt.Errorf("webhookutil: cannot update subscription %s: %w", dl.SubscriptionID, err)
+		}
+		return tx.Model(&Subscription{}).Where("id = ? AND enabled = ? AND failures >= ?", dl.SubscriptionID, true, d.cfg.DisableAfter).
+			Updates(map[string]any{"enabled": false, "disabled_at": now}).Error
+	})
+}
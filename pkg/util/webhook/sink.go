@@ -0,0 +1,52 @@
+package webhookutil
+
+import (
+	"context"
+	"encoding/json"
+
+	outboxutil "runar-himmel/pkg/util/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewSink returns an outbox sink queueing a delivery of each event for every enabled subscription of its type.
+// The deliveries are sent by Dispatcher. Queueing the same event again is a no-op, as the outbox is at-least-once.
+func NewSink(db *gorm.DB) outboxutil.Sink {
+	return &sink{db: db}
+}
+
+type sink struct {
+	db *gorm.DB
+}
+
+func (s *sink) Name() string {
+	return "webhooks"
+}
+
+func (s *sink) Deliver(ctx context.Context, e *outboxutil.Event) error {
+	subs := []Subscription{}
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Find(&subs).Error; err != nil {
+		return err
+	}
+	deliveries := []Delivery{}
+	for _, sub := range subs {
+		if !sub.Events.Match(e.Type) {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{SubscriptionID: sub.ID, EventID: e.ID, EventType: e.Type})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	// the same payload for all subscriptions
+	body, err := json.Marshal(e.Message())
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		deliveries[i].Payload = string(body)
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
@@ -0,0 +1,218 @@
+package webhookutil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"runar-himmel/pkg/util/ulidutil"
+
+	"gorm.io/gorm"
+)
+
+// Headers of the webhook requests
+const (
+	// The signature of the request, see Sign
+	HeaderSignature = "X-Webhook-Signature"
+	// ID of the delivery, the same for all attempts
+	HeaderDeliveryID = "X-Webhook-Delivery"
+	// ID & type of the delivered event, the receivers should drop the duplicated events by the ID
+	HeaderEventID   = "X-Webhook-Event-ID"
+	HeaderEventType = "X-Webhook-Event"
+)
+
+// Statuses of the deliveries
+const (
+	// Waiting to be delivered, or retried after NextAttemptAt
+	StatusPending = "pending"
+	// Responded with 2xx
+	StatusSucceeded = "succeeded"
+	// Given up after the maximum attempts, see DispatcherConfig.MaxAttempts
+	StatusFailed = "failed"
+)
+
+// ErrInvalidSignature is returned by Verify when the signature does not match or is too old
+var ErrInvalidSignature = errors.New("webhookutil: invalid signature")
+
+// Subscription represents an endpoint receiving the events
+// swagger:model WebhookSubscription
+type Subscription struct {
+	ID  string `json:"id" gorm:"primaryKey;type:varchar(26)" filter:"exact,in" sort:"true"`
+	URL string `json:"url" gorm:"type:varchar(2048)" filter:"exact,icontains"`
+	// The subscribed event types, e.g. `user.registered`. `user.*` matches all user events, `*` matches all events.
+	Events      Events `json:"events" gorm:"type:text"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	// The key signing the requests, only returned on creation & rotation
+	Secret string `json:"-" gorm:"type:varchar(128);not null"`
+	// Disabled subscriptions receive nothing, the pending deliveries are resumed once enabled again
+	Enabled bool `json:"enabled" gorm:"not null" filter:"exact"`
+	// Number of consecutive failed attempts, reset by the successful ones.
+	// The subscription is disabled automatically when it reaches DispatcherConfig.DisableAfter.
+	Failures   int        `json:"failures" gorm:"not null;default:0"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" filter:"gte,lte" sort:"true"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table of webhook subscriptions
+func (Subscription) TableName() string {
+	return "webhooks"
+}
+
+// BeforeCreate hook executed by gorm
+func (s *Subscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = ulidutil.NewString()
+	}
+	return nil
+}
+
+// Audited enables the audit logs of the subscriptions, see auditutil.Use
+func (Subscription) Audited() bool {
+	return true
+}
+
+// Delivery represents the delivery of an event to a subscription, along with the result of the latest attempt
+// swagger:model WebhookDelivery
+type Delivery struct {
+	ID             string `json:"id" gorm:"primaryKey;type:varchar(26)" filter:"exact,in" sort:"true"`
+	SubscriptionID string `json:"subscription_id" gorm:"type:varchar(26);not null;uniqueIndex:uix_webhook_deliveries_event,priority:1" filter:"exact"`
+	EventID        string `json:"event_id" gorm:"type:varchar(26);not null;uniqueIndex:uix_webhook_deliveries_event,priority:2" filter:"exact"`
+	EventType      string `json:"event_type" gorm:"type:varchar(64)" filter:"exact,in"`
+	// The request body, the same for all attempts
+	Payload string `json:"payload" gorm:"type:text"`
+
+	Status        string     `json:"status" gorm:"type:varchar(10);not null;default:pending;index:idx_webhook_deliveries_pending,priority:1" filter:"exact,in"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_pending,priority:2"`
+	ResponseCode  int        `json:"response_code,omitempty"`
+	ResponseBody  string     `json:"response_body,omitempty" gorm:"type:text"` // truncated to MaxResponseBody
+	Error         string     `json:"error,omitempty" gorm:"type:text"`
+	Duration      int64      `json:"duration"` // of the latest attempt in millisecond
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" filter:"gte,lte" sort:"true"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table of webhook deliveries
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// BeforeCreate hook executed by gorm
+func (d *Delivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = ulidutil.NewString()
+	}
+	if d.Status == "" {
+		d.Status = StatusPending
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now().UTC()
+	}
+	return nil
+}
+
+// Events holds the event types of a subscription, stored as comma separated text
+type Events []string
+
+// Match checks whether the event type is subscribed
+func (e Events) Match(typ string) bool {
+	for _, pattern := range e {
+		if pattern == "*" || pattern == typ {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(typ, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan implements the sql.Scanner interface
+func (e *Events) Scan(value any) error {
+	var s string
+	switch value := value.(type) {
+	case nil:
+	case []byte:
+		s = string(value)
+	case string:
+		s = value
+	default:
+		return fmt.Errorf("webhookutil: cannot scan %T into Events", value)
+	}
+	*e = Events{}
+	if s != "" {
+		*e = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (e Events) Value() (driver.Value, error) {
+	return strings.Join(e, ","), nil
+}
+
+// NewSecret returns a random secret for signing the requests
+func NewSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign returns the signature header of the body sent at t: `t=<unix timestamp>,v1=<hex HMAC-SHA256>`.
+// The HMAC is computed over `<unix timestamp>.<body>` with the secret of the subscription, so the receivers
+// can reject the replayed requests by the timestamp.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks the signature header of the body, which must be signed within tolerance from now.
+// Zero tolerance disables the timestamp check. It is the reference for the receivers.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return ErrInvalidSignature
+	}
+	expected := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
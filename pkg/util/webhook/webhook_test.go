@@ -0,0 +1,220 @@
+package webhookutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"runar-himmel/pkg/util/db/dbtest"
+	outboxutil "runar-himmel/pkg/util/outbox"
+	webhookutil "runar-himmel/pkg/util/webhook"
+)
+
+// receiver is a local endpoint verifying the signatures of the requests
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mu     sync.Mutex
+	status int
+	events []string
+	header http.Header
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{secret: secret, status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := webhookutil.Verify(r.secret, req.Header.Get(webhookutil.HeaderSignature), body, 5*time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		msg := outboxutil.Message{}
+		assert.NoError(t, json.Unmarshal(body, &msg))
+		assert.Equal(t, msg.ID, req.Header.Get(webhookutil.HeaderEventID))
+		r.header = req.Header
+		if r.status == http.StatusOK {
+			r.events = append(r.events, msg.Type)
+		}
+		w.WriteHeader(r.status)
+		_, _ = w.Write([]byte("thanks"))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func subscribe(t *testing.T, db *gorm.DB, url string, events ...string) *webhookutil.Subscription {
+	sub := &webhookutil.Subscription{URL: url, Events: events, Secret: webhookutil.NewSecret(), Enabled: true}
+	require.NoError(t, db.Create(sub).Error)
+	return sub
+}
+
+func emit(t *testing.T, db *gorm.DB, sink outboxutil.Sink, typ string) {
+	require.NoError(t, outboxutil.Emit(context.Background(), db, typ, "", nil))
+	e := outboxutil.Event{}
+	require.NoError(t, db.Order("id DESC").Take(&e).Error)
+	require.NoError(t, sink.Deliver(context.Background(), &e))
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	now := time.Now()
+	sig := webhookutil.Sign("secret", now, body)
+	assert.Regexp(t, `^t=\d+,v1=[0-9a-f]{64}$`, sig)
+
+	assert.NoError(t, webhookutil.Verify("secret", sig, body, time.Minute))
+	assert.ErrorIs(t, webhookutil.Verify("other", sig, body, time.Minute), webhookutil.ErrInvalidSignature)
+	assert.ErrorIs(t, webhookutil.Verify("secret", sig, []byte(`{"id":"2"}`), time.Minute), webhookutil.ErrInvalidSignature)
+	assert.ErrorIs(t, webhookutil.Verify("secret", "v1=abc", body, time.Minute), webhookutil.ErrInvalidSignature)
+
+	// replayed requests
+	old := webhookutil.Sign("secret", now.Add(-time.Hour), body)
+	assert.ErrorIs(t, webhookutil.Verify("secret", old, body, time.Minute), webhookutil.ErrInvalidSignature)
+	assert.NoError(t, webhookutil.Verify("secret", old, body, 0))
+}
+
+func TestEventsMatch(t *testing.T) {
+	assert.True(t, webhookutil.Events{"*"}.Match("user.blocked"))
+	assert.True(t, webhookutil.Events{"memo.created", "user.*"}.Match("user.blocked"))
+	assert.True(t, webhookutil.Events{"user.blocked"}.Match("user.blocked"))
+	assert.False(t, webhookutil.Events{"user.registered", "memo.*"}.Match("user.blocked"))
+	assert.False(t, webhookutil.Events{}.Match("user.blocked"))
+}
+
+func TestSinkAndDispatcher(t *testing.T) {
+	db := dbtest.New(t, &outboxutil.Event{}, &webhookutil.Subscription{}, &webhookutil.Delivery{})
+	sink := webhookutil.NewSink(db)
+	users, all := newReceiver(t, ""), newReceiver(t, "")
+	usersSub := subscribe(t, db, users.URL, "user.*")
+	allSub := subscribe(t, db, all.URL, "*")
+	users.secret, all.secret = usersSub.Secret, allSub.Secret
+	disabled := subscribe(t, db, all.URL, "*")
+	require.NoError(t, db.Model(disabled).Update("enabled", false).Error)
+
+	// queued for the enabled subscriptions of the event type, once
+	emit(t, db, sink, "user.registered")
+	emit(t, db, sink, "memo.created")
+	e := outboxutil.Event{}
+	require.NoError(t, db.Order("id").Take(&e).Error)
+	require.NoError(t, sink.Deliver(context.Background(), &e))
+	deliveries := dbtest.Find[webhookutil.Delivery](t, db)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, usersSub.ID, deliveries[0].SubscriptionID)
+	assert.Equal(t, "user.registered", deliveries[0].EventType)
+	assert.Equal(t, webhookutil.StatusPending, deliveries[0].Status)
+
+	d := webhookutil.NewDispatcher(db, webhookutil.DispatcherConfig{MaxAttempts: 2})
+	n, err := d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"user.registered"}, users.received())
+	assert.ElementsMatch(t, []string{"user.registered", "memo.created"}, all.received())
+	assert.Equal(t, "application/json", users.header.Get("Content-Type"))
+	assert.Equal(t, "user.registered", users.header.Get(webhookutil.HeaderEventType))
+	assert.Equal(t, deliveries[0].ID, users.header.Get(webhookutil.HeaderDeliveryID))
+
+	// the delivery log
+	deliveries = dbtest.Find[webhookutil.Delivery](t, db)
+	assert.Equal(t, webhookutil.StatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].ResponseCode)
+	assert.Equal(t, "thanks", deliveries[0].ResponseBody)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+	n, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// retried with backoff, then given up
+	users.setStatus(http.StatusInternalServerError)
+	emit(t, db, sink, "user.blocked")
+	_, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	dl := dbtest.Find[webhookutil.Delivery](t, db)[3]
+	assert.Equal(t, webhookutil.StatusPending, dl.Status)
+	assert.Equal(t, http.StatusInternalServerError, dl.ResponseCode)
+	assert.Equal(t, "responded 500 Internal Server Error", dl.Error)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), dl.NextAttemptAt, 5*time.Second)
+	dbtest.MakeDue(t, db, &webhookutil.Delivery{}, "next_attempt_at", "status = ?", webhookutil.StatusPending)
+	_, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	dl = dbtest.Find[webhookutil.Delivery](t, db)[3]
+	assert.Equal(t, webhookutil.StatusFailed, dl.Status)
+	assert.Equal(t, 2, dl.Attempts)
+	sub := webhookutil.Subscription{}
+	require.NoError(t, db.Take(&sub, "id = ?", usersSub.ID).Error)
+	assert.Equal(t, 2, sub.Failures)
+
+	// failures are reset by the successful attempts
+	users.setStatus(http.StatusOK)
+	emit(t, db, sink, "user.unblocked")
+	_, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, db.Take(&sub, "id = ?", usersSub.ID).Error)
+	assert.Zero(t, sub.Failures)
+	assert.Contains(t, users.received(), "user.unblocked")
+}
+
+func TestDispatcherDisable(t *testing.T) {
+	db := dbtest.New(t, &outboxutil.Event{}, &webhookutil.Subscription{}, &webhookutil.Delivery{})
+	sink := webhookutil.NewSink(db)
+	r := newReceiver(t, "")
+	sub := subscribe(t, db, r.URL, "*")
+	// signed with another secret
+	r.secret = "rotated"
+
+	d := webhookutil.NewDispatcher(db, webhookutil.DispatcherConfig{DisableAfter: 3})
+	for i := 0; i < 2; i++ {
+		emit(t, db, sink, "user.registered")
+	}
+	_, err := d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, dbtest.Find[webhookutil.Delivery](t, db)[0].ResponseCode)
+
+	dbtest.MakeDue(t, db, &webhookutil.Delivery{}, "next_attempt_at", "status = ?", webhookutil.StatusPending)
+	_, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	got := webhookutil.Subscription{}
+	require.NoError(t, db.Take(&got, "id = ?", sub.ID).Error)
+	assert.False(t, got.Enabled)
+	assert.Equal(t, 4, got.Failures)
+	assert.NotNil(t, got.DisabledAt)
+
+	// nothing is queued nor sent while disabled, the pending deliveries are resumed once enabled
+	emit(t, db, sink, "user.blocked")
+	assert.Len(t, dbtest.Find[webhookutil.Delivery](t, db), 2)
+	dbtest.MakeDue(t, db, &webhookutil.Delivery{}, "next_attempt_at", "status = ?", webhookutil.StatusPending)
+	n, err := d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	r.secret = sub.Secret
+	require.NoError(t, db.Model(&got).Updates(map[string]any{"enabled": true, "failures": 0}).Error)
+	dbtest.MakeDue(t, db, &webhookutil.Delivery{}, "next_attempt_at", "status = ?", webhookutil.StatusPending)
+	n, err = d.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, r.received(), 2)
+}
@@ -40,3 +40,4 @@ gobuild() {
 
 # Start building
 gobuild ./cmd/api main
+gobuild ./cmd/worker worker
@@ -29,3 +29,5 @@ gobuild() {
 
 gobuild ./functions/migration migration
 gobuild ./functions/seed seed
+gobuild ./functions/outbox outbox
+gobuild ./functions/cron cron